@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/arcedo/financial-ai-backend/utils"
+)
+
+// JWKS publishes the public signing keys so other services can verify our tokens.
+// It is served as a plain JWK Set, not wrapped in an APIResponse.
+func JWKS(keys *utils.KeyManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(keys.JWKS())
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/config"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/requests"
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	statusOK       = "ok"
+	statusDegraded = "degraded"
+	statusDown     = "down"
+)
+
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+type ReadinessReport struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// healthCheck is a single dependency check. Only critical checks make the instance unready,
+// the others just report it as degraded.
+type healthCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// Healthz is the liveness probe: the process is up and serving requests
+func Healthz(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	helpers.WriteJSON(w, http.StatusOK, map[string]string{"status": statusOK}, nil, "")
+	return nil
+}
+
+// Readyz is the readiness probe: it answers 503 when a critical dependency (Mongo) is down so
+// the orchestrator stops routing traffic here, and reports the state of every dependency
+func Readyz(cfg config.HealthConfig, llm *requests.LLMClient) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		checks := []healthCheck{
+			{name: "mongo", critical: true, check: store.Ping},
+			{name: "llm", check: llm.Ping},
+			{name: "stocks", check: func(ctx context.Context) error {
+				return checkStocksFreshness(ctx, store, cfg.StocksMaxAge)
+			}},
+		}
+
+		report := runHealthChecks(r.Context(), checks, cfg.CheckTimeout)
+		status := http.StatusOK
+		if report.Status == statusDown {
+			status = http.StatusServiceUnavailable
+		}
+
+		helpers.WriteJSON(w, status, report, nil, "")
+		return nil
+	}
+}
+
+// runHealthChecks runs the checks concurrently. The probe is public, so why a check failed is
+// only logged.
+func runHealthChecks(ctx context.Context, checks []healthCheck, timeout time.Duration) ReadinessReport {
+	report := ReadinessReport{Status: statusOK, Dependencies: make(map[string]DependencyStatus, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, hc := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := hc.check(checkCtx)
+			result := DependencyStatus{
+				Status:    statusOK,
+				Critical:  hc.critical,
+				LatencyMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = statusDown
+				utils.LoggerFrom(ctx).Warn("health check failed", "dependency", hc.name, "critical", hc.critical, "error", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Dependencies[hc.name] = result
+			if err != nil {
+				if hc.critical {
+					report.Status = statusDown
+				} else if report.Status == statusOK {
+					report.Status = statusDegraded
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report
+}
+
+// checkStocksFreshness fails when the newest stored bar is older than maxAge
+func checkStocksFreshness(ctx context.Context, store db.MongoStorage, maxAge time.Duration) error {
+	var latest types.Stock
+	err := store.Collection("stocks").FindOne(ctx, bson.D{},
+		options.FindOne().SetSort(bson.D{{Key: "date", Value: -1}}),
+	).Decode(&latest)
+	if err == mongo.ErrNoDocuments {
+		return fmt.Errorf("no stock data stored")
+	}
+	if err != nil {
+		return fmt.Errorf("error reading latest stock: %w", err)
+	}
+
+	latestDate, err := time.Parse("2006-01-02", latest.Date)
+	if err != nil {
+		return fmt.Errorf("invalid date on latest stock: %s", latest.Date)
+	}
+	if age := time.Since(latestDate); age > maxAge {
+		return fmt.Errorf("latest bar is from %s, older than %s", latest.Date, maxAge)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunHealthChecks(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("dial tcp 10.0.0.12:27017: connection refused") }
+
+	tests := []struct {
+		name   string
+		checks []healthCheck
+		want   string
+	}{
+		{"all up", []healthCheck{{name: "mongo", critical: true, check: ok}, {name: "llm", check: ok}}, statusOK},
+		{"optional dependency down", []healthCheck{{name: "mongo", critical: true, check: ok}, {name: "llm", check: failing}}, statusDegraded},
+		{"critical dependency down", []healthCheck{{name: "mongo", critical: true, check: failing}, {name: "llm", check: ok}}, statusDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := runHealthChecks(context.Background(), tt.checks, time.Second)
+			if report.Status != tt.want {
+				t.Errorf("status = %s, want %s", report.Status, tt.want)
+			}
+			if len(report.Dependencies) != len(tt.checks) {
+				t.Errorf("reported %d dependencies, want %d", len(report.Dependencies), len(tt.checks))
+			}
+
+			// The probe is public, the cause of a failure stays in the logs
+			body, err := json.Marshal(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(body), "10.0.0.12") {
+				t.Errorf("report exposes the error: %s", body)
+			}
+		})
+	}
+}
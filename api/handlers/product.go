@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/risk"
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetProducts lists the products on offer, archived ones left out
+func GetProducts(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	productsCollection := store.Collection("products")
+	cursor, err := productsCollection.Find(r.Context(), bson.M{"archived": bson.M{"$ne": true}},
+		options.Find().SetSort(bson.D{{Key: "symbol", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("error retrieving products: %w", err)
+	}
+	defer cursor.Close(r.Context())
+
+	products := []types.Product{}
+	if err := cursor.All(r.Context(), &products); err != nil {
+		return fmt.Errorf("error decoding products: %w", err)
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, products, nil, "")
+	return nil
+}
+
+func GetProduct(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	var product types.Product
+	err := store.Collection("products").FindOne(r.Context(), bson.M{"symbol": r.PathValue("symbol")}).Decode(&product)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return utils.NewNotFoundError("product")
+		}
+		return fmt.Errorf("error retrieving product: %w", err)
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, product, nil, "")
+	return nil
+}
+
+// GetProductDecay compares the return of a product with the multiple of its underlying's
+// return it targets, over the last year by default
+func GetProductDecay(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	var query types.DecayQuery
+	if err := utils.DecodeQuery(r.URL.Query(), &query); err != nil {
+		return err
+	}
+	if err := utils.Validate(r.Context(), query); err != nil {
+		return err
+	}
+	if query.From != "" && query.To != "" && query.From > query.To {
+		return utils.NewValidationError("from", "from must not be after to")
+	}
+
+	var product types.Product
+	err := store.Collection("products").FindOne(r.Context(), bson.M{"symbol": r.PathValue("symbol")}).Decode(&product)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return utils.NewNotFoundError("product")
+		}
+		return fmt.Errorf("error retrieving product: %w", err)
+	}
+
+	decay, err := risk.Analyze(r.Context(), store, product, query.From, query.To)
+	switch {
+	case errors.Is(err, risk.ErrNoUnderlying):
+		return utils.NewNotFoundError("underlying")
+	case errors.Is(err, risk.ErrNotEnoughData):
+		return utils.NewNotFoundError("prices for the period")
+	case err != nil:
+		return err
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, decay, nil, "")
+	return nil
+}
+
+func CreateProduct(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	var newProduct types.NewProduct
+	if err := json.NewDecoder(r.Body).Decode(&newProduct); err != nil {
+		return err
+	}
+	if err := utils.Validate(r.Context(), newProduct); err != nil {
+		return err
+	}
+
+	product := types.Product{
+		Symbol:     strings.ToUpper(strings.TrimSpace(newProduct.Symbol)),
+		Name:       strings.TrimSpace(newProduct.Name),
+		AssetClass: newProduct.AssetClass,
+		Sector:     strings.TrimSpace(newProduct.Sector),
+		Exchange:   strings.ToUpper(strings.TrimSpace(newProduct.Exchange)),
+		Currency:   strings.ToUpper(newProduct.Currency),
+		Leverage:   1,
+		Underlying: newProduct.Underlying,
+	}
+	if newProduct.Leverage != nil {
+		product.Leverage = *newProduct.Leverage
+	}
+	if newProduct.Inverse != nil {
+		product.Inverse = *newProduct.Inverse
+	}
+
+	_, err := store.Collection("products").InsertOne(r.Context(), product)
+	if mongo.IsDuplicateKeyError(err) {
+		return utils.NewConflictError(fmt.Sprintf("product %s already exists, archived products can be restored by updating them", product.Symbol))
+	}
+	if err != nil {
+		return fmt.Errorf("error creating product: %w", err)
+	}
+
+	helpers.WriteJSON(w, http.StatusCreated, product, nil, "product created successfully")
+	return nil
+}
+
+func UpdateProduct(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	var update types.UpdateProduct
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		return err
+	}
+	if err := utils.Validate(r.Context(), update); err != nil {
+		return err
+	}
+
+	fields := bson.M{}
+	if update.Name != nil {
+		fields["name"] = strings.TrimSpace(*update.Name)
+	}
+	if update.AssetClass != nil {
+		fields["asset_class"] = *update.AssetClass
+	}
+	if update.Sector != nil {
+		fields["sector"] = strings.TrimSpace(*update.Sector)
+	}
+	if update.Exchange != nil {
+		fields["exchange"] = strings.ToUpper(strings.TrimSpace(*update.Exchange))
+	}
+	if update.Currency != nil {
+		fields["currency"] = strings.ToUpper(*update.Currency)
+	}
+	if update.Leverage != nil {
+		fields["leverage"] = *update.Leverage
+	}
+	if update.Inverse != nil {
+		fields["inverse"] = *update.Inverse
+	}
+	if update.Underlying != nil {
+		fields["underlying"] = *update.Underlying
+	}
+	if update.Archived != nil {
+		fields["archived"] = *update.Archived
+	}
+	if len(fields) == 0 {
+		return utils.NewValidationError("", "at least one field must be provided")
+	}
+
+	var product types.Product
+	err := store.Collection("products").FindOneAndUpdate(r.Context(),
+		bson.M{"symbol": r.PathValue("symbol")},
+		bson.M{"$set": fields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&product)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return utils.NewNotFoundError("product")
+		}
+		return fmt.Errorf("error updating product: %w", err)
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, product, nil, "product updated successfully")
+	return nil
+}
+
+// DeleteProduct archives a product: it is no longer offered nor synced, but the transactions
+// and prices referring to it stay valid and the startup seeding does not bring it back
+func DeleteProduct(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	res, err := store.Collection("products").UpdateOne(r.Context(),
+		bson.M{"symbol": r.PathValue("symbol")},
+		bson.M{"$set": bson.M{"archived": true}},
+	)
+	if err != nil {
+		return fmt.Errorf("error archiving product: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return utils.NewNotFoundError("product")
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, nil, nil, "product archived successfully")
+	return nil
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/requests"
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// emailVerificationTTL is how long a requested email change can be confirmed
+const emailVerificationTTL = 24 * time.Hour
+
+func UpdateUser(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("unable to retrieve user ID from context")
+	}
+
+	var update types.UpdateUser
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		return err
+	}
+
+	if update.Name == nil && update.LastName == nil {
+		return utils.NewValidationError("", "at least one field must be provided")
+	}
+	if err := utils.Validate(r.Context(), update); err != nil {
+		return err
+	}
+
+	fields := bson.M{}
+	if update.Name != nil {
+		fields["name"] = utils.SanitizeString(*update.Name)
+	}
+	if update.LastName != nil {
+		fields["lastname"] = utils.SanitizeString(*update.LastName)
+	}
+
+	userCollection := store.Collection("users")
+	var user types.PublicUser
+	err := userCollection.FindOneAndUpdate(r.Context(),
+		bson.M{"_id": userID},
+		bson.M{"$set": fields},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return utils.NewNotFoundError("user")
+		}
+		return fmt.Errorf("error updating user: %w", err)
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, user, nil, "user updated successfully")
+	return nil
+}
+
+func ChangePassword(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("unable to retrieve user ID from context")
+	}
+	currentSessionID, _ := r.Context().Value("sessionID").(primitive.ObjectID)
+
+	var change types.ChangePassword
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(r.Context(), change); err != nil {
+		return err
+	}
+
+	user, err := findUser(r, store, userID)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(user.Password, change.CurrentPassword) {
+		return utils.ErrInvalidCredentials
+	}
+
+	hashedPassword, err := utils.HashPassword(change.NewPassword)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	userCollection := store.Collection("users")
+	_, err = userCollection.UpdateOne(r.Context(), bson.M{"_id": userID}, bson.M{"$set": bson.M{"password": hashedPassword}})
+	if err != nil {
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	// Anyone holding the old password may still be signed in elsewhere
+	if _, err := revokeSessions(r.Context(), store, userID, currentSessionID); err != nil {
+		return err
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, nil, nil, "password changed successfully")
+	return nil
+}
+
+func RequestEmailChange(mailer *requests.Mailer) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		return requestEmailChange(w, r, store, mailer)
+	}
+}
+
+func requestEmailChange(w http.ResponseWriter, r *http.Request, store db.MongoStorage, mailer *requests.Mailer) error {
+	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("unable to retrieve user ID from context")
+	}
+
+	var change types.ChangeEmail
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		return err
+	}
+	change.Email = utils.SanitizeString(change.Email)
+
+	if err := utils.Validate(r.Context(), change); err != nil {
+		return err
+	}
+
+	user, err := findUser(r, store, userID)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(user.Password, change.Password) {
+		return utils.ErrInvalidCredentials
+	}
+	if change.Email == user.Email {
+		return utils.NewValidationError("email", "new email must be different from the current one")
+	}
+	if err := checkEmailAvailable(r, store, change.Email); err != nil {
+		return err
+	}
+
+	token, tokenHash, err := newVerificationToken()
+	if err != nil {
+		return err
+	}
+
+	userCollection := store.Collection("users")
+	_, err = userCollection.UpdateOne(r.Context(), bson.M{"_id": userID}, bson.M{"$set": bson.M{
+		"email_change": types.EmailChange{
+			Email:     change.Email,
+			TokenHash: tokenHash,
+			ExpiresAt: time.Now().UTC().Add(emailVerificationTTL),
+		},
+	}})
+	if err != nil {
+		return fmt.Errorf("error saving email change: %w", err)
+	}
+
+	if err := mailer.SendEmailVerification(r.Context(), change.Email, token); err != nil {
+		return err
+	}
+
+	helpers.WriteJSON(w, http.StatusAccepted, nil, nil, "verification email sent, the change applies once it is confirmed")
+	return nil
+}
+
+func VerifyEmailChange(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	var verify types.VerifyEmail
+	if err := json.NewDecoder(r.Body).Decode(&verify); err != nil {
+		return err
+	}
+
+	if err := utils.Validate(r.Context(), verify); err != nil {
+		return err
+	}
+
+	userCollection := store.Collection("users")
+	var user types.User
+	err := userCollection.FindOne(r.Context(), bson.M{
+		"email_change.token_hash": hashVerificationToken(verify.Token),
+		"email_change.expires_at": bson.M{"$gt": time.Now().UTC()},
+	}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return utils.NewValidationError("token", "invalid or expired verification token")
+		}
+		return fmt.Errorf("error searching for user: %w", err)
+	}
+
+	// The address may have been taken while the verification was pending
+	if err := checkEmailAvailable(r, store, user.EmailChange.Email); err != nil {
+		return err
+	}
+
+	_, err = userCollection.UpdateOne(r.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set":   bson.M{"email": user.EmailChange.Email},
+		"$unset": bson.M{"email_change": ""},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return utils.NewConflictError("user with this email already exists")
+	}
+	if err != nil {
+		return fmt.Errorf("error updating email: %w", err)
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, nil, nil, "email changed successfully")
+	return nil
+}
+
+func findUser(r *http.Request, store db.MongoStorage, userID primitive.ObjectID) (types.User, error) {
+	userCollection := store.Collection("users")
+	var user types.User
+	if err := userCollection.FindOne(r.Context(), bson.M{"_id": userID}).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return types.User{}, utils.NewNotFoundError("user")
+		}
+		return types.User{}, fmt.Errorf("error retrieving user: %w", err)
+	}
+	return user, nil
+}
+
+func checkEmailAvailable(r *http.Request, store db.MongoStorage, email string) error {
+	userCollection := store.Collection("users")
+	count, err := userCollection.CountDocuments(r.Context(), bson.M{"email": email})
+	if err != nil {
+		return fmt.Errorf("error checking for existing user: %w", err)
+	}
+	if count > 0 {
+		return utils.NewConflictError("user with this email already exists")
+	}
+	return nil
+}
+
+// newVerificationToken returns a random token to mail to the user and the hash we store for it
+func newVerificationToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("error generating verification token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+	return token, hashVerificationToken(token), nil
+}
+
+func hashVerificationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/corporate"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Kinds of matches, best first
+var matchKinds = []string{"symbol", "prefix", "name", "contains", "fuzzy"}
+
+// SearchProducts finds the products whose symbol or name match q, exactly, by prefix, by
+// substring or within a typo or two, narrowed by the filters. Without q every product passing
+// the filters matches. Results are ranked by kind of match, then by the number of users
+// holding them, as last counted by interest.
+func SearchProducts(interest *corporate.InterestCache) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		return searchProducts(w, r, store, interest.Get())
+	}
+}
+
+func searchProducts(w http.ResponseWriter, r *http.Request, store db.MongoStorage, interest map[string]corporate.Interest) error {
+	query := types.ProductSearchQuery{Limit: 10}
+	if err := utils.DecodeQuery(r.URL.Query(), &query); err != nil {
+		return err
+	}
+	if err := utils.Validate(r.Context(), query); err != nil {
+		return err
+	}
+
+	filter := bson.M{"archived": bson.M{"$ne": true}}
+	if query.AssetClass != "" {
+		filter["asset_class"] = query.AssetClass
+	}
+	if query.Leveraged != nil {
+		if *query.Leveraged {
+			filter["leverage"] = bson.M{"$gt": 1}
+		} else {
+			filter["leverage"] = bson.M{"$lte": 1}
+		}
+	}
+	if query.Inverse != nil {
+		filter["inverse"] = *query.Inverse
+	}
+
+	cursor, err := store.Collection("products").Find(r.Context(), filter)
+	if err != nil {
+		return fmt.Errorf("error retrieving products: %w", err)
+	}
+	var products []types.Product
+	if err := cursor.All(r.Context(), &products); err != nil {
+		return fmt.Errorf("error decoding products: %w", err)
+	}
+
+	q := strings.ToLower(strings.TrimSpace(query.Q))
+	results := []types.ProductSearchResult{}
+	for _, product := range products {
+		kind := ""
+		if q != "" {
+			if kind = matchProduct(product, q); kind == "" {
+				continue
+			}
+		}
+		results = append(results, types.ProductSearchResult{Product: product, Match: kind, Holders: interest[product.Symbol].Holders})
+	}
+
+	rank := map[string]int{}
+	for i, kind := range matchKinds {
+		rank[kind] = i
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if rank[a.Match] != rank[b.Match] {
+			return rank[a.Match] < rank[b.Match]
+		}
+		if a.Holders != b.Holders {
+			return a.Holders > b.Holders
+		}
+		return a.Product.Symbol < b.Product.Symbol
+	})
+
+	if len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	helpers.WriteJSON(w, http.StatusOK, results, nil, "")
+	return nil
+}
+
+// matchProduct returns how the lowercase query matches the product, "" if it does not
+func matchProduct(product types.Product, q string) string {
+	symbol := strings.ToLower(product.Symbol)
+	name := strings.ToLower(product.Name)
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '-' || r == ',' || r == '.' || r == '&' || r == '$'
+	})
+
+	switch {
+	case symbol == q:
+		return "symbol"
+	case strings.HasPrefix(symbol, q):
+		return "prefix"
+	case strings.HasPrefix(name, q) || anyWord(words, func(word string) bool { return strings.HasPrefix(word, q) }):
+		return "name"
+	case strings.Contains(symbol, q) || strings.Contains(name, q):
+		return "contains"
+	}
+
+	// Longer queries allow more typos, short ones would match about anything
+	maxDistance := 0
+	switch {
+	case len(q) >= 8:
+		maxDistance = 2
+	case len(q) >= 4:
+		maxDistance = 1
+	}
+	if maxDistance == 0 {
+		return ""
+	}
+	if editDistance(symbol, q) <= maxDistance || anyWord(words, func(word string) bool {
+		// Also a typo in the start of a word, e.g. "nvidai" for "nvidia corporation"
+		return editDistance(word, q) <= maxDistance ||
+			len(word) > len(q) && editDistance(word[:len(q)], q) <= maxDistance
+	}) {
+		return "fuzzy"
+	}
+	return ""
+}
+
+func anyWord(words []string, match func(string) bool) bool {
+	for _, word := range words {
+		if match(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the number of single character insertions, deletions, substitutions and
+// swaps of adjacent characters turning a into b (optimal string alignment distance)
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
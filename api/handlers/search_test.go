@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"aapl", "aapl", 0},
+		{"aapl", "", 4},
+		{"nvda", "nvd", 1},
+		{"nvda", "nvdia", 1},
+		{"nvidia", "nvidai", 1},
+		{"tesla", "telsa", 1},
+		{"apple", "apply", 1},
+		{"microsoft", "micorsfot", 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMatchProduct(t *testing.T) {
+	nvidia := types.Product{Symbol: "NVDA", Name: "NVIDIA Corporation"}
+	sqqq := types.Product{Symbol: "SQQQ", Name: "ProShares UltraPro Short QQQ"}
+	tests := []struct {
+		name    string
+		product types.Product
+		q       string
+		want    string
+	}{
+		{"symbol", nvidia, "nvda", "symbol"},
+		{"symbol prefix", nvidia, "nv", "prefix"},
+		{"name prefix", nvidia, "nvidia", "name"},
+		{"word prefix", sqqq, "ultra", "name"},
+		{"substring", sqqq, "hort", "contains"},
+		{"typo", nvidia, "nvidai", "fuzzy"},
+		{"typo in a word start", sqqq, "ultrapor", "fuzzy"},
+		{"two typos in a long query", nvidia, "corprotaion", "fuzzy"},
+		{"short queries need to match", nvidia, "nvx", ""},
+		{"no match", nvidia, "tesla", ""},
+	}
+	for _, tt := range tests {
+		if got := matchProduct(tt.product, tt.q); got != tt.want {
+			t.Errorf("%s: matchProduct(%s, %q) = %q, want %q", tt.name, tt.product.Symbol, tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// startSession records a new login for the user and returns a token bound to it
+func startSession(r *http.Request, store db.MongoStorage, keys *utils.KeyManager, userID primitive.ObjectID) (string, error) {
+	now := time.Now().UTC()
+	session := types.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     userID,
+		Device:     r.UserAgent(),
+		IP:         utils.ClientIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.TokenTTL),
+	}
+
+	if _, err := store.Collection("sessions").InsertOne(r.Context(), session); err != nil {
+		return "", fmt.Errorf("error creating session: %w", err)
+	}
+
+	token, err := utils.GenerateJWT(keys, userID, session.ID)
+	if err != nil {
+		return "", fmt.Errorf("error generating token: %w", err)
+	}
+	return token, nil
+}
+
+func GetSessions(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("unable to retrieve user ID from context")
+	}
+	currentSessionID, _ := r.Context().Value("sessionID").(primitive.ObjectID)
+
+	sessionsColl := store.Collection("sessions")
+	cursor, err := sessionsColl.Find(r.Context(), bson.M{
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now().UTC()},
+	}, options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}}))
+	if err != nil {
+		return fmt.Errorf("failed to fetch sessions: %w", err)
+	}
+	defer cursor.Close(r.Context())
+
+	var sessions []types.Session
+	if err := cursor.All(r.Context(), &sessions); err != nil {
+		return fmt.Errorf("failed to decode sessions: %w", err)
+	}
+
+	publicSessions := make([]types.SessionPublic, 0, len(sessions))
+	for _, session := range sessions {
+		publicSessions = append(publicSessions, types.SessionPublic{
+			ID:         session.ID.Hex(),
+			Device:     session.Device,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentSessionID,
+		})
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, publicSessions, nil, "")
+	return nil
+}
+
+func RevokeSession(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("unable to retrieve user ID from context")
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		return utils.NewValidationError("id", "invalid session ID")
+	}
+
+	sessionsColl := store.Collection("sessions")
+	res, err := sessionsColl.UpdateOne(r.Context(), bson.M{
+		"_id":        sessionID,
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return utils.NewNotFoundError("session")
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, nil, nil, "session revoked successfully")
+	return nil
+}
+
+func RevokeOtherSessions(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("unable to retrieve user ID from context")
+	}
+	currentSessionID, _ := r.Context().Value("sessionID").(primitive.ObjectID)
+
+	revoked, err := revokeSessions(r.Context(), store, userID, currentSessionID)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, map[string]int64{"revoked": revoked}, nil, "other sessions revoked successfully")
+	return nil
+}
+
+// revokeSessions signs the user out everywhere except the session given in keep
+func revokeSessions(ctx context.Context, store db.MongoStorage, userID, keep primitive.ObjectID) (int64, error) {
+	sessionsColl := store.Collection("sessions")
+	res, err := sessionsColl.UpdateMany(ctx, bson.M{
+		"_id":        bson.M{"$ne": keep},
+		"user_id":    userID,
+		"revoked_at": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return res.ModifiedCount, nil
+}
@@ -1,62 +1,338 @@
 package handlers
 
 import (
-	"context"
 	"fmt"
 	"net/http"
+	"slices"
+	"sort"
+	"time"
 
 	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/corporate"
 	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/indicators"
+	"github.com/arcedo/financial-ai-backend/marketdata"
+	"github.com/arcedo/financial-ai-backend/quality"
 	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func GetAllStocks(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
 	stockCollection := store.Collection("stocks")
-	cursor, err := stockCollection.Find(context.Background(), bson.D{})
+	cursor, err := stockCollection.Find(r.Context(), bson.D{})
 	if err != nil {
-		return fmt.Errorf("error retrieving stocks: %v", err)
+		return fmt.Errorf("error retrieving stocks: %w", err)
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(r.Context())
 
 	var stocks []types.Stock
-	for cursor.Next(context.Background()) {
+	for cursor.Next(r.Context()) {
 		var stock types.Stock
 		if err := cursor.Decode(&stock); err != nil {
-			return fmt.Errorf("error decoding stocks: %v", err)
+			return fmt.Errorf("error decoding stocks: %w", err)
 		}
 		stocks = append(stocks, stock)
 	}
 
 	if err := cursor.Err(); err != nil {
-		return fmt.Errorf("cursor error: %v", err)
+		return fmt.Errorf("cursor error: %w", err)
 	}
 
 	helpers.WriteJSON(w, http.StatusOK, stocks, nil, "")
 	return nil
 }
 
-func GetProducts(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
-	productsCollection := store.Collection("products")
-	cursor, err := productsCollection.Find(context.Background(), bson.D{})
+// GetStockHistory returns a page of the bars of a symbol between from and to (inclusive),
+// oldest first, as traded or adjusted for splits and dividends. Daily bars are resampled to
+// the requested interval before paginating, so pages count resampled bars.
+func GetStockHistory(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	symbol := r.PathValue("symbol")
+	query := types.StockHistoryQuery{Interval: marketdata.IntervalDaily, Page: 1, PageSize: 100}
+	if err := utils.DecodeQuery(r.URL.Query(), &query); err != nil {
+		return err
+	}
+	if err := utils.Validate(r.Context(), query); err != nil {
+		return err
+	}
+	if query.From != "" && query.To != "" && query.From > query.To {
+		return utils.NewValidationError("from", "from must not be after to")
+	}
+	if err := checkProductExists(r, store, symbol); err != nil {
+		return err
+	}
+
+	dateRange := bson.M{}
+	if query.From != "" {
+		dateRange["$gte"] = query.From
+	}
+	if query.To != "" {
+		dateRange["$lte"] = query.To
+	}
+	filter := bson.M{"symbol": symbol}
+	if len(dateRange) > 0 {
+		filter["date"] = dateRange
+	}
+
+	history := types.StockHistory{Symbol: symbol, Interval: query.Interval, Page: query.Page, PageSize: query.PageSize}
+	byDate := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+
+	// Daily bars are paginated by Mongo, the other intervals need every bar to resample
+	if query.Interval == marketdata.IntervalDaily {
+		total, err := store.Collection("stocks").CountDocuments(r.Context(), filter)
+		if err != nil {
+			return fmt.Errorf("error counting stocks: %w", err)
+		}
+		bars, err := findBars(r, store, filter, byDate.
+			SetSkip(int64(query.Page-1)*int64(query.PageSize)).
+			SetLimit(int64(query.PageSize)))
+		if err != nil {
+			return err
+		}
+		if query.Adjusted {
+			if bars, err = adjustPage(r, store, symbol, bars); err != nil {
+				return err
+			}
+		}
+		history.Total = int(total)
+		history.Bars = append([]types.Bar{}, bars...)
+		helpers.WriteJSON(w, http.StatusOK, history, nil, "")
+		return nil
+	}
+
+	bars, err := findBars(r, store, filter, byDate)
+	if err != nil {
+		return err
+	}
+	if query.Adjusted {
+		actions, err := corporate.Load(r.Context(), store, symbol)
+		if err != nil {
+			return err
+		}
+		bars = corporate.AdjustBars(bars, actions, true)
+	}
+	bars, err = marketdata.Resample(bars, query.Interval)
+	if err != nil {
+		return fmt.Errorf("error resampling %s: %w", symbol, err)
+	}
+
+	start := min((query.Page-1)*query.PageSize, len(bars))
+	end := min(start+query.PageSize, len(bars))
+	history.Total = len(bars)
+	history.Bars = append([]types.Bar{}, bars[start:end]...)
+	helpers.WriteJSON(w, http.StatusOK, history, nil, "")
+	return nil
+}
+
+// GetLatestStock returns the last stored bar of a symbol and its change over the bar before
+func GetLatestStock(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	symbol := r.PathValue("symbol")
+	if err := checkProductExists(r, store, symbol); err != nil {
+		return err
+	}
+
+	bars, err := recentBars(r, store, symbol, 2)
 	if err != nil {
-		return fmt.Errorf("error retrieving products: %v", err)
+		return err
+	}
+	if len(bars) == 0 {
+		return utils.NewNotFoundError("stock price")
 	}
-	defer cursor.Close(context.Background())
 
-	var products []types.Product
-	for cursor.Next(context.Background()) {
-		var product types.Product
-		if err := cursor.Decode(&product); err != nil {
-			return fmt.Errorf("error decoding products: %v", err)
+	latest := bars[len(bars)-1]
+	quote := types.Quote{
+		Symbol: symbol,
+		Date:   latest.Date,
+		Open:   latest.Open,
+		High:   latest.High,
+		Low:    latest.Low,
+		Close:  latest.Close,
+		Volume: latest.Volume,
+	}
+	if len(bars) > 1 && bars[0].Close != 0 {
+		quote.PreviousClose = bars[0].Close
+		quote.Change = latest.Close - quote.PreviousClose
+		quote.ChangePercent = quote.Change / quote.PreviousClose * 100
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, quote, nil, "")
+	return nil
+}
+
+// GetStockIndicators computes the requested indicators over the adjusted daily closes of a
+// symbol. Bars before from are still used to fill the windows, so the series start at from.
+func GetStockIndicators(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	symbol := r.PathValue("symbol")
+	var query types.IndicatorsQuery
+	if err := utils.DecodeQuery(r.URL.Query(), &query); err != nil {
+		return err
+	}
+	if err := utils.Validate(r.Context(), query); err != nil {
+		return err
+	}
+	if query.From != "" && query.To != "" && query.From > query.To {
+		return utils.NewValidationError("from", "from must not be after to")
+	}
+
+	var specs []indicators.Spec
+	for _, name := range query.Names {
+		spec, err := indicators.ParseSpec(name)
+		if err != nil {
+			return utils.NewValidationError("names", err.Error())
 		}
-		products = append(products, product)
+		specs = append(specs, spec)
 	}
 
-	if err := cursor.Err(); err != nil {
-		return fmt.Errorf("cursor error: %v", err)
+	if err := checkProductExists(r, store, symbol); err != nil {
+		return err
 	}
 
-	helpers.WriteJSON(w, http.StatusOK, products, nil, "")
+	filter := bson.M{"symbol": symbol}
+	if query.To != "" {
+		filter["date"] = bson.M{"$lte": query.To}
+	}
+	bars, err := findBars(r, store, filter, options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
+	if err != nil {
+		return err
+	}
+
+	actions, err := corporate.Load(r.Context(), store, symbol)
+	if err != nil {
+		return err
+	}
+
+	series := indicators.Compute(corporate.AdjustBars(bars, actions, true), specs)
+	if query.From != "" {
+		for key, values := range series {
+			first := sort.Search(len(values), func(i int) bool { return values[i].Date >= query.From })
+			series[key] = values[first:]
+		}
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, types.Indicators{Symbol: symbol, Series: series}, nil, "")
 	return nil
 }
+
+// GetCorporateActions lists the splits and dividends of a symbol, oldest first
+func GetCorporateActions(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	symbol := r.PathValue("symbol")
+	if err := checkProductExists(r, store, symbol); err != nil {
+		return err
+	}
+
+	actions, err := corporate.Load(r.Context(), store, symbol)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, actions, nil, "")
+	return nil
+}
+
+// GetStockQuality reports the gaps, outliers and staleness of the stored bars of a symbol, by
+// default from its first stored bar to the latest the provider can have
+func GetStockQuality(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	symbol := r.PathValue("symbol")
+	var query types.QualityQuery
+	if err := utils.DecodeQuery(r.URL.Query(), &query); err != nil {
+		return err
+	}
+	if err := utils.Validate(r.Context(), query); err != nil {
+		return err
+	}
+	if query.From != "" && query.To != "" && query.From > query.To {
+		return utils.NewValidationError("from", "from must not be after to")
+	}
+
+	if err := checkProductExists(r, store, symbol); err != nil {
+		return err
+	}
+
+	report, err := quality.CheckStore(r.Context(), store, symbol, query.From, query.To, time.Now())
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, report, nil, "")
+	return nil
+}
+
+// recentBars returns up to limit of the latest bars of a symbol, oldest first
+func recentBars(r *http.Request, store db.MongoStorage, symbol string, limit int64) ([]types.Bar, error) {
+	bars, err := findBars(r, store, bson.M{"symbol": symbol},
+		options.Find().SetSort(bson.D{{Key: "date", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(bars)
+	return bars, nil
+}
+
+func checkProductExists(r *http.Request, store db.MongoStorage, symbol string) error {
+	count, err := store.Collection("products").CountDocuments(r.Context(), bson.M{"symbol": symbol})
+	if err != nil {
+		return fmt.Errorf("error checking product: %w", err)
+	}
+	if count == 0 {
+		return utils.NewNotFoundError("product")
+	}
+	return nil
+}
+
+// adjustPage adjusts a page of daily bars for splits and dividends. A dividend after the page
+// is scaled by the close before its ex-date, which is fetched along since it is on a later page.
+func adjustPage(r *http.Request, store db.MongoStorage, symbol string, bars []types.Bar) ([]types.Bar, error) {
+	if len(bars) == 0 {
+		return bars, nil
+	}
+	actions, err := corporate.Load(r.Context(), store, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	last := bars[len(bars)-1].Date
+	var closesBefore []string
+	for _, action := range actions {
+		if action.Type != types.ActionDividend || action.ExDate <= last {
+			continue
+		}
+		if exDate, err := time.Parse("2006-01-02", action.ExDate); err == nil {
+			closesBefore = append(closesBefore, marketdata.PreviousTradingDay(exDate))
+		}
+	}
+
+	withCloses := bars
+	if len(closesBefore) > 0 {
+		closes, err := findBars(r, store,
+			bson.M{"symbol": symbol, "date": bson.M{"$in": closesBefore, "$gt": last}},
+			options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
+		if err != nil {
+			return nil, err
+		}
+		withCloses = append(append([]types.Bar{}, bars...), closes...)
+	}
+	return corporate.AdjustBars(withCloses, actions, true)[:len(bars)], nil
+}
+
+func findBars(r *http.Request, store db.MongoStorage, filter bson.M, opts *options.FindOptions) ([]types.Bar, error) {
+	cursor, err := store.Collection("stocks").Find(r.Context(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving stocks: %w", err)
+	}
+	defer cursor.Close(r.Context())
+
+	var bars []types.Bar
+	for cursor.Next(r.Context()) {
+		var stock types.Stock
+		if err := cursor.Decode(&stock); err != nil {
+			return nil, fmt.Errorf("error decoding stocks: %w", err)
+		}
+		bars = append(bars, stock.Bar())
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %w", err)
+	}
+	return bars, nil
+}
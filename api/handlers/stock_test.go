@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/utils"
+)
+
+// The pages are bounded, the offset of the last one fits in an int
+func TestGetStockHistoryRejectsHugePages(t *testing.T) {
+	tests := []string{"page=9223372036854775807", "page=100001", "page=0", "page_size=501"}
+	for _, query := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/stocks/AAPL/history?"+query, nil)
+		r.SetPathValue("symbol", "AAPL")
+		err := GetStockHistory(httptest.NewRecorder(), r, db.MongoStorage{})
+
+		var validationErr *utils.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s: error = %v, want a validation error", query, err)
+		}
+	}
+}
@@ -2,24 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/corporate"
 	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/risk"
 	"github.com/arcedo/financial-ai-backend/types"
 	"github.com/arcedo/financial-ai-backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func CreateTransaction(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
 	// Parse the request body
 	var transaction types.TransactionPublic
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
 		return err
 	}
 	transaction.Type = utils.SanitizeString(transaction.Type)
@@ -30,29 +34,22 @@ func CreateTransaction(w http.ResponseWriter, r *http.Request, store db.MongoSto
 		return fmt.Errorf("unable to retrieve user ID from context")
 	}
 
-	// Validate transaction data
-	if err := types.ValidateTransaction(transaction); err != nil {
-		return err
+	// Only buy/sell transactions refer to a product and have shares. Dividends are credited by
+	// the corporate actions sync only, so they cannot be counted twice.
+	if transaction.Type != "buy" && transaction.Type != "sell" {
+		transaction.Symbol = ""
+		transaction.Quantity = nil
 	}
 
-	// Check if product exists if type is buy/sell
-	if transaction.Type == "buy" || transaction.Type == "sell" {
-		productsColl := store.Collection("products")
-		var product types.Product
-		if err := productsColl.FindOne(r.Context(), bson.M{"symbol": transaction.Symbol}).Decode(&product); err != nil {
-			if err == mongo.ErrNoDocuments {
-				return fmt.Errorf("no product found with that symbol")
-			}
-			return err
-		}
-	} else {
-		transaction.Symbol = ""
+	// Validate transaction data, including that the product exists
+	if err := utils.Validate(r.Context(), transaction); err != nil {
+		return err
 	}
 
 	// Parse date
 	dateFormated, err := time.Parse("2006-01-02", transaction.Date)
 	if err != nil {
-		return fmt.Errorf("invalid date format, expected YYYY-MM-DD")
+		return utils.NewValidationError("date", "invalid date format, expected YYYY-MM-DD")
 	}
 
 	// Prepare the transaction to insert
@@ -64,18 +61,70 @@ func CreateTransaction(w http.ResponseWriter, r *http.Request, store db.MongoSto
 		Date:   dateFormated.Format("2006-01-02"),
 	}
 
+	// Trades without a quantity are assumed filled at the close of the day, so holdings can
+	// still follow the splits. Without a stored price the shares stay unknown.
+	if transaction.Quantity != nil {
+		newTransaction.Quantity = *transaction.Quantity
+	} else if transaction.Type == "buy" || transaction.Type == "sell" {
+		var stock types.Stock
+		err := store.Collection("stocks").FindOne(r.Context(),
+			bson.M{"symbol": transaction.Symbol, "date": bson.M{"$lte": newTransaction.Date}},
+			options.FindOne().SetSort(bson.D{{Key: "date", Value: -1}}),
+		).Decode(&stock)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return fmt.Errorf("failed to fetch the price of the transaction: %w", err)
+		}
+		if err == nil && stock.ClosePrice > 0 {
+			quantity := transaction.Amount / float64(stock.ClosePrice)
+			newTransaction.Quantity = quantity
+			transaction.Quantity = &quantity
+		}
+	}
+
 	// Insert into database
 	transactionsColl := store.Collection("transactions")
 	_, err = transactionsColl.InsertOne(r.Context(), newTransaction)
 	if err != nil {
-		return fmt.Errorf("failed to insert transaction: %v", err)
+		return fmt.Errorf("failed to insert transaction: %w", err)
+	}
+
+	receipt := types.TransactionReceipt{
+		Type:     transaction.Type,
+		Amount:   transaction.Amount,
+		Quantity: transaction.Quantity,
+		Date:     newTransaction.Date,
+		Symbol:   transaction.Symbol,
+	}
+	// Buyers of leveraged and inverse funds get the disclosure along with the receipt
+	if transaction.Type == "buy" {
+		var product types.Product
+		err := store.Collection("products").FindOne(r.Context(), bson.M{"symbol": transaction.Symbol}).Decode(&product)
+		if err != nil {
+			return fmt.Errorf("failed to fetch the product of the transaction: %w", err)
+		}
+		if risk.DecayProne(product) {
+			receipt.Warnings = append(receipt.Warnings, riskWarning(r, store, product, ""))
+		}
 	}
 
 	// Return success
-	helpers.WriteJSON(w, http.StatusCreated, transaction, nil, "transaction created successfully")
+	helpers.WriteJSON(w, http.StatusCreated, receipt, nil, "transaction created successfully")
 	return nil
 }
 
+// riskWarning returns the disclosure of a decay prone product, with its decay against the
+// underlying since from (over the last year when empty) if the prices allow it
+func riskWarning(r *http.Request, store db.MongoStorage, product types.Product, from string) types.RiskWarning {
+	decay, err := risk.Analyze(r.Context(), store, product, from, "")
+	if err != nil {
+		if !errors.Is(err, risk.ErrNoUnderlying) && !errors.Is(err, risk.ErrNotEnoughData) {
+			utils.LoggerFrom(r.Context()).Warn("error analyzing decay", "symbol", product.Symbol, "error", err)
+		}
+		decay = nil
+	}
+	return risk.Warning(product, decay)
+}
+
 func GetTransactions(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
 	// Retrieve user ID from context
 	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
@@ -87,31 +136,93 @@ func GetTransactions(w http.ResponseWriter, r *http.Request, store db.MongoStora
 	transactionsColl := store.Collection("transactions")
 	cursor, err := transactionsColl.Find(r.Context(), bson.M{"user_id": userID})
 	if err != nil {
-		return fmt.Errorf("failed to fetch transactions: %v", err)
+		return fmt.Errorf("failed to fetch transactions: %w", err)
 	}
 	defer cursor.Close(r.Context())
 
 	var transactions []types.TransactionPublic
 	if err := cursor.All(r.Context(), &transactions); err != nil {
-		return fmt.Errorf("failed to decode transactions: %v", err)
+		return fmt.Errorf("failed to decode transactions: %w", err)
 	}
 
 	helpers.WriteJSON(w, http.StatusOK, transactions, nil, "")
 	return nil
 }
 
+// GetHoldings returns the positions of the current user in today's shares, with the splits
+// since each trade applied, the dividends received and how long they have been held
+func GetHoldings(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
+	if !ok {
+		return fmt.Errorf("unable to retrieve user ID from context")
+	}
+
+	transactionsColl := store.Collection("transactions")
+	cursor, err := transactionsColl.Find(r.Context(), bson.M{
+		"user_id": userID,
+		"type":    bson.M{"$in": bson.A{"buy", "sell", "dividend"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	defer cursor.Close(r.Context())
+
+	var transactions []types.Transaction
+	if err := cursor.All(r.Context(), &transactions); err != nil {
+		return fmt.Errorf("failed to decode transactions: %w", err)
+	}
+
+	var symbols []string
+	for _, t := range transactions {
+		if !slices.Contains(symbols, t.Symbol) {
+			symbols = append(symbols, t.Symbol)
+		}
+	}
+	actions := []types.CorporateAction{}
+	if len(symbols) > 0 {
+		if actions, err = corporate.Load(r.Context(), store, symbols...); err != nil {
+			return err
+		}
+	}
+
+	holdings := corporate.Holdings(transactions, actions)
+	if len(symbols) > 0 {
+		cursor, err := store.Collection("products").Find(r.Context(), bson.M{"symbol": bson.M{"$in": symbols}})
+		if err != nil {
+			return fmt.Errorf("error retrieving products: %w", err)
+		}
+		var products []types.Product
+		if err := cursor.All(r.Context(), &products); err != nil {
+			return fmt.Errorf("error decoding products: %w", err)
+		}
+
+		// Open positions in leveraged and inverse funds carry their decay since they were opened
+		for i, holding := range holdings {
+			for _, product := range products {
+				if product.Symbol == holding.Symbol && holding.OpenedAt != "" && risk.DecayProne(product) {
+					warning := riskWarning(r, store, product, holding.OpenedAt)
+					holdings[i].Risk = &warning
+				}
+			}
+		}
+	}
+
+	helpers.WriteJSON(w, http.StatusOK, holdings, nil, "")
+	return nil
+}
+
 func GetAllTransactions(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
 	// Fetch all transactions
 	transactionsColl := store.Collection("transactions")
 	cursor, err := transactionsColl.Find(r.Context(), bson.D{})
 	if err != nil {
-		return fmt.Errorf("failed to fetch transactions: %v", err)
+		return fmt.Errorf("failed to fetch transactions: %w", err)
 	}
 	defer cursor.Close(r.Context())
 
 	var transactions []types.Transaction
 	if err := cursor.All(r.Context(), &transactions); err != nil {
-		return fmt.Errorf("failed to decode transactions: %v", err)
+		return fmt.Errorf("failed to decode transactions: %w", err)
 	}
 
 	if len(transactions) == 0 {
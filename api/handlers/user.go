@@ -1,14 +1,14 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/corporate"
 	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/indicators"
 	"github.com/arcedo/financial-ai-backend/requests"
 	"github.com/arcedo/financial-ai-backend/types"
 	"github.com/arcedo/financial-ai-backend/utils"
@@ -17,35 +17,41 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func Login(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
-	var user types.User
+func Login(keys *utils.KeyManager) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		return login(w, r, store, keys)
+	}
+}
+
+func login(w http.ResponseWriter, r *http.Request, store db.MongoStorage, keys *utils.KeyManager) error {
+	var user types.Credentials
 	// Decode the incoming request body to extract the user credentials (e.g., username/password)
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		return err
 	}
 
-	if err := user.ValidateUserLogin(); err != nil {
+	if err := utils.Validate(r.Context(), user); err != nil {
 		return err
 	}
 
 	userCollection := store.Collection("users")
 	var foundUser types.User
 
-	err := userCollection.FindOne(context.Background(), bson.D{
+	err := userCollection.FindOne(r.Context(), bson.D{
 		{Key: "email", Value: user.Email},
 	}).Decode(&foundUser)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return fmt.Errorf("invalid credentials")
+			return utils.ErrInvalidCredentials
 		}
-		return fmt.Errorf("error searching for user: %v", err)
+		return fmt.Errorf("error searching for user: %w", err)
 	}
 	if passOk := utils.CheckPasswordHash(foundUser.Password, user.Password); passOk == false {
-		return fmt.Errorf("invalid credentials")
+		return utils.ErrInvalidCredentials
 	}
 
-	token, err := utils.GenerateJWT([]byte(os.Getenv("SECRET")), foundUser.ID)
+	token, err := startSession(r, store, keys, foundUser.ID)
 	if err != nil {
 		return err
 	}
@@ -56,40 +62,46 @@ func Login(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error
 		Email:    foundUser.Email,
 	}
 
-	helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{
-		"token": token,
-		"user":  publicUser,
+	helpers.WriteJSON(w, http.StatusOK, types.AuthResponse{
+		Token: token,
+		User:  publicUser,
 	}, nil, "")
 	return nil
 }
 
-func CreateUser(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+func CreateUser(keys *utils.KeyManager) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		return createUser(w, r, store, keys)
+	}
+}
+
+func createUser(w http.ResponseWriter, r *http.Request, store db.MongoStorage, keys *utils.KeyManager) error {
 	var newUser = types.NewUser{}
 	if err := json.NewDecoder(r.Body).Decode(&newUser); err != nil {
 		return err
 	}
 
-	if err := newUser.ValidateUserCreation(); err != nil {
+	if err := utils.Validate(r.Context(), newUser); err != nil {
 		return err
 	}
 
 	// Check if user with the same email already exists
 	userCollection := store.Collection("users")
 	var existingUser types.PublicUser
-	err := userCollection.FindOne(context.Background(), bson.D{
+	err := userCollection.FindOne(r.Context(), bson.D{
 		{Key: "email", Value: newUser.Email},
 	}).Decode(&existingUser)
 	if err != nil && err != mongo.ErrNoDocuments {
-		return fmt.Errorf("error checking for existing user: %v", err)
+		return fmt.Errorf("error checking for existing user: %w", err)
 	}
 	if existingUser.Email != "" {
-		return fmt.Errorf("user with this email already exists")
+		return utils.NewConflictError("user with this email already exists")
 	}
 
 	// Hash the password before storing it
 	newUser.Password, err = utils.HashPassword(newUser.Password)
 	if err != nil {
-		return fmt.Errorf("error hashing password: %v", err)
+		return fmt.Errorf("error hashing password: %w", err)
 	}
 
 	newUser.Name = utils.SanitizeString(newUser.Name)
@@ -97,9 +109,13 @@ func CreateUser(w http.ResponseWriter, r *http.Request, store db.MongoStorage) e
 	newUser.Email = utils.SanitizeString(newUser.Email)
 
 	// Insert the new user into the database
-	res, err := userCollection.InsertOne(context.Background(), newUser)
+	res, err := userCollection.InsertOne(r.Context(), newUser)
+	if mongo.IsDuplicateKeyError(err) {
+		// Registered concurrently since the check above
+		return utils.NewConflictError("user with this email already exists")
+	}
 	if err != nil {
-		return fmt.Errorf("error inserting new user: %v", err)
+		return fmt.Errorf("error inserting new user: %w", err)
 	}
 
 	// Retrieve the inserted ID and verify it's an ObjectID
@@ -108,10 +124,10 @@ func CreateUser(w http.ResponseWriter, r *http.Request, store db.MongoStorage) e
 		return fmt.Errorf("unexpected ID type")
 	}
 
-	// Generate the JWT token using the user ID
-	token, err := utils.GenerateJWT([]byte(os.Getenv("SECRET")), insertedID)
+	// Open a session and generate the JWT token bound to it
+	token, err := startSession(r, store, keys, insertedID)
 	if err != nil {
-		return fmt.Errorf("error generating token: %v", err)
+		return err
 	}
 
 	publicUser := types.PublicUser{
@@ -121,9 +137,9 @@ func CreateUser(w http.ResponseWriter, r *http.Request, store db.MongoStorage) e
 	}
 
 	// Return the response with the generated token
-	helpers.WriteJSON(w, http.StatusCreated, map[string]interface{}{
-		"token": token,
-		"user":  publicUser,
+	helpers.WriteJSON(w, http.StatusCreated, types.AuthResponse{
+		Token: token,
+		User:  publicUser,
 	}, nil, "user created successfully")
 	return nil
 }
@@ -137,14 +153,14 @@ func GetUser(w http.ResponseWriter, r *http.Request, store db.MongoStorage) erro
 	// Now you can use userID directly in the query
 	userCollection := store.Collection("users")
 	var user types.PublicUser
-	err := userCollection.FindOne(context.Background(), bson.D{
+	err := userCollection.FindOne(r.Context(), bson.D{
 		{Key: "_id", Value: userID},
 	}).Decode(&user)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return fmt.Errorf("user not found")
+			return utils.NewNotFoundError("user")
 		}
-		return fmt.Errorf("error retrieving user: %v", err)
+		return fmt.Errorf("error retrieving user: %w", err)
 	}
 
 	helpers.WriteJSON(w, http.StatusOK, user, nil, "")
@@ -153,30 +169,36 @@ func GetUser(w http.ResponseWriter, r *http.Request, store db.MongoStorage) erro
 
 func GetAllUsers(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
 	userCollection := store.Collection("users")
-	cursor, err := userCollection.Find(context.Background(), bson.D{})
+	cursor, err := userCollection.Find(r.Context(), bson.D{})
 	if err != nil {
-		return fmt.Errorf("error retrieving users: %v", err)
+		return fmt.Errorf("error retrieving users: %w", err)
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(r.Context())
 
 	var users []types.User
-	for cursor.Next(context.Background()) {
+	for cursor.Next(r.Context()) {
 		var user types.User
 		if err := cursor.Decode(&user); err != nil {
-			return fmt.Errorf("error decoding user: %v", err)
+			return fmt.Errorf("error decoding user: %w", err)
 		}
 		users = append(users, user)
 	}
 
 	if err := cursor.Err(); err != nil {
-		return fmt.Errorf("cursor error: %v", err)
+		return fmt.Errorf("cursor error: %w", err)
 	}
 
 	helpers.WriteJSON(w, http.StatusOK, users, nil, "")
 	return nil
 }
 
-func UpdateUserProfile(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+func UpdateUserProfile(llm *requests.LLMClient) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		return updateUserProfile(w, r, store, llm)
+	}
+}
+
+func updateUserProfile(w http.ResponseWriter, r *http.Request, store db.MongoStorage, llm *requests.LLMClient) error {
 	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
 	if !ok {
 		return fmt.Errorf("unable to retrieve user ID from context")
@@ -184,23 +206,23 @@ func UpdateUserProfile(w http.ResponseWriter, r *http.Request, store db.MongoSto
 
 	userCollection := store.Collection("users")
 	var userData types.Insights
-	err := userCollection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&userData.User)
+	err := userCollection.FindOne(r.Context(), bson.M{"_id": userID}).Decode(&userData.User)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return fmt.Errorf("user not found")
+			return utils.NewNotFoundError("user")
 		}
-		return fmt.Errorf("error retrieving user: %v", err)
+		return fmt.Errorf("error retrieving user: %w", err)
 	}
 
 	transactionsColl := store.Collection("transactions")
 	cursor, err := transactionsColl.Find(r.Context(), bson.M{"user_id": userID})
 	if err != nil {
-		return fmt.Errorf("failed to fetch transactions: %v", err)
+		return fmt.Errorf("failed to fetch transactions: %w", err)
 	}
 	defer cursor.Close(r.Context())
 
 	if err := cursor.All(r.Context(), &userData.Transactions); err != nil {
-		return fmt.Errorf("failed to decode transactions: %v", err)
+		return fmt.Errorf("failed to decode transactions: %w", err)
 	}
 
 	// Calculate position summary
@@ -215,66 +237,103 @@ func UpdateUserProfile(w http.ResponseWriter, r *http.Request, store db.MongoSto
 			position.TotalSaves += t.Amount
 		case "entry":
 			position.TotalEntry += t.Amount
+		case "dividend":
+			position.TotalDividends += t.Amount
 		}
 	}
 	position.NetMarket = position.TotalBuys - position.TotalSells
-	position.NetBalance = position.NetMarket + position.TotalSaves + position.TotalEntry
+	position.NetBalance = position.NetMarket + position.TotalSaves + position.TotalEntry + position.TotalDividends
 	userData.Position = position
 
 	// Send to LLM
-	newProfile, err := requests.RequestUpdateUserProfile(userData)
+	newProfile, err := llm.RequestUpdateUserProfile(r.Context(), userData)
 	if err != nil {
-		return fmt.Errorf("failed to update LLM profile: %v", err)
+		return fmt.Errorf("failed to update LLM profile: %w", err)
 	}
 
 	helpers.WriteJSON(w, http.StatusOK, newProfile, nil, "User profile updated successfully")
 	return nil
 }
 
-func GetRecommendations(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+func GetRecommendations(llm *requests.LLMClient) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		return getRecommendations(w, r, store, llm)
+	}
+}
+
+func getRecommendations(w http.ResponseWriter, r *http.Request, store db.MongoStorage, llm *requests.LLMClient) error {
 	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
 	if !ok {
 		return fmt.Errorf("unable to retrieve user ID from context")
 	}
 
-	recommendations, err := requests.GetRecommendations(userID.Hex())
+	recommendations, err := llm.GetRecommendations(r.Context(), userID.Hex())
 	if err != nil {
-		return fmt.Errorf("failed to get recommendations: %v", err)
+		return fmt.Errorf("failed to get recommendations: %w", err)
 	}
 
 	helpers.WriteJSON(w, http.StatusOK, recommendations, nil, "")
 	return nil
 }
 
-func GetAdvice(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+func GetAdvice(llm *requests.LLMClient) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		return getAdvice(w, r, store, llm)
+	}
+}
+
+func getAdvice(w http.ResponseWriter, r *http.Request, store db.MongoStorage, llm *requests.LLMClient) error {
 	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
 	if !ok {
 		return fmt.Errorf("unable to retrieve user ID from context")
 	}
 
-	advice, err := requests.GetAdvice(userID.Hex())
+	advice, err := llm.GetAdvice(r.Context(), userID.Hex())
 	if err != nil {
-		return fmt.Errorf("failed to get advice: %v", err)
+		return fmt.Errorf("failed to get advice: %w", err)
 	}
 
 	helpers.WriteJSON(w, http.StatusOK, advice, nil, "")
 	return nil
 }
 
-func GetAssetRecommendation(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+func GetAssetRecommendation(llm *requests.LLMClient) helpers.ApiFunc {
+	return func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error {
+		return getAssetRecommendation(w, r, store, llm)
+	}
+}
+
+func getAssetRecommendation(w http.ResponseWriter, r *http.Request, store db.MongoStorage, llm *requests.LLMClient) error {
 	userID, ok := r.Context().Value("userID").(primitive.ObjectID)
 	if !ok {
 		return fmt.Errorf("unable to retrieve user ID from context")
 	}
 
-	symbol, err := utils.GetPathParam(r.URL.Path, 1)
-	if err != nil {
-		return fmt.Errorf("unable to retrieve symbol from URL: %v", err)
+	symbol := r.PathValue("symbol")
+	if symbol == "" {
+		return utils.NewValidationError("symbol", "missing symbol in URL")
+	}
+
+	var snapshot *types.IndicatorSnapshot
+	if llm.SendsIndicators() {
+		// Enough bars for the longest default indicator, the 50 day SMA, and MACD to settle
+		bars, err := recentBars(r, store, symbol, 300)
+		if err != nil {
+			return err
+		}
+		if len(bars) > 0 {
+			actions, err := corporate.Load(r.Context(), store, symbol)
+			if err != nil {
+				return err
+			}
+			latest := indicators.Snapshot(symbol, corporate.AdjustBars(bars, actions, true))
+			snapshot = &latest
+		}
 	}
 
-	recommendations, err := requests.GetAssetRecommendation(userID.Hex(), symbol)
+	recommendations, err := llm.GetAssetRecommendation(r.Context(), userID.Hex(), symbol, snapshot)
 	if err != nil {
-		return fmt.Errorf("failed to get recommendations: %v", err)
+		return fmt.Errorf("failed to get recommendations: %w", err)
 	}
 
 	helpers.WriteJSON(w, http.StatusOK, recommendations, nil, "")
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RegisterValidationRules adds the validation rules that need to query the database
+func RegisterValidationRules(store db.MongoStorage) {
+	utils.RegisterRule("symbol", func(ctx context.Context, value reflect.Value, _ string) (string, error) {
+		count, err := store.Collection("products").CountDocuments(ctx, bson.M{
+			"symbol":   value.String(),
+			"archived": bson.M{"$ne": true},
+		})
+		if err != nil {
+			return "", fmt.Errorf("error checking product: %w", err)
+		}
+		if count == 0 {
+			return "must be the symbol of an existing product", nil
+		}
+		return "", nil
+	})
+}
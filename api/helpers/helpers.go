@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
 
 	db "github.com/arcedo/financial-ai-backend/database"
@@ -11,9 +12,10 @@ import (
 type ApiFunc func(w http.ResponseWriter, r *http.Request, store db.MongoStorage) error
 
 type APIResponse struct {
-	Data    any    `json:"data"`
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	Data    any                `json:"data"`
+	Error   string             `json:"error"`
+	Message string             `json:"message"`
+	Details []utils.FieldError `json:"details,omitempty"`
 }
 
 func WriteJSON(w http.ResponseWriter, status int, data any, apiErr *utils.APIError, message string) {
@@ -31,6 +33,7 @@ func WriteJSON(w http.ResponseWriter, status int, data any, apiErr *utils.APIErr
 	if apiErr != nil {
 		response.Error = apiErr.Code
 		response.Message = apiErr.Message
+		response.Details = apiErr.Details
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -39,19 +42,24 @@ func WriteJSON(w http.ResponseWriter, status int, data any, apiErr *utils.APIErr
 	}
 }
 
-func MakeHTTPHandleFunc(fn ApiFunc, s db.MongoStorage, allowedMethods []string) http.HandlerFunc {
+// MakeHTTPHandleFunc adapts fn to an http.HandlerFunc. The allowed methods are part of the
+// route pattern, so the router rejects the other ones before fn is called.
+func MakeHTTPHandleFunc(fn ApiFunc, s db.MongoStorage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check if the request method is allowed
-		if err := utils.ValidateMethods(r, allowedMethods); err != nil {
-			errValue := utils.ErrorMap[err]
-			WriteJSON(w, http.StatusMethodNotAllowed, nil, &errValue, "")
-			return
-		}
-
 		// Call the handler function and handle errors
 		if err := fn(w, r, s); err != nil {
 			// Map the error to an appropriate HTTP status code and API error
 			apiErr, statusCode := utils.MapErrorToAPIError(err)
+			// The client only gets the generic message, keep the real cause in our logs
+			level := slog.LevelWarn
+			if statusCode >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			utils.LoggerFrom(r.Context()).Log(r.Context(), level, "handler failed",
+				"status", statusCode,
+				"code", apiErr.Code,
+				"error", err,
+			)
 			WriteJSON(w, statusCode, nil, apiErr, "")
 			return
 		}
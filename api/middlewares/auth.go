@@ -3,6 +3,8 @@ package middlewares
 import (
 	"context"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/arcedo/financial-ai-backend/api/helpers"
 	db "github.com/arcedo/financial-ai-backend/database"
@@ -13,22 +15,23 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func JWTAuthMiddleware(secretKey []byte, store db.MongoStorage) func(http.HandlerFunc) http.HandlerFunc {
+func JWTAuthMiddleware(keys *utils.KeyManager, store db.MongoStorage) func(http.HandlerFunc) http.HandlerFunc {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
-			tokenString := r.Header.Get("Authorization")
+			// Both the raw token and the standard "Bearer <token>" form are accepted
+			tokenString := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
 			if tokenString == "" {
 				errValue := utils.ErrorMap[utils.ErrUnauthorized]
 				helpers.WriteJSON(w, http.StatusUnauthorized, nil, &errValue, "")
 				return
 			}
 
-			claims, err := utils.ValidateJWT(secretKey, tokenString)
+			claims, err := utils.ValidateJWT(keys, tokenString)
 			if err != nil {
-				helpers.WriteJSON(w, http.StatusUnauthorized, nil, &utils.APIError{
-					Code:    "UNAUTHORIZED",
-					Message: err.Error(),
-				}, "")
+				// The cause, e.g. an unknown kid, helps debugging but is no business of the client
+				utils.LoggerFrom(r.Context()).Info("rejected token", "error", err)
+				errValue := utils.ErrorMap[utils.ErrUnauthorized]
+				helpers.WriteJSON(w, http.StatusUnauthorized, nil, &errValue, "")
 				return
 			}
 
@@ -45,7 +48,7 @@ func JWTAuthMiddleware(secretKey []byte, store db.MongoStorage) func(http.Handle
 			// Check if the user exists in the database
 			userCollection := store.Collection("users")
 			var foundUser types.User
-			err = userCollection.FindOne(context.Background(), bson.D{
+			err = userCollection.FindOne(r.Context(), bson.D{
 				{Key: "_id", Value: userID},
 			}).Decode(&foundUser)
 
@@ -57,6 +60,7 @@ func JWTAuthMiddleware(secretKey []byte, store db.MongoStorage) func(http.Handle
 					}, "")
 					return
 				}
+				utils.LoggerFrom(r.Context()).Error("error searching for user", "error", err)
 				helpers.WriteJSON(w, http.StatusInternalServerError, nil, &utils.APIError{
 					Code:    "INTERNAL_SERVER_ERROR",
 					Message: "error searching for user",
@@ -65,7 +69,78 @@ func JWTAuthMiddleware(secretKey []byte, store db.MongoStorage) func(http.Handle
 			}
 
 			ctx := context.WithValue(r.Context(), "userID", userID)
+			ctx = context.WithValue(ctx, "userRole", foundUser.Role)
+			if info := utils.RequestInfoFrom(ctx); info != nil {
+				info.UserID = userID.Hex()
+			}
+
+			// Tokens issued before sessions were tracked carry no sid and stay valid until they expire
+			if claims.SessionID != "" {
+				sessionID, apiErr, status := checkSession(r, store, claims.SessionID, userID)
+				if apiErr != nil {
+					helpers.WriteJSON(w, status, nil, apiErr, "")
+					return
+				}
+				ctx = context.WithValue(ctx, "sessionID", sessionID)
+			}
+
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 	}
 }
+
+// RequireAdmin only lets administrators through. It runs inside JWTAuthMiddleware, which puts
+// the role of the user in the context.
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if role, _ := r.Context().Value("userRole").(string); role != types.RoleAdmin {
+			errValue := utils.ErrorMap[utils.ErrForbidden]
+			helpers.WriteJSON(w, http.StatusForbidden, nil, &errValue, "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// lastSeenResolution limits how often a session's last-seen time is written
+const lastSeenResolution = time.Minute
+
+// checkSession makes sure the session the token belongs to has not been revoked
+func checkSession(r *http.Request, store db.MongoStorage, sessionHex string, userID primitive.ObjectID) (primitive.ObjectID, *utils.APIError, int) {
+	sessionID, err := primitive.ObjectIDFromHex(sessionHex)
+	if err != nil {
+		return primitive.NilObjectID, &utils.APIError{
+			Code:    "INVALID_OBJECTID",
+			Message: "invalid session ID in token",
+		}, http.StatusUnauthorized
+	}
+
+	sessionsColl := store.Collection("sessions")
+	var session types.Session
+	err = sessionsColl.FindOne(r.Context(), bson.M{"_id": sessionID, "user_id": userID}).Decode(&session)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return primitive.NilObjectID, &utils.APIError{
+			Code:    "INTERNAL_SERVER_ERROR",
+			Message: "error searching for session",
+		}, http.StatusInternalServerError
+	}
+	if err == mongo.ErrNoDocuments || session.RevokedAt != nil {
+		return primitive.NilObjectID, &utils.APIError{
+			Code:    "SESSION_REVOKED",
+			Message: "this session has been signed out",
+		}, http.StatusUnauthorized
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		_, err := sessionsColl.UpdateOne(r.Context(), bson.M{"_id": sessionID}, bson.M{"$set": bson.M{
+			"last_seen_at": now,
+			"ip":           utils.ClientIP(r),
+		}})
+		if err != nil {
+			utils.LoggerFrom(r.Context()).Warn("error updating session last seen", "session_id", sessionHex, "error", err)
+		}
+	}
+
+	return sessionID, nil, http.StatusOK
+}
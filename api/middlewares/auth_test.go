@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/config"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestJWTAuthMiddlewareRejectsInvalidTokens(t *testing.T) {
+	newKeys := func() *utils.KeyManager {
+		keys, err := utils.NewKeyManager(config.JWTConfig{Algorithm: utils.AlgorithmEdDSA})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	keys, otherKeys := newKeys(), newKeys()
+	foreign, err := utils.GenerateJWT(otherKeys, primitive.NewObjectID(), primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"missing", ""},
+		{"garbage", "Bearer not-a-jwt"},
+		{"signed with an unknown key", "Bearer " + foreign},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/me", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", tt.token)
+			}
+			w := httptest.NewRecorder()
+			next := func(w http.ResponseWriter, r *http.Request) { t.Error("the request must not reach the handler") }
+			JWTAuthMiddleware(keys, db.MongoStorage{})(next)(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+			var response helpers.APIResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			// The cause of the rejection stays in the logs
+			want := utils.ErrorMap[utils.ErrUnauthorized]
+			if response.Error != want.Code || response.Message != want.Message {
+				t.Errorf("response = %s: %q, want %s: %q", response.Error, response.Message, want.Code, want.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package middlewares
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/arcedo/financial-ai-backend/config"
+)
+
+// CORSPolicy decides which cross-origin requests the browser may make
+type CORSPolicy struct {
+	anyOrigin   bool
+	origins     []string
+	patterns    []originPattern
+	methods     []string
+	headers     []string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// originPattern matches origins like https://*.example.com, on any subdomain depth
+type originPattern struct {
+	prefix string
+	suffix string
+}
+
+func NewCORSPolicy(cfg config.CORSConfig) *CORSPolicy {
+	policy := &CORSPolicy{
+		methods:     upper(cfg.AllowedMethods),
+		headers:     lower(cfg.AllowedHeaders),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		policy.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			policy.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			policy.patterns = append(policy.patterns, originPattern{prefix: prefix, suffix: suffix})
+		default:
+			policy.origins = append(policy.origins, origin)
+		}
+	}
+	return policy
+}
+
+// Override returns a copy of the policy with the origins and credentials of a route override
+func (p *CORSPolicy) Override(route config.CORSRouteConfig) *CORSPolicy {
+	base := config.CORSConfig{
+		AllowedOrigins:   route.AllowedOrigins,
+		AllowCredentials: route.AllowCredentials,
+	}
+	override := NewCORSPolicy(base)
+	override.methods = p.methods
+	override.headers = p.headers
+	override.exposed = p.exposed
+	override.maxAge = p.maxAge
+	return override
+}
+
+func (p *CORSPolicy) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.anyOrigin || slices.Contains(p.origins, origin) {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if len(origin) > len(pattern.prefix)+len(pattern.suffix) &&
+			strings.HasPrefix(origin, pattern.prefix) && strings.HasSuffix(origin, pattern.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowOriginValue is "*" only when any origin is allowed without credentials, since browsers
+// reject the wildcard on credentialed requests; otherwise the request origin is echoed.
+func (p *CORSPolicy) allowOriginValue(origin string) string {
+	if p.anyOrigin && !p.credentials {
+		return "*"
+	}
+	return origin
+}
+
+// CORS applies the policy of the longest route override matching the path, or the default one.
+// Preflight requests are answered here and never reach the router.
+func CORS(policy *CORSPolicy, overrides map[string]*CORSPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy := routePolicy(policy, overrides, r.URL.Path)
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				policy.preflight(w, r)
+				return
+			}
+
+			policy.simple(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func routePolicy(policy *CORSPolicy, overrides map[string]*CORSPolicy, path string) *CORSPolicy {
+	matched := ""
+	for prefix, override := range overrides {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			matched, policy = prefix, override
+		}
+	}
+	return policy
+}
+
+func (p *CORSPolicy) preflight(w http.ResponseWriter, r *http.Request) {
+	headers := w.Header()
+	// Caches must key preflight answers on everything they depend on
+	headers.Add("Vary", "Origin")
+	headers.Add("Vary", "Access-Control-Request-Method")
+	headers.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requested := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+
+	// A rejected preflight gets no CORS headers, which makes the browser block the request
+	allowed := origin != "" && p.allowsOrigin(origin) && slices.Contains(p.methods, method)
+	for _, header := range requested {
+		allowed = allowed && slices.Contains(p.headers, header)
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	headers.Set("Access-Control-Allow-Origin", p.allowOriginValue(origin))
+	headers.Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+	if len(requested) > 0 {
+		headers.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if p.credentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if p.maxAge != "" {
+		headers.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *CORSPolicy) simple(w http.ResponseWriter, r *http.Request) {
+	headers := w.Header()
+	// The answer depends on the origin unless every origin gets the same wildcard
+	if p.allowOriginValue("") != "*" {
+		headers.Add("Vary", "Origin")
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || !p.allowsOrigin(origin) {
+		return
+	}
+
+	headers.Set("Access-Control-Allow-Origin", p.allowOriginValue(origin))
+	if p.credentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if p.exposed != "" {
+		headers.Set("Access-Control-Expose-Headers", p.exposed)
+	}
+}
+
+func parseHeaderList(raw string) []string {
+	var headers []string
+	for _, header := range strings.Split(raw, ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, strings.ToLower(header))
+		}
+	}
+	return headers
+}
+
+func upper(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = strings.ToUpper(value)
+	}
+	return result
+}
+
+func lower(values []string) []string {
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = strings.ToLower(value)
+	}
+	return result
+}
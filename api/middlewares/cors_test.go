@@ -0,0 +1,192 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/config"
+)
+
+var testCORS = config.CORSConfig{
+	AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+	AllowedMethods: []string{"GET", "POST"},
+	AllowedHeaders: []string{"Content-Type", "Authorization"},
+	ExposedHeaders: []string{"X-Request-ID"},
+	MaxAge:         10 * time.Minute,
+}
+
+// serveCORS runs a request through the CORS middleware and reports whether it reached next
+func serveCORS(policy *CORSPolicy, overrides map[string]*CORSPolicy, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true })
+	w := httptest.NewRecorder()
+	CORS(policy, overrides)(next).ServeHTTP(w, r)
+	return w, reached
+}
+
+func TestCORSSimpleRequests(t *testing.T) {
+	anyOrigin := testCORS
+	anyOrigin.AllowedOrigins = []string{"*"}
+	credentials := anyOrigin
+	credentials.AllowCredentials = true
+
+	tests := []struct {
+		name        string
+		cfg         config.CORSConfig
+		origin      string
+		wantOrigin  string
+		wantCreds   bool
+		wantVary    bool
+		wantExposed bool
+	}{
+		{"exact origin", testCORS, "https://app.example.com", "https://app.example.com", false, true, true},
+		{"origin case is ignored", testCORS, "https://APP.example.com", "https://APP.example.com", false, true, true},
+		{"pattern origin", testCORS, "https://eu.api.example.org", "https://eu.api.example.org", false, true, true},
+		{"pattern needs a subdomain", testCORS, "https://.example.org", "", false, true, false},
+		{"denied origin", testCORS, "https://evil.example.net", "", false, true, false},
+		{"same origin request", testCORS, "", "", false, true, false},
+		{"wildcard", anyOrigin, "https://anyone.test", "*", false, false, true},
+		{"wildcard with credentials echoes the origin", credentials, "https://anyone.test", "https://anyone.test", true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/products", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w, reached := serveCORS(NewCORSPolicy(tt.cfg), nil, r)
+
+			if !reached {
+				t.Error("simple requests must reach the handler")
+			}
+			headers := w.Header()
+			if got := headers.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := headers.Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCreds {
+				t.Errorf("Access-Control-Allow-Credentials set = %v, want %v", got, tt.wantCreds)
+			}
+			if got := slices.Contains(headers.Values("Vary"), "Origin"); got != tt.wantVary {
+				t.Errorf("Vary: Origin set = %v, want %v", got, tt.wantVary)
+			}
+			if got := headers.Get("Access-Control-Expose-Headers") != ""; got != tt.wantExposed {
+				t.Errorf("Access-Control-Expose-Headers set = %v, want %v", got, tt.wantExposed)
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin      string
+		method      string
+		headers     string
+		wantAllowed bool
+	}{
+		{"allowed", "https://app.example.com", "POST", "Content-Type, authorization", true},
+		{"allowed without headers", "https://app.example.com", "GET", "", true},
+		{"pattern origin", "https://eu.example.org", "post", "", true},
+		{"denied origin", "https://evil.example.net", "POST", "", false},
+		{"rejected method", "https://app.example.com", "DELETE", "", false},
+		{"rejected header", "https://app.example.com", "POST", "Content-Type, X-Secret", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("OPTIONS", "/api/v1/transactions", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			w, reached := serveCORS(NewCORSPolicy(testCORS), nil, r)
+
+			if reached {
+				t.Error("preflight requests must be answered by the middleware")
+			}
+			if w.Code != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusNoContent)
+			}
+			headers := w.Header()
+			for _, vary := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+				if !slices.Contains(headers.Values("Vary"), vary) {
+					t.Errorf("Vary is missing %s", vary)
+				}
+			}
+
+			if !tt.wantAllowed {
+				for _, name := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Max-Age"} {
+					if got := headers.Get(name); got != "" {
+						t.Errorf("rejected preflight got %s: %q", name, got)
+					}
+				}
+				return
+			}
+			if got := headers.Get("Access-Control-Allow-Origin"); got != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.origin)
+			}
+			if got := headers.Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+				t.Errorf("Access-Control-Allow-Methods = %q", got)
+			}
+			if got := headers.Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+			}
+			if tt.headers != "" && headers.Get("Access-Control-Allow-Headers") == "" {
+				t.Error("Access-Control-Allow-Headers missing")
+			}
+		})
+	}
+}
+
+func TestCORSPreflightWithoutMaxAge(t *testing.T) {
+	cfg := testCORS
+	cfg.MaxAge = 0
+	r := httptest.NewRequest("OPTIONS", "/api/v1/products", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	w, _ := serveCORS(NewCORSPolicy(cfg), nil, r)
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Access-Control-Max-Age = %q, want none", got)
+	}
+}
+
+func TestRoutePolicy(t *testing.T) {
+	base := NewCORSPolicy(testCORS)
+	stocks := base.Override(config.CORSRouteConfig{Path: "/api/v1/stocks", AllowedOrigins: []string{"*"}})
+	history := base.Override(config.CORSRouteConfig{Path: "/api/v1/stocks/history", AllowedOrigins: []string{"https://charts.test"}})
+	overrides := map[string]*CORSPolicy{
+		"/api/v1/stocks":         stocks,
+		"/api/v1/stocks/history": history,
+	}
+
+	tests := []struct {
+		path string
+		want *CORSPolicy
+	}{
+		{"/api/v1/products", base},
+		{"/api/v1/stocks", stocks},
+		{"/api/v1/stocks/AAPL/latest", stocks},
+		{"/api/v1/stocks/history/AAPL", history},
+	}
+	for _, tt := range tests {
+		if got := routePolicy(base, overrides, tt.path); got != tt.want {
+			t.Errorf("routePolicy(%s) picked the wrong policy", tt.path)
+		}
+	}
+
+	// Overrides keep the methods, headers and max age of the default policy
+	r := httptest.NewRequest("OPTIONS", "/api/v1/stocks/AAPL/latest", nil)
+	r.Header.Set("Origin", "https://anyone.test")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	r.Header.Set("Access-Control-Request-Headers", "Authorization")
+	w, _ := serveCORS(base, overrides, r)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+	}
+}
@@ -0,0 +1,257 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBody  = 1 << 20
+	idempotencyProcessingLock = time.Minute
+	idempotencySaveTimeout    = 5 * time.Second
+)
+
+// replayedHeaders are the response headers stored with the response and replayed with it
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Idempotency makes retries of a request sent with the same Idempotency-Key safe: the first
+// response is stored for ttl and replayed to the retries, reusing the key for a different
+// request is rejected. Keys are scoped to the client and the route, and requests without the
+// header are served as usual.
+func Idempotency(store db.MongoStorage, ttl time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return idempotency(mongoIdempotencyStore{store.Collection("idempotency_keys")}, ttl)
+}
+
+func idempotency(store idempotencyStore, ttl time.Duration) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next(w, r)
+				return
+			}
+
+			record, err := acquireIdempotencyKey(r, store, key, ttl)
+			if err != nil {
+				writeError(w, r, err)
+				return
+			}
+			if record != nil {
+				replay(w, record)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next(rec, r)
+			saveIdempotentResponse(r, store, scopedKey(r, key), rec)
+		}
+	}
+}
+
+// idempotencyStore keeps the idempotency records
+type idempotencyStore interface {
+	// create stores the record, reporting false when its key is already taken
+	create(ctx context.Context, record types.IdempotencyRecord) (bool, error)
+	find(ctx context.Context, id string) (types.IdempotencyRecord, error)
+	// lock takes over an unfinished record whose lock expired before now, reporting whether it did
+	lock(ctx context.Context, id string, now, until time.Time) (bool, error)
+	complete(ctx context.Context, id string, status int, header map[string][]string, body []byte) error
+	release(ctx context.Context, id string) error
+}
+
+// acquireIdempotencyKey claims the key for this request. It returns the stored record when
+// the request is a retry whose response can be replayed, nil when the request must run.
+func acquireIdempotencyKey(r *http.Request, store idempotencyStore, key string, ttl time.Duration) (*types.IdempotencyRecord, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, utils.NewValidationError(IdempotencyKeyHeader, fmt.Sprintf("%s must be at most %d characters long", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) > maxIdempotentRequestBody {
+		return nil, utils.NewValidationError("body", "request body is too large")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	// The path is part of the request too, e.g. the session of DELETE /me/sessions/{id}
+	hash := sha256.New()
+	hash.Write([]byte(r.URL.Path + "\n"))
+	hash.Write(body)
+	requestHash := hex.EncodeToString(hash.Sum(nil))
+	id := scopedKey(r, key)
+	now := time.Now().UTC()
+
+	created, err := store.create(r.Context(), types.IdempotencyRecord{
+		ID:          id,
+		RequestHash: requestHash,
+		LockedUntil: now.Add(idempotencyProcessingLock),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	})
+	if err != nil || created {
+		return nil, err
+	}
+
+	existing, err := store.find(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, utils.ErrIdempotencyKeyReused
+	}
+	if existing.Completed {
+		return &existing, nil
+	}
+
+	// The first request may have died with its instance, take over once its lock has expired
+	locked, err := store.lock(r.Context(), id, now, now.Add(idempotencyProcessingLock))
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, utils.NewConflictError("a request with this Idempotency-Key is still being processed")
+	}
+	return nil, nil
+}
+
+// saveIdempotentResponse stores the response for the retries. Server errors are not stored,
+// the key is released instead so the client can retry for real. The response is saved even
+// when the client went away before it, that client is the one retrying.
+func saveIdempotentResponse(r *http.Request, store idempotencyStore, id string, rec *responseRecorder) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencySaveTimeout)
+	defer cancel()
+
+	var err error
+	if rec.status >= http.StatusInternalServerError {
+		err = store.release(ctx, id)
+	} else {
+		header := map[string][]string{}
+		for _, name := range replayedHeaders {
+			if values := rec.Header().Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+		err = store.complete(ctx, id, rec.status, header, rec.body.Bytes())
+	}
+	if err != nil {
+		utils.LoggerFrom(r.Context()).Error("error saving idempotent response", "error", err)
+	}
+}
+
+func replay(w http.ResponseWriter, record *types.IdempotencyRecord) {
+	for name, values := range record.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+}
+
+// scopedKey keeps clients from colliding on the same key, and a key from spanning routes
+func scopedKey(r *http.Request, key string) string {
+	return clientKey(r) + ":" + r.Pattern + ":" + key
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, status := utils.MapErrorToAPIError(err)
+	if status >= http.StatusInternalServerError {
+		utils.LoggerFrom(r.Context()).Error("idempotency check failed", "error", err)
+	}
+	helpers.WriteJSON(w, status, nil, apiErr, "")
+}
+
+// responseRecorder keeps a copy of the response written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// mongoIdempotencyStore keeps the records in a collection whose _id is the scoped key
+type mongoIdempotencyStore struct {
+	coll *mongo.Collection
+}
+
+func (s mongoIdempotencyStore) create(ctx context.Context, record types.IdempotencyRecord) (bool, error) {
+	_, err := s.coll.InsertOne(ctx, record)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to store idempotency key: %w", err)
+	}
+	return true, nil
+}
+
+func (s mongoIdempotencyStore) find(ctx context.Context, id string) (types.IdempotencyRecord, error) {
+	var record types.IdempotencyRecord
+	if err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&record); err != nil {
+		return record, fmt.Errorf("failed to find idempotency key: %w", err)
+	}
+	return record, nil
+}
+
+func (s mongoIdempotencyStore) lock(ctx context.Context, id string, now, until time.Time) (bool, error) {
+	res, err := s.coll.UpdateOne(ctx,
+		bson.M{"_id": id, "completed": false, "locked_until": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"locked_until": until}},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock idempotency key: %w", err)
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+func (s mongoIdempotencyStore) complete(ctx context.Context, id string, status int, header map[string][]string, body []byte) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"completed": true,
+		"status":    status,
+		"header":    header,
+		"body":      body,
+	}})
+	return err
+}
+
+func (s mongoIdempotencyStore) release(ctx context.Context, id string) error {
+	_, err := s.coll.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,231 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+// memoryIdempotencyStore is an idempotencyStore in memory
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]types.IdempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: map[string]types.IdempotencyRecord{}}
+}
+
+func (s *memoryIdempotencyStore) create(_ context.Context, record types.IdempotencyRecord) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[record.ID]; ok {
+		return false, nil
+	}
+	s.records[record.ID] = record
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) find(_ context.Context, id string) (types.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records[id], nil
+}
+
+func (s *memoryIdempotencyStore) lock(_ context.Context, id string, now, until time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[id]
+	if record.Completed || !record.LockedUntil.Before(now) {
+		return false, nil
+	}
+	record.LockedUntil = until
+	s.records[id] = record
+	return true, nil
+}
+
+func (s *memoryIdempotencyStore) complete(ctx context.Context, id string, status int, header map[string][]string, body []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := s.records[id]
+	record.Completed, record.Status, record.Header, record.Body = true, status, header, body
+	s.records[id] = record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) release(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, id)
+	return nil
+}
+
+// idempotentServer serves POST /transactions through the middleware, answering with status
+// and counting the requests that reached the handler
+func idempotentServer(store idempotencyStore, status *int, calls *int) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /transactions", idempotency(store, time.Hour)(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/transactions/1")
+		w.Header().Set("X-Not-Replayed", "1")
+		w.WriteHeader(*status)
+		fmt.Fprintf(w, `{"message":"call %d"}`, *calls)
+	}))
+	return mux
+}
+
+func postTransaction(mux *http.ServeMux, key, body, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/transactions", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	if remoteAddr != "" {
+		r.RemoteAddr = remoteAddr
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	mux := idempotentServer(newMemoryIdempotencyStore(), &status, &calls)
+
+	first := postTransaction(mux, "key-1", `{"amount":10}`, "")
+	retry := postTransaction(mux, "key-1", `{"amount":10}`, "")
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry got %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("only the retry must be marked as replayed")
+	}
+	if retry.Header().Get("Content-Type") != "application/json" || retry.Header().Get("Location") != "/transactions/1" {
+		t.Errorf("replayed headers = %v", retry.Header())
+	}
+	if retry.Header().Get("X-Not-Replayed") != "" {
+		t.Error("headers outside the replayed ones must not be stored")
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		firstKey   string
+		firstAddr  string
+		firstBody  string
+		key        string
+		addr       string
+		body       string
+		wantStatus int
+		wantCalls  int
+	}{
+		{"no key", "", "", `{}`, "", "", `{}`, http.StatusCreated, 2},
+		{"different keys", "key-1", "", `{}`, "key-2", "", `{}`, http.StatusCreated, 2},
+		{"key reused for another request", "key-1", "", `{"amount":10}`, "key-1", "", `{"amount":20}`, http.StatusUnprocessableEntity, 1},
+		{"keys are scoped to the client", "key-1", "203.0.113.7:1", `{}`, "key-1", "198.51.100.1:1", `{"other":true}`, http.StatusCreated, 2},
+		{"key too long", "", "", `{}`, strings.Repeat("k", maxIdempotencyKeyLength+1), "", `{}`, http.StatusBadRequest, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, calls := http.StatusCreated, 0
+			mux := idempotentServer(newMemoryIdempotencyStore(), &status, &calls)
+
+			postTransaction(mux, tt.firstKey, tt.firstBody, tt.firstAddr)
+			w := postTransaction(mux, tt.key, tt.body, tt.addr)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyServerErrorsAreRetried(t *testing.T) {
+	status, calls := http.StatusInternalServerError, 0
+	mux := idempotentServer(newMemoryIdempotencyStore(), &status, &calls)
+
+	postTransaction(mux, "key-1", `{}`, "")
+	status = http.StatusCreated
+	w := postTransaction(mux, "key-1", `{}`, "")
+	if calls != 2 || w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry after a server error: %d calls, status %d, replayed %q", calls, w.Code, w.Header().Get(IdempotentReplayedHeader))
+	}
+
+	// Client errors are final and replayed like successes
+	status = http.StatusBadRequest
+	postTransaction(mux, "key-2", `{}`, "")
+	status = http.StatusCreated
+	if w := postTransaction(mux, "key-2", `{}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("retry of a client error got %d, want the stored 400", w.Code)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	status, calls := http.StatusCreated, 0
+	store := newMemoryIdempotencyStore()
+	mux := idempotentServer(store, &status, &calls)
+
+	// A record still locked by a request being processed elsewhere
+	r := httptest.NewRequest("POST", "/transactions", nil)
+	r.Pattern = "POST /transactions"
+	id := scopedKey(r, "key-1")
+	w := postTransaction(mux, "key-1", `{}`, "")
+	if calls != 1 {
+		t.Fatal("the first request must run")
+	}
+	record := store.records[id]
+	record.Completed = false
+	store.records[id] = record
+
+	if w = postTransaction(mux, "key-1", `{}`, ""); w.Code != http.StatusConflict {
+		t.Errorf("retry while in flight got %d, want 409", w.Code)
+	}
+
+	// Its instance died: once the lock expires the retry takes over
+	record.LockedUntil = time.Now().Add(-time.Second)
+	store.records[id] = record
+	if w = postTransaction(mux, "key-1", `{}`, ""); w.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("retry after the lock expired got %d with %d calls, want 201 and 2", w.Code, calls)
+	}
+}
+
+func TestIdempotencySavedAfterClientDisconnect(t *testing.T) {
+	calls := 0
+	ctx, disconnect := context.WithCancel(context.Background())
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /transactions", idempotency(newMemoryIdempotencyStore(), time.Hour)(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// The client goes away while the transaction is created
+		disconnect()
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	r := httptest.NewRequest("POST", "/transactions", strings.NewReader(`{}`)).WithContext(ctx)
+	r.Header.Set(IdempotencyKeyHeader, "key-1")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+
+	w := postTransaction(mux, "key-1", `{}`, "")
+	if calls != 1 || w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry after a disconnect: %d calls, status %d, replayed %q", calls, w.Code, w.Header().Get(IdempotentReplayedHeader))
+	}
+}
@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/utils"
+)
+
+// maxRequestIDLength stops clients from flooding our logs through the request ID header
+const maxRequestIDLength = 128
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// RequestLogger assigns every request an ID (reusing the caller's X-Request-ID when present),
+// exposes a logger carrying it through the request context and writes one access log line
+// per request once it has been served.
+func RequestLogger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(utils.RequestIDHeader)
+			if requestID == "" || len(requestID) > maxRequestIDLength {
+				requestID = utils.NewRequestID()
+			}
+			w.Header().Set(utils.RequestIDHeader, requestID)
+
+			info := &utils.RequestInfo{ID: requestID}
+			requestLogger := logger.With("request_id", requestID)
+			ctx := utils.WithRequestInfo(utils.WithLogger(r.Context(), requestLogger), info)
+			r = r.WithContext(ctx)
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			route := r.Pattern
+			if route == "" {
+				route = "unmatched"
+			}
+
+			attrs := []any{
+				"method", r.Method,
+				"route", route,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"latency_ms", time.Since(start).Milliseconds(),
+				"ip", utils.ClientIP(r),
+			}
+			if info.UserID != "" {
+				attrs = append(attrs, "user_id", info.UserID)
+			}
+
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			} else if rec.status >= http.StatusBadRequest {
+				level = slog.LevelWarn
+			}
+			requestLogger.Log(r.Context(), level, "request served", attrs...)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/metrics"
+)
+
+// Metrics records the count and latency of every request by method, route pattern and status.
+// The route pattern is used instead of the path to keep the label cardinality bounded.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(rec.status)
+		metrics.HTTPDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, status).Inc()
+	})
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/metrics"
+	"github.com/arcedo/financial-ai-backend/ratelimit"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RateLimit throttles requests with a token bucket per client. Clients are the authenticated
+// user when it runs after JWTAuthMiddleware, their IP otherwise.
+func RateLimit(store ratelimit.Store, bucket ratelimit.Bucket) func(http.HandlerFunc) http.HandlerFunc {
+	return limit(bucket.Name, func(r *http.Request, key string) (ratelimit.Result, error) {
+		return store.TakeToken(r.Context(), key, bucket, time.Now())
+	})
+}
+
+// Quota caps the requests a client can make in a fixed window, e.g. the daily LLM calls.
+// Every route wrapped with the same quota shares the client's allowance.
+func Quota(store ratelimit.Store, quota ratelimit.Quota) func(http.HandlerFunc) http.HandlerFunc {
+	return limit(quota.Name, func(r *http.Request, key string) (ratelimit.Result, error) {
+		return store.CountHit(r.Context(), key, quota, time.Now())
+	})
+}
+
+func limit(name string, check func(r *http.Request, key string) (ratelimit.Result, error)) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			result, err := check(r, name+":"+clientKey(r))
+			if err != nil {
+				// An unavailable limiter backend should not take the whole API down with it
+				utils.LoggerFrom(r.Context()).Error("rate limit check failed, letting request through",
+					"policy", name,
+					"error", err,
+				)
+				next(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w, result)
+			if !result.Allowed {
+				metrics.RateLimitRejections.WithLabelValues(name).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				errValue := utils.ErrorMap[utils.ErrTooManyRequests]
+				helpers.WriteJSON(w, http.StatusTooManyRequests, nil, &errValue, "")
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
+
+// clientKey identifies who the request is counted against
+func clientKey(r *http.Request) string {
+	if userID, ok := r.Context().Value("userID").(primitive.ObjectID); ok {
+		return "user:" + userID.Hex()
+	}
+	return "ip:" + utils.ClientIP(r)
+}
+
+// setRateLimitHeaders writes the RateLimit headers of the IETF draft. When several limits
+// apply to a route, the headers describe the one with the fewest requests left.
+func setRateLimitHeaders(w http.ResponseWriter, result ratelimit.Result) {
+	if current := w.Header().Get("RateLimit-Remaining"); current != "" {
+		if remaining, err := strconv.Atoi(current); err == nil && remaining <= result.Remaining {
+			return
+		}
+	}
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	w.Header().Set("RateLimit-Policy", result.Policy)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
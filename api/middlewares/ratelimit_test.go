@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/ratelimit"
+)
+
+type failingStore struct{}
+
+func (failingStore) TakeToken(context.Context, string, ratelimit.Bucket, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("backend down")
+}
+
+func (failingStore) CountHit(context.Context, string, ratelimit.Quota, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("backend down")
+}
+
+func TestRateLimit(t *testing.T) {
+	bucket := ratelimit.Bucket{Name: "api", Rate: 1, Period: time.Minute, Burst: 2}
+	handler := RateLimit(ratelimit.NewMemoryStore(), bucket)(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		remoteAddr    string
+		wantStatus    int
+		wantRemaining string
+	}{
+		{"203.0.113.7:5000", http.StatusOK, "1"},
+		{"203.0.113.7:5001", http.StatusOK, "0"},
+		{"203.0.113.7:5002", http.StatusTooManyRequests, "0"},
+		// Counted per client IP
+		{"198.51.100.1:5000", http.StatusOK, "1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/products", nil)
+		r.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.remoteAddr, w.Code, tt.wantStatus)
+		}
+		headers := w.Header()
+		if got := headers.Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("%s: RateLimit-Remaining = %q, want %q", tt.remoteAddr, got, tt.wantRemaining)
+		}
+		if headers.Get("RateLimit-Limit") != "2" || headers.Get("RateLimit-Policy") != "1;w=60" {
+			t.Errorf("%s: RateLimit-Limit %q, RateLimit-Policy %q", tt.remoteAddr, headers.Get("RateLimit-Limit"), headers.Get("RateLimit-Policy"))
+		}
+		wantRetry := ""
+		if tt.wantStatus == http.StatusTooManyRequests {
+			wantRetry = "60"
+		}
+		if got := headers.Get("Retry-After"); got != wantRetry {
+			t.Errorf("%s: Retry-After = %q, want %q", tt.remoteAddr, got, wantRetry)
+		}
+	}
+}
+
+func TestQuotaSharedAcrossRoutes(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	quota := Quota(store, ratelimit.Quota{Name: "llm", Limit: 1, Window: 24 * time.Hour})
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	recommendation, analysis := quota(next), quota(next)
+
+	for i, handler := range []http.HandlerFunc{recommendation, analysis} {
+		r := httptest.NewRequest("POST", "/api/v1/llm", nil)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		want := []int{http.StatusOK, http.StatusTooManyRequests}[i]
+		if w.Code != want {
+			t.Errorf("call %d: status %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
+
+func TestRateLimitHeadersShowTheTightestLimit(t *testing.T) {
+	store := ratelimit.NewMemoryStore()
+	bucket := RateLimit(store, ratelimit.Bucket{Name: "api", Rate: 60, Period: time.Minute, Burst: 20})
+	quota := Quota(store, ratelimit.Quota{Name: "llm", Limit: 5, Window: 24 * time.Hour})
+	handler := bucket(quota(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/api/v1/llm", nil))
+	if got := w.Header().Get("RateLimit-Remaining"); got != "4" {
+		t.Errorf("RateLimit-Remaining = %q, want the quota's 4", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "5;w=86400" {
+		t.Errorf("RateLimit-Policy = %q, want the quota's", got)
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	reached := false
+	handler := RateLimit(failingStore{}, ratelimit.Bucket{Name: "api", Rate: 1, Period: time.Minute, Burst: 1})(
+		func(w http.ResponseWriter, r *http.Request) { reached = true })
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/api/v1/products", nil))
+	if !reached || w.Code != http.StatusOK {
+		t.Errorf("request with the limiter down: reached %v, status %d", reached, w.Code)
+	}
+}
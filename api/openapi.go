@@ -0,0 +1,166 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/api/middlewares"
+	"github.com/arcedo/financial-ai-backend/openapi"
+)
+
+//go:embed docs/index.html
+var docsUI embed.FS
+
+// OpenAPISpec documents the versioned API from its route table. The handler dependencies are
+// not needed to describe the routes, so it works without a running server.
+func OpenAPISpec() *openapi.Document {
+	return (&Server{}).openAPISpec()
+}
+
+func (s *Server) openAPISpec() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "Financial AI API",
+		Version:     strings.TrimPrefix(apiPrefix, "/api/"),
+		Description: "Every response is wrapped in an envelope: the payload goes in data, failures set error and message.",
+	})
+	doc.Components.SecuritySchemes["bearer"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	errorSchema := doc.Schema(helpers.APIResponse{})
+
+	for _, route := range s.routes() {
+		op := s.operation(doc, route, errorSchema)
+		doc.AddOperation(route.Method, apiPrefix+route.Path, op)
+
+		if route.Legacy != "" {
+			legacy := *op
+			legacy.OperationID += "Legacy"
+			legacy.Deprecated = true
+			legacy.Summary = fmt.Sprintf("%s (deprecated, use %s)", route.Summary, apiPrefix+route.Path)
+			legacy.Parameters = pathParameters(route.Legacy)
+			for _, param := range op.Parameters {
+				if param.In != "path" {
+					legacy.Parameters = append(legacy.Parameters, param)
+				}
+			}
+			doc.AddOperation(route.Method, route.Legacy, &legacy)
+		}
+	}
+	return doc
+}
+
+func (s *Server) operation(doc *openapi.Document, route Route, errorSchema *openapi.Schema) *openapi.Operation {
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"data":    doc.Schema(route.Response),
+			"message": {Type: "string"},
+		},
+	}
+	op := &openapi.Operation{
+		Summary:     route.Summary,
+		OperationID: operationID(route),
+		Tags:        []string{strings.Split(strings.Trim(route.Path, "/"), "/")[0]},
+		Parameters:  pathParameters(route.Path),
+		Responses: map[string]*openapi.Response{
+			strconv.Itoa(status): jsonResponse(http.StatusText(status), success),
+			"500":                jsonResponse("Unexpected error", errorSchema),
+		},
+	}
+
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, doc.QueryParameters(route.Query)...)
+	}
+	if route.Request != nil {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{"application/json": {Schema: doc.Schema(route.Request)}},
+		}
+		op.Responses["400"] = jsonResponse("Invalid input, details lists every rejected field", errorSchema)
+	} else if route.Query != nil {
+		op.Responses["400"] = jsonResponse("Invalid query parameter, details lists every rejected one", errorSchema)
+	} else if len(op.Parameters) > 0 {
+		op.Responses["400"] = jsonResponse("Invalid path parameter", errorSchema)
+	}
+	if route.Auth || route.Admin {
+		op.Security = []map[string][]string{{"bearer": {}}}
+		op.Responses["401"] = jsonResponse("Missing, invalid or revoked token", errorSchema)
+	}
+	if route.Admin {
+		op.Responses["403"] = jsonResponse("The user is not an administrator", errorSchema)
+	}
+	if route.idempotent() {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name:        middlewares.IdempotencyKeyHeader,
+			In:          "header",
+			Description: "Makes retries safe: the first response is replayed to requests sent again with the same key and body",
+			Schema:      &openapi.Schema{Type: "string"},
+		})
+		op.Responses["409"] = jsonResponse("A request with the same Idempotency-Key is still being processed", errorSchema)
+		op.Responses["422"] = jsonResponse("The Idempotency-Key was already used with a different body", errorSchema)
+	}
+	if route.Rate != RateNone {
+		op.Responses["429"] = jsonResponse("Rate limit exceeded, see the RateLimit and Retry-After headers", errorSchema)
+	}
+	return op
+}
+
+// operationID derives a stable ID like "getMeSessions" or "deleteMeSessionsById"
+func operationID(route Route) string {
+	id := strings.ToLower(route.Method)
+	for _, segment := range strings.Split(strings.Trim(route.Path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") {
+			segment = "by-" + strings.Trim(segment, "{}.")
+		}
+		for _, word := range strings.Split(segment, "-") {
+			if word != "" {
+				id += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+	}
+	return id
+}
+
+func pathParameters(path string) []openapi.Parameter {
+	var params []openapi.Parameter
+	for _, match := range wildcard.FindAllStringSubmatch(path, -1) {
+		params = append(params, openapi.Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &openapi.Schema{Type: "string"},
+		})
+	}
+	return params
+}
+
+func jsonResponse(description string, schema *openapi.Schema) *openapi.Response {
+	return &openapi.Response{
+		Description: description,
+		Content:     map[string]openapi.MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// serveOpenAPI serves the spec as raw JSON, not wrapped in an APIResponse, so tools can load it
+func serveOpenAPI(doc *openapi.Document) http.HandlerFunc {
+	spec, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("openapi spec cannot be encoded: %v", err))
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+	http.ServeFileFS(w, r, docsUI, "docs/index.html")
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+// The committed spec must be regenerated whenever the routes or their types change
+func TestOpenAPISpecIsUpToDate(t *testing.T) {
+	spec, err := json.MarshalIndent(OpenAPISpec(), "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode the spec: %v", err)
+	}
+	spec = append(spec, '\n')
+
+	committed, err := os.ReadFile("../docs/openapi.json")
+	if err != nil {
+		t.Fatalf("failed to read the committed spec: %v", err)
+	}
+	if !bytes.Equal(committed, spec) {
+		t.Error("docs/openapi.json is out of date with the routes and their types, regenerate it with: go run . openapi > docs/openapi.json")
+	}
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/api/helpers"
+	"github.com/arcedo/financial-ai-backend/api/middlewares"
+	"github.com/arcedo/financial-ai-backend/ratelimit"
+	"github.com/arcedo/financial-ai-backend/utils"
+)
+
+// apiPrefix is where the current version of the API is served
+const apiPrefix = "/api/v1"
+
+// RateClass selects the rate limits applied to a route
+type RateClass int
+
+const (
+	// RateNone routes are not limited
+	RateNone RateClass = iota
+	// RateAPI routes share the per client request bucket
+	RateAPI
+	// RateLLM routes also count against the daily LLM quota
+	RateLLM
+)
+
+// Route declares an API endpoint. Path is relative to apiPrefix and uses the Go 1.22 pattern
+// syntax for wildcards, e.g. "/me/sessions/{id}".
+//
+// Summary, Query, Request, Response and Status only document the route in the OpenAPI spec:
+// Query, Request and Response are zero values of the query parameters, body and data types,
+// Status the success status (200 when zero).
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	Auth    bool
+	// Admin routes are only served to administrators, they imply Auth
+	Admin bool
+	// Credentials routes answer with a session token, their responses are never stored to be
+	// replayed to Idempotency-Key retries
+	Credentials bool
+	Rate        RateClass
+	Handler     helpers.ApiFunc
+	Query       any
+	Request     any
+	Response    any
+	Status      int
+	// Legacy is the unversioned path the route was served on before apiPrefix existed. It
+	// keeps working as a deprecated alias pointing clients to the versioned path.
+	Legacy string
+}
+
+// registerRoutes adds the routes to the router under apiPrefix, with their auth and limits
+func (s *Server) registerRoutes(router *http.ServeMux, routes []Route) {
+	authMiddleware := middlewares.JWTAuthMiddleware(s.keys, s.store)
+
+	limited := middlewares.RateLimit(s.limits, ratelimit.Bucket{
+		Name:   "api",
+		Rate:   s.cfg.RateLimit.RequestsPerMinute,
+		Period: time.Minute,
+		Burst:  s.cfg.RateLimit.Burst,
+	})
+	llmQuota := middlewares.Quota(s.limits, ratelimit.Quota{
+		Name:   "llm",
+		Limit:  s.cfg.RateLimit.LLMDailyQuota,
+		Window: 24 * time.Hour,
+	})
+
+	idempotent := middlewares.Idempotency(s.store, s.cfg.Idempotency.TTL)
+
+	for _, route := range routes {
+		handler := helpers.MakeHTTPHandleFunc(route.Handler, s.store)
+		if route.idempotent() {
+			handler = idempotent(handler)
+		}
+		switch route.Rate {
+		case RateAPI:
+			handler = limited(handler)
+		case RateLLM:
+			handler = limited(llmQuota(handler))
+		}
+		if route.Admin {
+			handler = middlewares.RequireAdmin(handler)
+		}
+		// Authenticated routes are limited per user, so the limiters go inside authMiddleware
+		if route.Auth || route.Admin {
+			handler = authMiddleware(handler)
+		}
+
+		router.HandleFunc(route.Method+" "+apiPrefix+route.Path, handler)
+		if route.Legacy != "" {
+			router.HandleFunc(route.Method+" "+route.Legacy, deprecated(apiPrefix+route.Path, handler))
+		}
+	}
+}
+
+// idempotent reports the routes whose requests can carry an Idempotency-Key
+func (route Route) idempotent() bool {
+	return mutating(route.Method) && !route.Credentials
+}
+
+func mutating(method string) bool {
+	return method == http.MethodPost || method == http.MethodPatch || method == http.MethodPut || method == http.MethodDelete
+}
+
+var wildcard = regexp.MustCompile(`\{(\w+)(\.\.\.)?\}`)
+
+// deprecated marks the responses of a legacy alias with the Deprecation header and a link
+// to the path that replaces it
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		location := wildcard.ReplaceAllStringFunc(successor, func(match string) string {
+			return r.PathValue(wildcard.FindStringSubmatch(match)[1])
+		})
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+location+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+// jsonFallback answers the requests matching no route, or a route with another method, with
+// the same JSON errors as the handlers instead of the router's plain text ones
+func jsonFallback(router *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := router.Handler(r); pattern != "" {
+			router.ServeHTTP(w, r)
+			return
+		}
+		router.ServeHTTP(&fallbackWriter{ResponseWriter: w}, r)
+	})
+}
+
+// fallbackWriter replaces the plain text 404 and 405 bodies written by http.ServeMux
+type fallbackWriter struct {
+	http.ResponseWriter
+	replaced bool
+}
+
+func (fw *fallbackWriter) WriteHeader(status int) {
+	sentinel, ok := map[int]error{
+		http.StatusNotFound:         utils.ErrNotFound,
+		http.StatusMethodNotAllowed: utils.ErrInvalidMethod,
+	}[status]
+	if !ok {
+		fw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	fw.replaced = true
+	fw.Header().Del("Content-Type")
+	fw.Header().Del("X-Content-Type-Options")
+	errValue := utils.ErrorMap[sentinel]
+	helpers.WriteJSON(fw.ResponseWriter, status, nil, &errValue, "")
+}
+
+func (fw *fallbackWriter) Write(b []byte) (int, error) {
+	if fw.replaced {
+		return len(b), nil
+	}
+	return fw.ResponseWriter.Write(b)
+}
+
+func (fw *fallbackWriter) Unwrap() http.ResponseWriter {
+	return fw.ResponseWriter
+}
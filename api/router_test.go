@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+// Responses carrying a session token must never be stored in idempotency_keys
+func TestTokenRoutesAreNotIdempotent(t *testing.T) {
+	for _, route := range (&Server{}).routes() {
+		if _, ok := route.Response.(types.AuthResponse); !ok {
+			continue
+		}
+		if !route.Credentials || route.idempotent() {
+			t.Errorf("%s %s answers with a token but stores its responses for retries", route.Method, route.Path)
+		}
+	}
+}
+
+func TestIdempotentRoutes(t *testing.T) {
+	tests := []struct {
+		route Route
+		want  bool
+	}{
+		{Route{Method: "GET"}, false},
+		{Route{Method: "POST"}, true},
+		{Route{Method: "PATCH"}, true},
+		{Route{Method: "DELETE"}, true},
+		{Route{Method: "POST", Credentials: true}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.route.idempotent(); got != tt.want {
+			t.Errorf("idempotent(%s, credentials %v) = %v, want %v", tt.route.Method, tt.route.Credentials, got, tt.want)
+		}
+	}
+}
+
+// Legacy aliases only keep the unversioned paths clients used before /api/v1 working
+func TestLegacyPathsExistedBeforeVersioning(t *testing.T) {
+	unversioned := map[string]bool{
+		"/login": true, "/register": true, "/transaction": true, "/transactions": true, "/stocks": true,
+		"/update-profile": true, "/get-recommendations": true, "/advice": true, "/asset-recommendation/{symbol}": true,
+	}
+	for _, route := range (&Server{}).routes() {
+		if route.Legacy != "" && !unversioned[route.Legacy] {
+			t.Errorf("%s %s has the legacy alias %s, which never existed", route.Method, route.Path, route.Legacy)
+		}
+	}
+}
@@ -3,38 +3,326 @@ package api
 
 import (
 	"net/http"
-	"os"
 
 	"github.com/arcedo/financial-ai-backend/api/handlers"
 	"github.com/arcedo/financial-ai-backend/api/helpers"
-	"github.com/arcedo/financial-ai-backend/api/middlewares"
+	"github.com/arcedo/financial-ai-backend/metrics"
+	"github.com/arcedo/financial-ai-backend/types"
 )
 
+// routes is the table of the versioned API
+func (s *Server) routes() []Route {
+	return []Route{
+		{
+			Method:      "POST",
+			Path:        "/login",
+			Summary:     "Log in and open a session",
+			Rate:        RateAPI,
+			Handler:     handlers.Login(s.keys),
+			Request:     types.Credentials{},
+			Response:    types.AuthResponse{},
+			Credentials: true,
+			Legacy:      "/login",
+		},
+		{
+			Method:      "POST",
+			Path:        "/register",
+			Summary:     "Create an account and open a session",
+			Rate:        RateAPI,
+			Handler:     handlers.CreateUser(s.keys),
+			Request:     types.NewUser{},
+			Response:    types.AuthResponse{},
+			Credentials: true,
+			Status:      http.StatusCreated,
+			Legacy:      "/register",
+		},
+		{
+			Method:  "POST",
+			Path:    "/verify-email",
+			Summary: "Confirm a requested email change with the emailed token",
+			Rate:    RateAPI,
+			Handler: handlers.VerifyEmailChange,
+			Request: types.VerifyEmail{},
+		},
+
+		{
+			Method:   "GET",
+			Path:     "/me",
+			Summary:  "Get the current user",
+			Auth:     true,
+			Rate:     RateAPI,
+			Handler:  handlers.GetUser,
+			Response: types.PublicUser{},
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/me",
+			Summary:  "Update the name of the current user",
+			Auth:     true,
+			Rate:     RateAPI,
+			Handler:  handlers.UpdateUser,
+			Request:  types.UpdateUser{},
+			Response: types.PublicUser{},
+		},
+		{
+			Method:  "POST",
+			Path:    "/me/password",
+			Summary: "Change the password and revoke the other sessions",
+			Auth:    true,
+			Rate:    RateAPI,
+			Handler: handlers.ChangePassword,
+			Request: types.ChangePassword{},
+		},
+		{
+			Method:  "POST",
+			Path:    "/me/email",
+			Summary: "Request an email change, applied once the new address is verified",
+			Auth:    true,
+			Rate:    RateAPI,
+			Handler: handlers.RequestEmailChange(s.mailer),
+			Request: types.ChangeEmail{},
+			Status:  http.StatusAccepted,
+		},
+
+		{
+			Method:   "GET",
+			Path:     "/me/sessions",
+			Summary:  "List the active sessions",
+			Auth:     true,
+			Rate:     RateAPI,
+			Handler:  handlers.GetSessions,
+			Response: []types.SessionPublic{},
+		},
+		{
+			Method:   "DELETE",
+			Path:     "/me/sessions",
+			Summary:  "Revoke every session but the current one",
+			Auth:     true,
+			Rate:     RateAPI,
+			Handler:  handlers.RevokeOtherSessions,
+			Response: map[string]int64{},
+		},
+		{
+			Method:  "DELETE",
+			Path:    "/me/sessions/{id}",
+			Summary: "Revoke a session",
+			Auth:    true,
+			Rate:    RateAPI,
+			Handler: handlers.RevokeSession,
+		},
+
+		{
+			Method:   "POST",
+			Path:     "/transactions",
+			Summary:  "Record a transaction, buys of leveraged and inverse funds come with risk warnings",
+			Auth:     true,
+			Rate:     RateAPI,
+			Handler:  handlers.CreateTransaction,
+			Request:  types.TransactionPublic{},
+			Response: types.TransactionReceipt{},
+			Status:   http.StatusCreated,
+			Legacy:   "/transaction",
+		},
+		{
+			Method:   "GET",
+			Path:     "/transactions",
+			Summary:  "List the transactions of the current user",
+			Auth:     true,
+			Rate:     RateAPI,
+			Handler:  handlers.GetTransactions,
+			Response: []types.TransactionPublic{},
+			Legacy:   "/transactions",
+		},
+
+		{
+			Method:   "GET",
+			Path:     "/me/holdings",
+			Summary:  "List the positions of the current user, in shares adjusted for splits",
+			Auth:     true,
+			Rate:     RateAPI,
+			Handler:  handlers.GetHoldings,
+			Response: []types.Holding{},
+		},
+
+		{
+			Method:   "GET",
+			Path:     "/products",
+			Summary:  "List the products on offer",
+			Rate:     RateAPI,
+			Handler:  handlers.GetProducts,
+			Response: []types.Product{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/products/search",
+			Summary:  "Search products by symbol or name, tolerating typos, ranked by match and popularity",
+			Rate:     RateAPI,
+			Handler:  handlers.SearchProducts(s.interest),
+			Query:    types.ProductSearchQuery{},
+			Response: []types.ProductSearchResult{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/products/{symbol}",
+			Summary:  "Get a product, archived ones included",
+			Rate:     RateAPI,
+			Handler:  handlers.GetProduct,
+			Response: types.Product{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/products/{symbol}/decay",
+			Summary:  "Compare the return of a leveraged or inverse fund with the multiple of its underlying it targets",
+			Rate:     RateAPI,
+			Handler:  handlers.GetProductDecay,
+			Query:    types.DecayQuery{},
+			Response: types.DecayAnalysis{},
+		},
+		{
+			Method:   "POST",
+			Path:     "/products",
+			Summary:  "Add a product",
+			Admin:    true,
+			Rate:     RateAPI,
+			Handler:  handlers.CreateProduct,
+			Request:  types.NewProduct{},
+			Response: types.Product{},
+			Status:   http.StatusCreated,
+		},
+		{
+			Method:   "PATCH",
+			Path:     "/products/{symbol}",
+			Summary:  "Update the given fields of a product, archived false restores it",
+			Admin:    true,
+			Rate:     RateAPI,
+			Handler:  handlers.UpdateProduct,
+			Request:  types.UpdateProduct{},
+			Response: types.Product{},
+		},
+		{
+			Method:  "DELETE",
+			Path:    "/products/{symbol}",
+			Summary: "Archive a product, its transactions and prices are kept",
+			Admin:   true,
+			Rate:    RateAPI,
+			Handler: handlers.DeleteProduct,
+		},
+
+		{
+			Method:   "GET",
+			Path:     "/stocks",
+			Summary:  "List the stored daily prices",
+			Rate:     RateAPI,
+			Handler:  handlers.GetAllStocks,
+			Response: []types.Stock{},
+			Legacy:   "/stocks",
+		},
+		{
+			Method:   "GET",
+			Path:     "/stocks/{symbol}/history",
+			Summary:  "Page through the prices of a symbol, optionally resampled to weekly, monthly or quarterly bars",
+			Rate:     RateAPI,
+			Handler:  handlers.GetStockHistory,
+			Query:    types.StockHistoryQuery{},
+			Response: types.StockHistory{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/stocks/{symbol}/latest",
+			Summary:  "Get the latest price of a symbol and its change over the previous close",
+			Rate:     RateAPI,
+			Handler:  handlers.GetLatestStock,
+			Response: types.Quote{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/stocks/{symbol}/actions",
+			Summary:  "List the splits and cash dividends of a symbol",
+			Rate:     RateAPI,
+			Handler:  handlers.GetCorporateActions,
+			Response: []types.CorporateAction{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/stocks/{symbol}/indicators",
+			Summary:  "Compute technical indicators (sma, ema, rsi, macd, bollinger, atr, volatility) over the daily closes, names take an optional period as in sma:50",
+			Rate:     RateAPI,
+			Handler:  handlers.GetStockIndicators,
+			Query:    types.IndicatorsQuery{},
+			Response: types.Indicators{},
+		},
+		{
+			Method:   "GET",
+			Path:     "/stocks/{symbol}/quality",
+			Summary:  "Find the trading days without a stored bar, the unlikely bars and whether the symbol is stale",
+			Admin:    true,
+			Rate:     RateAPI,
+			Handler:  handlers.GetStockQuality,
+			Query:    types.QualityQuery{},
+			Response: types.QualityReport{},
+		},
+
+		{
+			Method:   "GET",
+			Path:     "/update-profile",
+			Summary:  "Recompute the risk and financial scores with the LLM",
+			Auth:     true,
+			Rate:     RateLLM,
+			Handler:  handlers.UpdateUserProfile(s.llm),
+			Response: types.UserProfile{},
+			Legacy:   "/update-profile",
+		},
+		{
+			Method:   "GET",
+			Path:     "/recommendations",
+			Summary:  "Get product recommendations from the LLM",
+			Auth:     true,
+			Rate:     RateLLM,
+			Handler:  handlers.GetRecommendations(s.llm),
+			Response: []types.Recommendation{},
+			Legacy:   "/get-recommendations",
+		},
+		{
+			Method:   "GET",
+			Path:     "/recommendations/{symbol}",
+			Summary:  "Get the LLM score of an asset for the current user",
+			Auth:     true,
+			Rate:     RateLLM,
+			Handler:  handlers.GetAssetRecommendation(s.llm),
+			Response: 0,
+			Legacy:   "/asset-recommendation/{symbol}",
+		},
+		{
+			Method:   "GET",
+			Path:     "/advice",
+			Summary:  "Get financial advice from the LLM",
+			Auth:     true,
+			Rate:     RateLLM,
+			Handler:  handlers.GetAdvice(s.llm),
+			Response: types.Advice{},
+			Legacy:   "/advice",
+		},
+
+		// Debugging routes
+		//{Method: "GET", Path: "/users", Handler: handlers.GetAllUsers},
+		//{Method: "GET", Path: "/all-transactions", Handler: handlers.GetAllTransactions},
+	}
+}
+
 func (s *Server) setupRoutes() {
 	router := http.NewServeMux()
 
-	authMiddleware := middlewares.JWTAuthMiddleware([]byte(os.Getenv("SECRET")), s.store)
-
-	router.HandleFunc("/login", helpers.MakeHTTPHandleFunc(handlers.Login, s.store, []string{"POST"}))
-	router.HandleFunc("/register", helpers.MakeHTTPHandleFunc(handlers.CreateUser, s.store, []string{"POST"}))
-	/*router.HandleFunc("/user",
-		authMiddleware(
-			helpers.MakeHTTPHandleFunc(handlers.GetUser, s.store, []string{"GET"}),
-		),
-	)*/
-
-	router.HandleFunc("/transaction", authMiddleware(helpers.MakeHTTPHandleFunc(handlers.CreateTransaction, s.store, []string{"POST"})))
-	router.HandleFunc("/transactions", authMiddleware(helpers.MakeHTTPHandleFunc(handlers.GetTransactions, s.store, []string{"GET"})))
-
-	router.HandleFunc("/stocks", helpers.MakeHTTPHandleFunc(handlers.GetAllStocks, s.store, []string{"GET"}))
-
-	router.HandleFunc("/update-profile", authMiddleware(helpers.MakeHTTPHandleFunc(handlers.UpdateUserProfile, s.store, []string{"GET"})))
-	router.HandleFunc("/get-recommendations", authMiddleware(helpers.MakeHTTPHandleFunc(handlers.GetRecommendations, s.store, []string{"GET"})))
-	router.HandleFunc("/advice", authMiddleware(helpers.MakeHTTPHandleFunc(handlers.GetAdvice, s.store, []string{"GET"})))
-	router.HandleFunc("/asset-recommendation/{symbol}", authMiddleware(helpers.MakeHTTPHandleFunc(handlers.GetAssetRecommendation, s.store, []string{"GET"})))
-	// Debugging routes
-	//router.HandleFunc("/users", helpers.MakeHTTPHandleFunc(handlers.GetAllUsers, s.store, []string{"GET"}))
-	//router.HandleFunc("/all-transactions", helpers.MakeHTTPHandleFunc(handlers.GetAllTransactions, s.store, []string{"GET"}))
-	//router.HandleFunc("/products", helpers.MakeHTTPHandleFunc(handlers.GetProducts, s.store, []string{"GET"}))
+	handlers.RegisterValidationRules(s.store)
+
+	// Operational endpoints are not versioned, probes and scrapers expect them at fixed paths
+	router.Handle("GET /metrics", metrics.Handler())
+	router.HandleFunc("GET /healthz", helpers.MakeHTTPHandleFunc(handlers.Healthz, s.store))
+	router.HandleFunc("GET /readyz", helpers.MakeHTTPHandleFunc(handlers.Readyz(s.cfg.Health, s.llm), s.store))
+	router.HandleFunc("GET /.well-known/jwks.json", handlers.JWKS(s.keys))
+
+	router.HandleFunc("GET /openapi.json", serveOpenAPI(s.openAPISpec()))
+	router.HandleFunc("GET /docs", serveDocs)
+
+	s.registerRoutes(router, s.routes())
 	s.router = router
 }
@@ -1,60 +1,105 @@
 package api
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 
+	"github.com/arcedo/financial-ai-backend/api/middlewares"
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/arcedo/financial-ai-backend/corporate"
 	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/ratelimit"
+	"github.com/arcedo/financial-ai-backend/requests"
+	"github.com/arcedo/financial-ai-backend/utils"
 )
 
 type Server struct {
-	listenAddress string
-	store         db.MongoStorage
-	router        *http.ServeMux
+	cfg    *config.Config
+	logger *slog.Logger
+	store  db.MongoStorage
+	keys   *utils.KeyManager
+	llm    *requests.LLMClient
+	mailer *requests.Mailer
+	limits ratelimit.Store
+	// interest ranks the products by holders, refreshed in the background
+	interest *corporate.InterestCache
+	router   *http.ServeMux
 }
 
-func NewServer(listenAddress string, store db.MongoStorage) *Server {
+func NewServer(cfg *config.Config, logger *slog.Logger, store db.MongoStorage, keys *utils.KeyManager, interest *corporate.InterestCache) *Server {
 	return &Server{
-		listenAddress: listenAddress,
-		store:         store,
+		cfg:      cfg,
+		logger:   logger,
+		store:    store,
+		keys:     keys,
+		llm:      requests.NewLLMClient(cfg.LLM),
+		mailer:   requests.NewMailer(cfg.Mail),
+		limits:   newRateLimitStore(cfg.RateLimit, store),
+		interest: interest,
 	}
 }
 
-func (s *Server) Start() error {
+// newRateLimitStore keeps the limits in memory, or in Mongo when several instances must share them
+func newRateLimitStore(cfg config.RateLimitConfig, store db.MongoStorage) ratelimit.Store {
+	if cfg.Backend == "mongo" {
+		return ratelimit.NewMongoStore(store.Collection("rate_limits"))
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+// Start serves requests until ctx is cancelled, then stops accepting connections and waits
+// up to the configured shutdown timeout for in-flight requests (slow LLM calls included)
+// to finish before closing whatever is left.
+func (s *Server) Start(ctx context.Context) error {
 	s.setupRoutes()
 
 	server := &http.Server{
-		Addr:    s.listenAddress,
-		Handler: Cors(s.router),
+		Addr:    s.cfg.ListenAddress,
+		Handler: middlewares.RequestLogger(s.logger)(middlewares.Metrics(s.cors()(jsonFallback(s.router)))),
 	}
 
-	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	serveErr := make(chan error, 1)
 	go func() {
-		<-quit
-		log.Printf("Shutting down server...")
-		server.Close()
+		slog.Info("server running", "address", s.cfg.ListenAddress)
+		serveErr <- server.ListenAndServe()
 	}()
 
-	log.Printf("Server running on port %s", s.listenAddress)
-	return server.ListenAndServe()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server, draining in-flight requests", "timeout", s.cfg.ShutdownTimeout)
+	drainCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(drainCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("server did not drain in time: %w", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	slog.Info("server stopped")
+	return nil
 }
 
-func Cors(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*") // TODO: change in production to our domain
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// cors builds the CORS middleware from the configured policy and its route overrides
+func (s *Server) cors() func(http.Handler) http.Handler {
+	policy := middlewares.NewCORSPolicy(s.cfg.CORS)
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	// The public keys are meant to be fetched by anyone verifying our tokens
+	overrides := map[string]*middlewares.CORSPolicy{
+		"/.well-known/": policy.Override(config.CORSRouteConfig{AllowedOrigins: []string{"*"}}),
+	}
+	for _, route := range s.cfg.CORS.Routes {
+		overrides[route.Path] = policy.Override(route)
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	return middlewares.CORS(policy, overrides)
 }
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/api"
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/arcedo/financial-ai-backend/corporate"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/marketdata"
+	"github.com/arcedo/financial-ai-backend/quality"
+	"github.com/arcedo/financial-ai-backend/stocksync"
+	"github.com/arcedo/financial-ai-backend/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// runCommand runs the maintenance subcommand name and exits
+func runCommand(name string, args []string) {
+	var err error
+	switch name {
+	case "openapi":
+		err = openAPICommand(args)
+	case "dedupe-stocks":
+		err = dedupeStocksCommand()
+	case "sync-actions":
+		err = syncActionsCommand(args)
+	case "grant-admin":
+		err = grantAdminCommand(args)
+	case "check-stocks":
+		err = checkStocksCommand(args)
+	case "backfill-stocks":
+		err = backfillStocksCommand(args)
+	default:
+		err = fmt.Errorf("unknown command %q, available: openapi, dedupe-stocks, sync-actions, grant-admin, check-stocks, backfill-stocks", name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// connect loads the configuration and connects to the database for a command
+func connect() (*config.Config, *db.MongoStorage, error) {
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile == "" {
+		configFile = "config.yaml"
+	}
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	store, err := db.NewMongoStorage(cfg.Database.MongoURI(), cfg.Database.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mongo connection failed: %w", err)
+	}
+	return cfg, store, nil
+}
+
+// dedupeStocksCommand removes the duplicated bars stored before (symbol, date) was unique,
+// then creates the unique index:
+//
+//	go run . dedupe-stocks
+func dedupeStocksCommand() error {
+	_, store, err := connect()
+	if err != nil {
+		return err
+	}
+	defer store.Close(context.Background())
+
+	ctx := context.Background()
+	deleted, err := store.DedupeStocks(ctx, "stocks")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("deleted %d duplicated stock bars\n", deleted)
+
+	if err := store.InitStocks(ctx, "stocks"); err != nil {
+		return err
+	}
+	fmt.Println("unique (symbol, date) index ready")
+	return nil
+}
+
+// syncActionsCommand fetches the splits and dividends of the given symbols, or of every traded
+// symbol, and credits the dividends to their holders. It spends two calls per symbol of the
+// daily market data budget:
+//
+//	go run . sync-actions [SYMBOL...]
+func syncActionsCommand(args []string) error {
+	cfg, store, err := connect()
+	if err != nil {
+		return err
+	}
+	defer store.Close(context.Background())
+
+	provider, err := marketdata.New(cfg.MarketData)
+	if err != nil {
+		return err
+	}
+	actionProvider, ok := provider.(marketdata.ActionProvider)
+	if !ok {
+		return fmt.Errorf("the %s provider has no corporate actions", provider.Name())
+	}
+
+	ctx := context.Background()
+	if err := store.InitCorporateActions(ctx); err != nil {
+		return err
+	}
+
+	symbols := args
+	if len(symbols) == 0 {
+		if symbols, err = corporate.TradedSymbols(ctx, *store); err != nil {
+			return err
+		}
+	}
+
+	budget := stocksync.NewBudget(*store, provider.Name(), cfg.MarketData.DailyBudget)
+	for _, symbol := range symbols {
+		result, err := corporate.Sync(ctx, *store, actionProvider, budget, symbol)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d new actions, %d dividends credited\n", symbol, result.NewActions, result.Credited)
+	}
+	return nil
+}
+
+// grantAdminCommand makes the user with the given email an administrator, or with -revoke a
+// regular user again:
+//
+//	go run . grant-admin [-revoke] EMAIL
+func grantAdminCommand(args []string) error {
+	flags := flag.NewFlagSet("grant-admin", flag.ExitOnError)
+	revoke := flags.Bool("revoke", false, "remove the admin role instead")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: grant-admin [-revoke] EMAIL")
+	}
+	email := strings.TrimSpace(flags.Arg(0))
+
+	_, store, err := connect()
+	if err != nil {
+		return err
+	}
+	defer store.Close(context.Background())
+
+	update := bson.M{"$set": bson.M{"role": types.RoleAdmin}}
+	if *revoke {
+		update = bson.M{"$unset": bson.M{"role": ""}}
+	}
+	res, err := store.Collection("users").UpdateOne(context.Background(), bson.M{"email": email}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update the role: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("no user with email %s", email)
+	}
+
+	if *revoke {
+		fmt.Printf("%s is no longer an administrator\n", email)
+	} else {
+		fmt.Printf("%s is now an administrator\n", email)
+	}
+	return nil
+}
+
+// checkStocksCommand reports the trading days without a stored bar, the unlikely bars and the
+// stale symbols among the given symbols, or every product, and fails when it finds any:
+//
+//	go run . check-stocks [-from DATE] [-to DATE] [SYMBOL...]
+func checkStocksCommand(args []string) error {
+	flags := flag.NewFlagSet("check-stocks", flag.ExitOnError)
+	from := flags.String("from", "", "first day checked, YYYY-MM-DD (default the first stored bar)")
+	to := flags.String("to", "", "last day checked, YYYY-MM-DD (default the latest bar available)")
+	flags.Parse(args)
+	if err := checkDates(*from, *to); err != nil {
+		return err
+	}
+
+	_, store, err := connect()
+	if err != nil {
+		return err
+	}
+	defer store.Close(context.Background())
+
+	ctx := context.Background()
+	symbols := flags.Args()
+	if len(symbols) == 0 {
+		if symbols, err = productSymbols(ctx, *store); err != nil {
+			return err
+		}
+	}
+
+	failing := 0
+	for _, symbol := range symbols {
+		report, err := quality.CheckStore(ctx, *store, symbol, *from, *to, time.Now())
+		if err != nil {
+			return err
+		}
+		if !report.OK() {
+			failing++
+		}
+
+		status := "ok"
+		if report.Stale {
+			status = fmt.Sprintf("stale, %d trading days behind", report.StaleDays)
+		}
+		fmt.Printf("%s: %d/%d bars from %s to %s, %d missing days in %d gaps, %d outliers, latest %s (%s)\n",
+			symbol, report.Bars, report.ExpectedBars, report.From, report.To, report.MissingDays,
+			len(report.Gaps), len(report.Outliers), report.LatestDate, status)
+		for _, gap := range report.Gaps {
+			fmt.Printf("  gap %s to %s (%d days)\n", gap.From, gap.To, gap.Days)
+		}
+		for _, outlier := range report.Outliers {
+			fmt.Printf("  %s %s: %s\n", outlier.Date, outlier.Reason, outlier.Detail)
+		}
+	}
+
+	if failing > 0 {
+		return fmt.Errorf("%d of %d symbols have data problems, missing bars can be fetched with: go run . backfill-stocks -from DATE SYMBOL", failing, len(symbols))
+	}
+	return nil
+}
+
+// backfillStocksCommand fetches the bars of the given symbols over a period and stores them,
+// then reports the trading days still missing. It fails when the provider's history does not
+// cover the period. It spends one call per symbol of the daily market data budget:
+//
+//	go run . backfill-stocks -from DATE [-to DATE] SYMBOL...
+func backfillStocksCommand(args []string) error {
+	flags := flag.NewFlagSet("backfill-stocks", flag.ExitOnError)
+	from := flags.String("from", "", "first day fetched, YYYY-MM-DD")
+	to := flags.String("to", "", "last day fetched, YYYY-MM-DD (default the latest bar available)")
+	flags.Parse(args)
+	if *from == "" || flags.NArg() == 0 {
+		return fmt.Errorf("usage: backfill-stocks -from DATE [-to DATE] SYMBOL...")
+	}
+	if err := checkDates(*from, *to); err != nil {
+		return err
+	}
+
+	cfg, store, err := connect()
+	if err != nil {
+		return err
+	}
+	defer store.Close(context.Background())
+
+	provider, err := marketdata.New(cfg.MarketData)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	budget := stocksync.NewBudget(*store, provider.Name(), cfg.MarketData.DailyBudget)
+	failing := 0
+	for _, symbol := range flags.Args() {
+		result, backfillErr := stocksync.Backfill(ctx, *store, provider, budget, symbol, *from, *to)
+		if errors.Is(backfillErr, stocksync.ErrBudgetExhausted) || errors.Is(backfillErr, marketdata.ErrRateLimited) {
+			return backfillErr
+		}
+
+		report, err := quality.CheckStore(ctx, *store, symbol, *from, *to, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d bars fetched, %d new, %d trading days still missing\n",
+			symbol, result.Fetched, result.Inserted, report.MissingDays)
+		if backfillErr != nil {
+			fmt.Printf("  %v\n", backfillErr)
+			failing++
+		}
+	}
+
+	if failing > 0 {
+		return fmt.Errorf("%d of %d symbols could not be backfilled over the whole period", failing, flags.NArg())
+	}
+	return nil
+}
+
+// checkDates validates the optional period of a command
+func checkDates(from, to string) error {
+	for _, date := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+	}
+	if from != "" && to != "" && from > to {
+		return fmt.Errorf("from must not be after to")
+	}
+	return nil
+}
+
+// productSymbols lists the symbols of the products that are not archived
+func productSymbols(ctx context.Context, store db.MongoStorage) ([]string, error) {
+	cursor, err := store.Collection("products").Find(ctx, bson.M{"archived": bson.M{"$ne": true}},
+		options.Find().SetSort(bson.D{{Key: "symbol", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	var products []types.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, fmt.Errorf("failed to decode products: %w", err)
+	}
+
+	symbols := make([]string, len(products))
+	for i, product := range products {
+		symbols[i] = product.Symbol
+	}
+	return symbols, nil
+}
+
+// openAPICommand prints the OpenAPI spec generated from the route table, or with -check
+// compares it with the committed one so CI fails when routes or their types drift from it:
+//
+//	go run . openapi > docs/openapi.json
+//	go run . openapi -check docs/openapi.json
+func openAPICommand(args []string) error {
+	flags := flag.NewFlagSet("openapi", flag.ExitOnError)
+	check := flags.String("check", "", "committed spec to compare the generated one with")
+	flags.Parse(args)
+
+	spec, err := json.MarshalIndent(api.OpenAPISpec(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the spec: %w", err)
+	}
+	spec = append(spec, '\n')
+
+	if *check == "" {
+		_, err := os.Stdout.Write(spec)
+		return err
+	}
+
+	committed, err := os.ReadFile(*check)
+	if err != nil {
+		return fmt.Errorf("failed to read the committed spec: %w", err)
+	}
+	if !bytes.Equal(committed, spec) {
+		return fmt.Errorf("%s is out of date with the routes and their types, regenerate it with: go run . openapi > %s", *check, *check)
+	}
+	fmt.Println("openapi spec is up to date")
+	return nil
+}
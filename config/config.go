@@ -0,0 +1,426 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting of the backend. Values are resolved with this precedence,
+// highest first: process environment, .env file, YAML config file, defaults.
+//
+// Fields are bound to environment variables through the `env` tag, fields tagged
+// `secret:"true"` are redacted when the config is printed and `required:"true"` fields
+// must end up with a non-empty value.
+type Config struct {
+	ListenAddress   string        `yaml:"listen_address" env:"LISTEN_ADDRESS" required:"true"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" required:"true"`
+	// TrustedProxies lists the CIDRs of the reverse proxies whose X-Forwarded-For is believed,
+	// the client IP of other requests is their remote address
+	TrustedProxies []string          `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	Log            LogConfig         `yaml:"log"`
+	Database       DatabaseConfig    `yaml:"database"`
+	JWT            JWTConfig         `yaml:"jwt"`
+	LLM            LLMConfig         `yaml:"llm"`
+	MarketData     MarketDataConfig  `yaml:"market_data"`
+	Mail           MailConfig        `yaml:"mail"`
+	Health         HealthConfig      `yaml:"health"`
+	RateLimit      RateLimitConfig   `yaml:"rate_limit"`
+	CORS           CORSConfig        `yaml:"cors"`
+	Idempotency    IdempotencyConfig `yaml:"idempotency"`
+	Products       ProductsConfig    `yaml:"products"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" required:"true"`
+	Format string `yaml:"format" env:"LOG_FORMAT" required:"true"`
+}
+
+type DatabaseConfig struct {
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASS" secret:"true"`
+	Host     string `yaml:"host" env:"DB_HOST" required:"true"`
+	Port     int    `yaml:"port" env:"DB_PORT" required:"true"`
+	Name     string `yaml:"name" env:"DB_NAME" required:"true"`
+}
+
+type JWTConfig struct {
+	Issuer           string        `yaml:"issuer" env:"ISSUER"`
+	Algorithm        string        `yaml:"algorithm" env:"JWT_ALGORITHM" required:"true"`
+	KeysDir          string        `yaml:"keys_dir" env:"JWT_KEYS_DIR"`
+	RotationInterval time.Duration `yaml:"rotation_interval" env:"JWT_ROTATION_INTERVAL"`
+	LegacySecret     string        `yaml:"legacy_secret" env:"SECRET" secret:"true"`
+}
+
+type LLMConfig struct {
+	Host    string        `yaml:"host" env:"LLM_HOST" required:"true"`
+	APIKey  string        `yaml:"api_key" env:"LLM_API_KEY" secret:"true"`
+	Timeout time.Duration `yaml:"timeout" env:"LLM_TIMEOUT" required:"true"`
+	// SendIndicators posts the latest technical indicators of the asset along with asset
+	// recommendation requests, for llama services that accept them
+	SendIndicators bool `yaml:"send_indicators" env:"LLM_SEND_INDICATORS"`
+}
+
+// MarketDataConfig selects where daily prices come from: "alphavantage", "file" (CSV or JSON
+// files in DataDir) or "fake" (generated random walks)
+type MarketDataConfig struct {
+	Provider    string        `yaml:"provider" env:"MARKET_DATA_PROVIDER" required:"true"`
+	APIKey      string        `yaml:"api_key" env:"ALPHA_VANTAGE_API_KEY" secret:"true"`
+	BaseURL     string        `yaml:"base_url" env:"ALPHA_VANTAGE_URL"`
+	MinInterval time.Duration `yaml:"min_interval" env:"ALPHA_VANTAGE_MIN_INTERVAL"`
+	DataDir     string        `yaml:"data_dir" env:"MARKET_DATA_DIR"`
+	SyncEnabled bool          `yaml:"sync_enabled" env:"STOCK_SYNC_ENABLED"`
+	// SyncAt lists the UTC times of the day ("15:04") the sync runs at
+	SyncAt []string `yaml:"sync_at" env:"STOCK_SYNC_AT" required:"true"`
+	// DailyBudget caps the provider calls per UTC day across every instance, 0 for no cap
+	DailyBudget int `yaml:"daily_budget" env:"MARKET_DATA_DAILY_BUDGET"`
+}
+
+type MailConfig struct {
+	AppURL     string `yaml:"app_url" env:"APP_URL"`
+	WebhookURL string `yaml:"webhook_url" env:"MAIL_WEBHOOK_URL" secret:"true"`
+}
+
+type HealthConfig struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" required:"true"`
+	StocksMaxAge time.Duration `yaml:"stocks_max_age" env:"HEALTH_STOCKS_MAX_AGE" required:"true"`
+}
+
+// RateLimitConfig throttles clients per user (or per IP when unauthenticated). The LLM
+// quota is a separate daily allowance shared by every LLM backed route.
+type RateLimitConfig struct {
+	Backend           string `yaml:"backend" env:"RATE_LIMIT_BACKEND" required:"true"`
+	RequestsPerMinute int    `yaml:"requests_per_minute" env:"RATE_LIMIT_REQUESTS_PER_MINUTE" required:"true"`
+	Burst             int    `yaml:"burst" env:"RATE_LIMIT_BURST" required:"true"`
+	LLMDailyQuota     int    `yaml:"llm_daily_quota" env:"RATE_LIMIT_LLM_DAILY_QUOTA" required:"true"`
+}
+
+// CORSConfig is the cross-origin policy of the API. Origins are exact ("https://app.example.com"),
+// patterns with a single wildcard ("https://*.example.com") or "*" for any origin.
+// Routes overrides the origins and credentials for the paths starting with their path; it
+// can only be set in the YAML file.
+type CORSConfig struct {
+	AllowedOrigins   []string          `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" required:"true"`
+	AllowedMethods   []string          `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS" required:"true"`
+	AllowedHeaders   []string          `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	ExposedHeaders   []string          `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS"`
+	AllowCredentials bool              `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration     `yaml:"max_age" env:"CORS_MAX_AGE"`
+	Routes           []CORSRouteConfig `yaml:"routes"`
+}
+
+type CORSRouteConfig struct {
+	Path             string   `yaml:"path"`
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+}
+
+// IdempotencyConfig sets how long the responses to requests sent with an Idempotency-Key
+// are kept to be replayed to retries
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" required:"true"`
+}
+
+// ProductsConfig selects how the built-in products are seeded on startup: "reconcile" adds
+// the missing products and fills the fields missing from stored ones without overwriting
+// anything, "once" only seeds an empty collection, "off" leaves it alone.
+type ProductsConfig struct {
+	Seed string `yaml:"seed" env:"PRODUCTS_SEED" required:"true"`
+}
+
+// Default returns the configuration used when nothing overrides a value
+func Default() Config {
+	return Config{
+		ListenAddress:   ":3001",
+		ShutdownTimeout: 30 * time.Second,
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Database: DatabaseConfig{
+			Host: "localhost",
+			Port: 27017,
+			Name: "financial-ai",
+		},
+		JWT: JWTConfig{
+			Algorithm:        "RS256",
+			KeysDir:          "./keys", // stored keys keep the sessions valid across restarts
+			RotationInterval: 30 * 24 * time.Hour,
+		},
+		LLM: LLMConfig{
+			Timeout: 10 * time.Second,
+		},
+		MarketData: MarketDataConfig{
+			Provider: "alphavantage",
+			BaseURL:  "https://www.alphavantage.co",
+			// The free tier allows 5 calls per minute
+			MinInterval: 12 * time.Second,
+			// and only 25 calls per day
+			DailyBudget: 25,
+			SyncEnabled: false,
+			// After the US close, and again before the European open for what was missed
+			SyncAt: []string{"21:30", "06:00"},
+		},
+		Health: HealthConfig{
+			CheckTimeout: 2 * time.Second,
+			// Long enough to cover a weekend plus a bank holiday without market data
+			StocksMaxAge: 96 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Backend:           "memory",
+			RequestsPerMinute: 60,
+			Burst:             20,
+			LLMDailyQuota:     50,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:3000"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-Request-ID", "Idempotency-Key"},
+			ExposedHeaders: []string{
+				"X-Request-ID", "Retry-After", "Idempotent-Replayed",
+				"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+			},
+			MaxAge: 10 * time.Minute,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: 24 * time.Hour,
+		},
+		Products: ProductsConfig{
+			Seed: "reconcile",
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the optional YAML file at configFile,
+// the optional .env file and the environment, then validates it.
+func Load(configFile string) (*Config, error) {
+	cfg := Default()
+
+	if configFile != "" {
+		raw, err := os.ReadFile(configFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err == nil {
+			if err := yaml.Unmarshal(raw, &cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %w", configFile, err)
+			}
+		}
+	}
+
+	// godotenv never overrides variables already set, so the real environment wins
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env file: %w", err)
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate reports every missing required value and every invalid one at once
+func (c *Config) Validate() error {
+	var problems []string
+	walk(reflect.ValueOf(c).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		if field.Tag.Get("required") == "true" && value.IsZero() {
+			problems = append(problems, fmt.Sprintf("%s is required (env %s)", path, field.Tag.Get("env")))
+		}
+	})
+
+	if c.JWT.Algorithm != "RS256" && c.JWT.Algorithm != "EdDSA" {
+		problems = append(problems, "jwt.algorithm must be RS256 or EdDSA")
+	}
+	if c.JWT.RotationInterval < 0 {
+		problems = append(problems, "jwt.rotation_interval cannot be negative")
+	}
+
+	if c.RateLimit.Backend != "memory" && c.RateLimit.Backend != "mongo" {
+		problems = append(problems, "rate_limit.backend must be memory or mongo")
+	}
+
+	for _, cidr := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			problems = append(problems, fmt.Sprintf("trusted_proxies: invalid CIDR %q", cidr))
+		}
+	}
+
+	problems = append(problems, c.CORS.validate()...)
+
+	switch c.Products.Seed {
+	case "reconcile", "once", "off":
+	default:
+		problems = append(problems, "products.seed must be reconcile, once or off")
+	}
+
+	switch c.MarketData.Provider {
+	case "alphavantage":
+		if c.MarketData.BaseURL == "" {
+			problems = append(problems, "market_data.base_url is required for the alphavantage provider (env ALPHA_VANTAGE_URL)")
+		}
+	case "file":
+		if c.MarketData.DataDir == "" {
+			problems = append(problems, "market_data.data_dir is required for the file provider (env MARKET_DATA_DIR)")
+		}
+	case "fake":
+	default:
+		problems = append(problems, "market_data.provider must be alphavantage, file or fake")
+	}
+	for _, at := range c.MarketData.SyncAt {
+		if _, err := time.Parse("15:04", at); err != nil {
+			problems = append(problems, fmt.Sprintf("market_data.sync_at: invalid time of the day %q, expected HH:MM", at))
+		}
+	}
+	if c.MarketData.DailyBudget < 0 {
+		problems = append(problems, "market_data.daily_budget cannot be negative")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (c CORSConfig) validate() []string {
+	var problems []string
+	check := func(path string, origins []string, credentials bool) {
+		for _, origin := range origins {
+			if origin == "*" && credentials {
+				problems = append(problems, path+": any origin (*) cannot be combined with allow_credentials")
+			}
+			if strings.Count(origin, "*") > 1 || (origin != "*" && strings.Contains(origin, "*") && !strings.Contains(origin, "://*.")) {
+				problems = append(problems, fmt.Sprintf("%s: origin pattern %q must look like https://*.example.com", path, origin))
+			}
+		}
+	}
+
+	check("cors.allowed_origins", c.AllowedOrigins, c.AllowCredentials)
+	for i, route := range c.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			problems = append(problems, fmt.Sprintf("cors.routes[%d].path must start with /", i))
+		}
+		check(fmt.Sprintf("cors.routes[%d].allowed_origins", i), route.AllowedOrigins, route.AllowCredentials)
+	}
+	return problems
+}
+
+// MongoURI builds the connection string for the configured database
+func (d DatabaseConfig) MongoURI() string {
+	uri := url.URL{Scheme: "mongodb", Host: fmt.Sprintf("%s:%d", d.Host, d.Port)}
+	if d.User != "" {
+		uri.User = url.UserPassword(d.User, d.Password)
+	}
+	return uri.String()
+}
+
+// String prints the configuration with secrets redacted, safe to log
+func (c Config) String() string {
+	var b strings.Builder
+	walk(reflect.ValueOf(&c).Elem(), "", func(path string, field reflect.StructField, value reflect.Value) {
+		shown := fmt.Sprint(value.Interface())
+		if field.Tag.Get("secret") == "true" && !value.IsZero() {
+			shown = "[redacted]"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", path, shown)
+	})
+	return b.String()
+}
+
+// walk calls fn for every leaf field of a config struct, with its dotted yaml path
+func walk(value reflect.Value, prefix string, fn func(path string, field reflect.StructField, value reflect.Value)) {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		path := field.Tag.Get("yaml")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			walk(value.Field(i), path, fn)
+			continue
+		}
+		fn(path, field, value.Field(i))
+	}
+}
+
+// applyEnv overrides every field bound to an environment variable that is set
+func applyEnv(value reflect.Value) error {
+	var problems []string
+	walk(value, "", func(path string, field reflect.StructField, fieldValue reflect.Value) {
+		name := field.Tag.Get("env")
+		raw, ok := os.LookupEnv(name)
+		if name == "" || !ok {
+			return
+		}
+		if err := setValue(fieldValue, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	})
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid environment variables:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func setValue(value reflect.Value, raw string) error {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		number, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(number)
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(flag)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported config type %s", value.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	case reflect.Float64:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(number)
+	default:
+		return fmt.Errorf("unsupported config type %s", value.Type())
+	}
+	return nil
+}
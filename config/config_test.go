@@ -0,0 +1,240 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsetEnv clears the variables for the test, restoring them afterwards. Variables the .env
+// file sets are restored too, since t.Setenv remembers their original value.
+func unsetEnv(t *testing.T, names ...string) {
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	unsetEnv(t, "LOG_LEVEL", "LOG_FORMAT", "DB_NAME", "DB_PORT", "DB_HOST", "LLM_HOST", "LLM_TIMEOUT", "CORS_ALLOWED_ORIGINS")
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), `
+log:
+  level: debug
+  format: text
+database:
+  port: 1234
+  name: from-yaml
+llm:
+  host: http://from-yaml
+  timeout: 3s
+`)
+	writeFile(t, filepath.Join(dir, ".env"), "LOG_LEVEL=warn\nDB_NAME=from-dotenv\n")
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example.com, https://*.example.org,")
+
+	cfg, err := Load(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"environment over .env", cfg.Log.Level, "error"},
+		{".env over YAML", cfg.Database.Name, "from-dotenv"},
+		{"YAML over defaults", cfg.Log.Format, "text"},
+		{"YAML number", cfg.Database.Port, 1234},
+		{"YAML duration", cfg.LLM.Timeout, 3 * time.Second},
+		{"default", cfg.Database.Host, "localhost"},
+		{"comma separated list", cfg.CORS.AllowedOrigins, []string{"https://a.example.com", "https://*.example.org"}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		env      map[string]string
+		contains string
+	}{
+		{
+			name:     "missing required value",
+			contains: "llm.host is required (env LLM_HOST)",
+		},
+		{
+			name:     "unparsable variable",
+			env:      map[string]string{"LLM_HOST": "http://llm", "DB_PORT": "mongo"},
+			contains: "DB_PORT",
+		},
+		{
+			name:     "unparsable duration",
+			env:      map[string]string{"LLM_HOST": "http://llm", "LLM_TIMEOUT": "10"},
+			contains: "LLM_TIMEOUT",
+		},
+		{
+			name:     "malformed YAML",
+			yaml:     "log: [",
+			contains: "failed to parse config file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Chdir(dir)
+			unsetEnv(t, "LLM_HOST", "DB_PORT", "LLM_TIMEOUT")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			configFile := filepath.Join(dir, "config.yaml")
+			if tt.yaml != "" {
+				writeFile(t, configFile, tt.yaml)
+			}
+
+			_, err := Load(configFile)
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Load() error = %v, want it to contain %q", err, tt.contains)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(*Config)
+		contains []string
+	}{
+		{name: "defaults", change: func(*Config) {}},
+		{name: "fake provider", change: func(c *Config) { c.MarketData.Provider = "fake"; c.MarketData.BaseURL = "" }},
+		{
+			name: "every problem is reported",
+			change: func(c *Config) {
+				c.JWT.Algorithm = "HS256"
+				c.RateLimit.Backend = "redis"
+				c.Products.Seed = "always"
+			},
+			contains: []string{"jwt.algorithm", "rate_limit.backend", "products.seed"},
+		},
+		{
+			name:     "file provider without a directory",
+			change:   func(c *Config) { c.MarketData.Provider = "file" },
+			contains: []string{"market_data.data_dir is required"},
+		},
+		{
+			name:     "unknown provider",
+			change:   func(c *Config) { c.MarketData.Provider = "bloomberg" },
+			contains: []string{"market_data.provider"},
+		},
+		{
+			name:     "sync time",
+			change:   func(c *Config) { c.MarketData.SyncAt = []string{"21:30", "9pm"} },
+			contains: []string{`invalid time of the day "9pm"`},
+		},
+		{
+			name:     "negative budget and rotation",
+			change:   func(c *Config) { c.MarketData.DailyBudget = -1; c.JWT.RotationInterval = -time.Hour },
+			contains: []string{"market_data.daily_budget", "jwt.rotation_interval"},
+		},
+		{
+			name:     "trusted proxy without prefix length",
+			change:   func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8", "10.0.0.1"} },
+			contains: []string{`invalid CIDR "10.0.0.1"`},
+		},
+		{
+			name:     "any origin with credentials",
+			change:   func(c *Config) { c.CORS.AllowedOrigins = []string{"*"}; c.CORS.AllowCredentials = true },
+			contains: []string{"cannot be combined with allow_credentials"},
+		},
+		{
+			name: "origin patterns and route paths",
+			change: func(c *Config) {
+				c.CORS.AllowedOrigins = []string{"https://app.*.com"}
+				c.CORS.Routes = []CORSRouteConfig{{Path: "api/v1/stocks", AllowedOrigins: []string{"*.*"}}}
+			},
+			contains: []string{`origin pattern "https://app.*.com"`, "cors.routes[0].path", `origin pattern "*.*"`},
+		},
+		{
+			name:     "required values",
+			change:   func(c *Config) { c.ListenAddress = ""; c.CORS.AllowedMethods = nil },
+			contains: []string{"listen_address is required (env LISTEN_ADDRESS)", "cors.allowed_methods is required"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.LLM.Host = "http://llm"
+			tt.change(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.contains) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() = nil, want an error")
+			}
+			for _, problem := range tt.contains {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("error %q does not mention %q", err, problem)
+				}
+			}
+		})
+	}
+}
+
+func TestStringRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "hunter2"
+	cfg.MarketData.APIKey = "av-key"
+
+	printed := cfg.String()
+	for _, secret := range []string{"hunter2", "av-key"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("String() leaks %q", secret)
+		}
+	}
+	if !strings.Contains(printed, "database.password: [redacted]") {
+		t.Error("String() does not show the password as redacted")
+	}
+	// Empty secrets show they are unset
+	if !strings.Contains(printed, "llm.api_key: \n") {
+		t.Error("String() redacts an unset secret")
+	}
+}
+
+func TestMongoURI(t *testing.T) {
+	tests := []struct {
+		db   DatabaseConfig
+		want string
+	}{
+		{DatabaseConfig{Host: "localhost", Port: 27017}, "mongodb://localhost:27017"},
+		{DatabaseConfig{User: "app", Password: "p@ss/word", Host: "db", Port: 27018}, "mongodb://app:p%40ss%2Fword@db:27018"},
+	}
+	for _, tt := range tests {
+		if got := tt.db.MongoURI(); got != tt.want {
+			t.Errorf("MongoURI() = %q, want %q", got, tt.want)
+		}
+	}
+}
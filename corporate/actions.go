@@ -0,0 +1,128 @@
+// Package corporate stores the splits and cash dividends of symbols and applies them: to
+// price series, which stay continuous across them, and to the holdings of users, whose shares
+// follow the splits and who are credited the dividends.
+package corporate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Collection holds the actions, unique per symbol, type and ex-date
+const Collection = "corporate_actions"
+
+// Load returns the actions of the symbols, every symbol when none is given, oldest first
+func Load(ctx context.Context, store db.MongoStorage, symbols ...string) ([]types.CorporateAction, error) {
+	filter := bson.M{}
+	if len(symbols) > 0 {
+		filter["symbol"] = bson.M{"$in": symbols}
+	}
+
+	cursor, err := store.Collection(Collection).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "ex_date", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving corporate actions: %w", err)
+	}
+	actions := []types.CorporateAction{}
+	if err := cursor.All(ctx, &actions); err != nil {
+		return nil, fmt.Errorf("error decoding corporate actions: %w", err)
+	}
+	return actions, nil
+}
+
+// Upsert stores the actions, replacing the amounts of those already known, and returns how
+// many were new
+func Upsert(ctx context.Context, store db.MongoStorage, actions []types.CorporateAction) (int, error) {
+	if len(actions) == 0 {
+		return 0, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(actions))
+	for _, action := range actions {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"symbol": action.Symbol, "type": action.Type, "ex_date": action.ExDate}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"pay_date": action.PayDate,
+				"ratio":    action.Ratio,
+				"amount":   action.Amount,
+			}}).
+			SetUpsert(true))
+	}
+
+	res, err := store.Collection(Collection).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, fmt.Errorf("failed to store corporate actions: %w", err)
+	}
+	return int(res.UpsertedCount), nil
+}
+
+// SplitFactor is how many shares one share held on date has become through the splits since.
+// Splits still to go ex have not changed anything yet.
+func SplitFactor(actions []types.CorporateAction, date string) float64 {
+	today := time.Now().UTC().Format("2006-01-02")
+	factor := 1.0
+	for _, action := range actions {
+		if action.Type == types.ActionSplit && action.ExDate > date && action.ExDate <= today && action.Ratio > 0 {
+			factor *= action.Ratio
+		}
+	}
+	return factor
+}
+
+// AdjustBars back-adjusts bars sorted oldest first so returns stay continuous: bars before a
+// split are divided by its ratio (and their volume multiplied), and with dividends, bars
+// before an ex-date are scaled by 1 - dividend / the previous close. The prices since the last
+// ex-date are left as traded, actions declared but still to go ex are not applied.
+func AdjustBars(bars []types.Bar, actions []types.CorporateAction, dividends bool) []types.Bar {
+	today := time.Now().UTC().Format("2006-01-02")
+	pending := make([]types.CorporateAction, 0, len(actions))
+	for _, action := range actions {
+		if action.ExDate > today {
+			continue
+		}
+		if action.Type == types.ActionSplit && action.Ratio > 0 ||
+			dividends && action.Type == types.ActionDividend && action.Amount > 0 {
+			pending = append(pending, action)
+		}
+	}
+	// Walked newest first, alongside the bars
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ExDate > pending[j].ExDate })
+
+	adjusted := make([]types.Bar, len(bars))
+	priceFactor, volumeFactor := 1.0, 1.0
+	for i := len(bars) - 1; i >= 0; i-- {
+		bar := bars[i]
+		// Every action effective after this bar applies to it and the bars before
+		for len(pending) > 0 && pending[0].ExDate > bar.Date {
+			switch action := pending[0]; action.Type {
+			case types.ActionSplit:
+				priceFactor /= action.Ratio
+				volumeFactor *= action.Ratio
+			case types.ActionDividend:
+				// bar is the last one before the ex-date, its close included the dividend
+				if adjClose := float64(bar.Close); adjClose > action.Amount {
+					priceFactor *= 1 - action.Amount/adjClose
+				}
+			}
+			pending = pending[1:]
+		}
+
+		adjusted[i] = types.Bar{
+			Date:   bar.Date,
+			Open:   float32(float64(bar.Open) * priceFactor),
+			High:   float32(float64(bar.High) * priceFactor),
+			Low:    float32(float64(bar.Low) * priceFactor),
+			Close:  float32(float64(bar.Close) * priceFactor),
+			Volume: float32(float64(bar.Volume) * volumeFactor),
+		}
+	}
+	return adjusted
+}
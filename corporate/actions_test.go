@@ -0,0 +1,144 @@
+package corporate
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+func TestAdjustBars(t *testing.T) {
+	bars := []types.Bar{
+		{Date: "2025-07-01", Open: 99, High: 101, Low: 98, Close: 100, Volume: 1000},
+		{Date: "2025-07-02", Open: 100, High: 102, Low: 99, Close: 100, Volume: 1000},
+		{Date: "2025-07-03", Open: 25, High: 26, Low: 24, Close: 25, Volume: 4000},
+		{Date: "2025-07-07", Open: 25, High: 26, Low: 24, Close: 25, Volume: 4000},
+	}
+	split := types.CorporateAction{Type: types.ActionSplit, ExDate: "2025-07-03", Ratio: 4}
+	dividend := types.CorporateAction{Type: types.ActionDividend, ExDate: "2025-07-02", Amount: 1}
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+
+	tests := []struct {
+		name        string
+		actions     []types.CorporateAction
+		dividends   bool
+		wantCloses  []float64
+		wantVolumes []float64
+	}{
+		{
+			name:        "no actions",
+			wantCloses:  []float64{100, 100, 25, 25},
+			wantVolumes: []float64{1000, 1000, 4000, 4000},
+		},
+		{
+			name:        "split",
+			actions:     []types.CorporateAction{split},
+			wantCloses:  []float64{25, 25, 25, 25},
+			wantVolumes: []float64{4000, 4000, 4000, 4000},
+		},
+		{
+			name:        "dividend",
+			actions:     []types.CorporateAction{dividend},
+			dividends:   true,
+			wantCloses:  []float64{99, 100, 25, 25},
+			wantVolumes: []float64{1000, 1000, 4000, 4000},
+		},
+		{
+			name:        "dividends left out",
+			actions:     []types.CorporateAction{dividend},
+			wantCloses:  []float64{100, 100, 25, 25},
+			wantVolumes: []float64{1000, 1000, 4000, 4000},
+		},
+		{
+			name:        "split and dividend in any order",
+			actions:     []types.CorporateAction{dividend, split},
+			dividends:   true,
+			wantCloses:  []float64{24.75, 25, 25, 25},
+			wantVolumes: []float64{4000, 4000, 4000, 4000},
+		},
+		{
+			name: "dividend above the close is ignored",
+			actions: []types.CorporateAction{
+				{Type: types.ActionDividend, ExDate: "2025-07-02", Amount: 150},
+			},
+			dividends:   true,
+			wantCloses:  []float64{100, 100, 25, 25},
+			wantVolumes: []float64{1000, 1000, 4000, 4000},
+		},
+		{
+			name: "split without a ratio is ignored",
+			actions: []types.CorporateAction{
+				{Type: types.ActionSplit, ExDate: "2025-07-03"},
+			},
+			wantCloses:  []float64{100, 100, 25, 25},
+			wantVolumes: []float64{1000, 1000, 4000, 4000},
+		},
+		{
+			name: "actions after the latest bar apply to every bar",
+			actions: []types.CorporateAction{
+				{Type: types.ActionSplit, ExDate: "2025-07-08", Ratio: 5},
+			},
+			wantCloses:  []float64{20, 20, 5, 5},
+			wantVolumes: []float64{5000, 5000, 20000, 20000},
+		},
+		{
+			name: "actions still to go ex are not applied",
+			actions: []types.CorporateAction{
+				{Type: types.ActionDividend, ExDate: tomorrow, Amount: 1},
+				{Type: types.ActionSplit, ExDate: tomorrow, Ratio: 5},
+			},
+			dividends:   true,
+			wantCloses:  []float64{100, 100, 25, 25},
+			wantVolumes: []float64{1000, 1000, 4000, 4000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adjusted := AdjustBars(bars, tt.actions, tt.dividends)
+			if len(adjusted) != len(bars) {
+				t.Fatalf("got %d bars, want %d", len(adjusted), len(bars))
+			}
+			for i, bar := range adjusted {
+				if bar.Date != bars[i].Date {
+					t.Errorf("bar %d dated %s, want %s", i, bar.Date, bars[i].Date)
+				}
+				if math.Abs(float64(bar.Close)-tt.wantCloses[i]) > 1e-4 {
+					t.Errorf("%s close = %v, want %v", bar.Date, bar.Close, tt.wantCloses[i])
+				}
+				if math.Abs(float64(bar.Volume)-tt.wantVolumes[i]) > 1e-2 {
+					t.Errorf("%s volume = %v, want %v", bar.Date, bar.Volume, tt.wantVolumes[i])
+				}
+				// Every price of a bar is scaled alike
+				if ratio := float64(bar.High) / float64(bars[i].High); math.Abs(ratio-tt.wantCloses[i]/float64(bars[i].Close)) > 1e-4 {
+					t.Errorf("%s high scaled by %v, close by %v", bar.Date, ratio, tt.wantCloses[i]/float64(bars[i].Close))
+				}
+			}
+		})
+	}
+}
+
+func TestSplitFactor(t *testing.T) {
+	actions := []types.CorporateAction{
+		{Type: types.ActionSplit, ExDate: "2014-06-09", Ratio: 7},
+		{Type: types.ActionDividend, ExDate: "2015-02-05", Amount: 0.47},
+		{Type: types.ActionSplit, ExDate: "2020-08-31", Ratio: 4},
+		// Not effective yet
+		{Type: types.ActionSplit, ExDate: time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02"), Ratio: 2},
+	}
+	tests := []struct {
+		date string
+		want float64
+	}{
+		{"2014-06-06", 28},
+		{"2014-06-09", 4},
+		{"2020-08-28", 4},
+		{"2020-08-31", 1},
+		{"2025-07-01", 1},
+	}
+	for _, tt := range tests {
+		if got := SplitFactor(actions, tt.date); got != tt.want {
+			t.Errorf("SplitFactor(%s) = %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
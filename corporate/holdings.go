@@ -0,0 +1,234 @@
+package corporate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Holdings rebuilds the positions of a user from their transactions. Quantities are converted
+// to today's shares with the splits since each transaction, sells take their share of the cost
+// at the average cost, and dividend transactions add up per symbol. Transactions recorded
+// without a quantity only count towards the cost basis. A position is open from the buy made
+// while nothing was held until it is sold entirely.
+func Holdings(transactions []types.Transaction, actions []types.CorporateAction) []types.Holding {
+	bySymbol := map[string][]types.CorporateAction{}
+	for _, action := range actions {
+		bySymbol[action.Symbol] = append(bySymbol[action.Symbol], action)
+	}
+
+	sorted := append([]types.Transaction{}, transactions...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date < sorted[j].Date })
+
+	positions := map[string]*types.Holding{}
+	for _, t := range sorted {
+		if t.Symbol == "" {
+			continue
+		}
+		holding, ok := positions[t.Symbol]
+		if !ok {
+			holding = &types.Holding{Symbol: t.Symbol}
+			positions[t.Symbol] = holding
+		}
+
+		shares := t.Quantity * SplitFactor(bySymbol[t.Symbol], t.Date)
+		switch t.Type {
+		case "buy":
+			if holding.Shares <= 0 && holding.CostBasis <= 0 {
+				holding.OpenedAt = t.Date
+			}
+			holding.Shares += shares
+			holding.CostBasis += t.Amount
+		case "sell":
+			if holding.Shares > 0 {
+				sold := min(shares, holding.Shares)
+				holding.CostBasis -= holding.CostBasis * sold / holding.Shares
+				holding.Shares -= sold
+			} else {
+				holding.CostBasis = max(holding.CostBasis-t.Amount, 0)
+			}
+			// Float remainders of a full sale do not keep a position open
+			if holding.Shares < 1e-9 && holding.CostBasis < 1e-6 {
+				holding.Shares, holding.CostBasis, holding.OpenedAt = 0, 0, ""
+			}
+		case "dividend":
+			holding.Dividends += t.Amount
+		}
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	holdings := make([]types.Holding, 0, len(positions))
+	for _, holding := range positions {
+		if holding.Shares > 0 {
+			holding.AverageCost = holding.CostBasis / holding.Shares
+		}
+		if opened, err := time.Parse("2006-01-02", holding.OpenedAt); err == nil {
+			holding.HeldDays = int(today.Sub(opened).Hours() / 24)
+		}
+		holdings = append(holdings, *holding)
+	}
+	sort.Slice(holdings, func(i, j int) bool { return holdings[i].Symbol < holdings[j].Symbol })
+	return holdings
+}
+
+// CreditDividends records a dividend transaction for every user holding shares of the
+// symbol before the ex-date of the dividend, paid on its pay date (or ex-date when unknown).
+// Dividends still to go ex are skipped, and crediting again is a no-op. It returns how many
+// users were credited.
+func CreditDividends(ctx context.Context, store db.MongoStorage, dividend types.CorporateAction, actions []types.CorporateAction) (int, error) {
+	if dividend.Type != types.ActionDividend || dividend.Amount <= 0 {
+		return 0, nil
+	}
+	if dividend.ExDate > time.Now().UTC().Format("2006-01-02") {
+		return 0, nil
+	}
+
+	transactionsColl := store.Collection("transactions")
+	cursor, err := transactionsColl.Find(ctx, bson.M{
+		"symbol": dividend.Symbol,
+		"type":   bson.M{"$in": bson.A{"buy", "sell"}},
+		"date":   bson.M{"$lt": dividend.ExDate},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	var transactions []types.Transaction
+	if err := cursor.All(ctx, &transactions); err != nil {
+		return 0, fmt.Errorf("failed to decode transactions: %w", err)
+	}
+
+	// Splits after the ex-date do not change the shares the dividend was paid on
+	var before []types.CorporateAction
+	for _, action := range actions {
+		if action.Symbol == dividend.Symbol && action.ExDate <= dividend.ExDate {
+			before = append(before, action)
+		}
+	}
+
+	byUser := map[primitive.ObjectID][]types.Transaction{}
+	for _, t := range transactions {
+		byUser[t.UserID] = append(byUser[t.UserID], t)
+	}
+
+	payDate := dividend.PayDate
+	if payDate == "" {
+		payDate = dividend.ExDate
+	}
+
+	credited := 0
+	for userID, userTransactions := range byUser {
+		holdings := Holdings(userTransactions, before)
+		if len(holdings) == 0 || holdings[0].Shares <= 0 {
+			continue
+		}
+		shares := holdings[0].Shares
+
+		res, err := transactionsColl.UpdateOne(ctx,
+			bson.M{"user_id": userID, "action_id": dividend.ID},
+			bson.M{"$setOnInsert": types.NewTransaction{
+				Type:     "dividend",
+				Amount:   shares * dividend.Amount,
+				Date:     payDate,
+				Symbol:   dividend.Symbol,
+				UserID:   userID,
+				ActionID: dividend.ID,
+			}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return credited, fmt.Errorf("failed to credit dividend: %w", err)
+		}
+		if res.UpsertedCount > 0 {
+			credited++
+		}
+	}
+	return credited, nil
+}
+
+// Interest is how many users hold a symbol and how many ever traded it
+type Interest struct {
+	Holders int
+	Traders int
+}
+
+// SymbolInterest counts per symbol the users holding shares and the users who traded it. A
+// position is held by its shares, not by the cash put in and taken out, so a position sold
+// at a profit is closed and one sold entirely at a loss is too.
+func SymbolInterest(ctx context.Context, store db.MongoStorage) (map[string]Interest, error) {
+	cursor, err := store.Collection("transactions").Find(ctx, bson.M{
+		"type":   bson.M{"$in": bson.A{"buy", "sell"}},
+		"symbol": bson.M{"$nin": bson.A{"", nil}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	var transactions []types.Transaction
+	if err := cursor.All(ctx, &transactions); err != nil {
+		return nil, fmt.Errorf("failed to decode transactions: %w", err)
+	}
+
+	actions, err := Load(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	return countInterest(transactions, actions), nil
+}
+
+// InterestCache keeps the SymbolInterest counts for the requests ranking by them, since
+// computing them replays every transaction. They are as of the last Refresh.
+type InterestCache struct {
+	store    db.MongoStorage
+	mu       sync.RWMutex
+	interest map[string]Interest
+}
+
+func NewInterestCache(store db.MongoStorage) *InterestCache {
+	return &InterestCache{store: store, interest: map[string]Interest{}}
+}
+
+// Get returns the counts per symbol, empty until the first refresh. They must not be modified.
+func (c *InterestCache) Get() map[string]Interest {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.interest
+}
+
+// Refresh recomputes the counts, the previous ones are kept when it fails
+func (c *InterestCache) Refresh(ctx context.Context) error {
+	interest, err := SymbolInterest(ctx, c.store)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.interest = interest
+	c.mu.Unlock()
+	return nil
+}
+
+func countInterest(transactions []types.Transaction, actions []types.CorporateAction) map[string]Interest {
+	byUser := map[primitive.ObjectID][]types.Transaction{}
+	for _, t := range transactions {
+		byUser[t.UserID] = append(byUser[t.UserID], t)
+	}
+
+	interest := map[string]Interest{}
+	for _, userTransactions := range byUser {
+		for _, holding := range Holdings(userTransactions, actions) {
+			counts := interest[holding.Symbol]
+			counts.Traders++
+			// Transactions recorded without a quantity only leave the cost basis to go by
+			if holding.Shares > 0 || holding.CostBasis > 0 {
+				counts.Holders++
+			}
+			interest[holding.Symbol] = counts
+		}
+	}
+	return interest
+}
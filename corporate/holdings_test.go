@@ -0,0 +1,118 @@
+package corporate
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHoldings(t *testing.T) {
+	split := types.CorporateAction{Symbol: "AAPL", Type: types.ActionSplit, ExDate: "2020-08-31", Ratio: 4}
+	tests := []struct {
+		name         string
+		transactions []types.Transaction
+		wantShares   float64
+		wantCost     float64
+		wantOpened   string
+	}{
+		{
+			name: "buys add up",
+			transactions: []types.Transaction{
+				{Type: "buy", Symbol: "AAPL", Quantity: 2, Amount: 200, Date: "2025-01-02"},
+				{Type: "buy", Symbol: "AAPL", Quantity: 1, Amount: 130, Date: "2025-02-03"},
+			},
+			wantShares: 3, wantCost: 330, wantOpened: "2025-01-02",
+		},
+		{
+			name: "sells take the average cost",
+			transactions: []types.Transaction{
+				{Type: "buy", Symbol: "AAPL", Quantity: 4, Amount: 400, Date: "2025-01-02"},
+				{Type: "sell", Symbol: "AAPL", Quantity: 1, Amount: 150, Date: "2025-03-03"},
+			},
+			wantShares: 3, wantCost: 300, wantOpened: "2025-01-02",
+		},
+		{
+			name: "shares bought before a split follow it",
+			transactions: []types.Transaction{
+				{Type: "buy", Symbol: "AAPL", Quantity: 1, Amount: 400, Date: "2020-08-03"},
+				{Type: "sell", Symbol: "AAPL", Quantity: 2, Amount: 250, Date: "2020-09-01"},
+			},
+			wantShares: 2, wantCost: 200, wantOpened: "2020-08-03",
+		},
+		{
+			name: "a full sale closes the position",
+			transactions: []types.Transaction{
+				{Type: "buy", Symbol: "AAPL", Quantity: 3, Amount: 300, Date: "2025-01-02"},
+				{Type: "sell", Symbol: "AAPL", Quantity: 3, Amount: 100, Date: "2025-03-03"},
+			},
+		},
+		{
+			name: "a new buy reopens it",
+			transactions: []types.Transaction{
+				{Type: "sell", Symbol: "AAPL", Quantity: 3, Amount: 360, Date: "2025-03-03"},
+				{Type: "buy", Symbol: "AAPL", Quantity: 1, Amount: 120, Date: "2025-04-01"},
+				{Type: "buy", Symbol: "AAPL", Quantity: 3, Amount: 300, Date: "2025-01-02"},
+			},
+			wantShares: 1, wantCost: 120, wantOpened: "2025-04-01",
+		},
+		{
+			name: "transactions without a quantity only count towards the cost",
+			transactions: []types.Transaction{
+				{Type: "buy", Symbol: "AAPL", Amount: 500, Date: "2025-01-02"},
+				{Type: "sell", Symbol: "AAPL", Amount: 200, Date: "2025-03-03"},
+			},
+			wantCost: 300, wantOpened: "2025-01-02",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			holdings := Holdings(tt.transactions, []types.CorporateAction{split})
+			if len(holdings) != 1 {
+				t.Fatalf("got %d holdings, want 1", len(holdings))
+			}
+			got := holdings[0]
+			if math.Abs(got.Shares-tt.wantShares) > 1e-9 || math.Abs(got.CostBasis-tt.wantCost) > 1e-6 {
+				t.Errorf("shares %v at cost %v, want %v at %v", got.Shares, got.CostBasis, tt.wantShares, tt.wantCost)
+			}
+			if got.OpenedAt != tt.wantOpened {
+				t.Errorf("opened at %q, want %q", got.OpenedAt, tt.wantOpened)
+			}
+		})
+	}
+}
+
+func TestCountInterest(t *testing.T) {
+	alice, bob, carol := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	transactions := []types.Transaction{
+		// Holding AAPL, and sold MSFT entirely at a profit
+		{UserID: alice, Type: "buy", Symbol: "AAPL", Quantity: 2, Amount: 300, Date: "2025-01-02"},
+		{UserID: alice, Type: "buy", Symbol: "MSFT", Quantity: 1, Amount: 400, Date: "2025-01-02"},
+		{UserID: alice, Type: "sell", Symbol: "MSFT", Quantity: 1, Amount: 500, Date: "2025-03-03"},
+		// Sold AAPL entirely at a loss
+		{UserID: bob, Type: "buy", Symbol: "AAPL", Quantity: 2, Amount: 300, Date: "2025-01-02"},
+		{UserID: bob, Type: "sell", Symbol: "AAPL", Quantity: 2, Amount: 200, Date: "2025-03-03"},
+		// Still holding part of MSFT after selling at a profit
+		{UserID: carol, Type: "buy", Symbol: "MSFT", Quantity: 4, Amount: 1600, Date: "2025-01-02"},
+		{UserID: carol, Type: "sell", Symbol: "MSFT", Quantity: 3, Amount: 1800, Date: "2025-03-03"},
+	}
+
+	got := countInterest(transactions, nil)
+	want := map[string]Interest{
+		"AAPL": {Holders: 1, Traders: 2},
+		"MSFT": {Holders: 1, Traders: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("countInterest() = %+v, want %+v", got, want)
+	}
+}
+
+func TestInterestCacheEmptyUntilRefreshed(t *testing.T) {
+	cache := NewInterestCache(db.MongoStorage{})
+	if interest := cache.Get(); interest == nil || len(interest) != 0 {
+		t.Errorf("Get() before a refresh = %v, want an empty map", interest)
+	}
+}
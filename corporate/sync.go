@@ -0,0 +1,77 @@
+package corporate
+
+import (
+	"context"
+	"fmt"
+
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/marketdata"
+	"github.com/arcedo/financial-ai-backend/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Budget hands out the provider calls, see stocksync.Budget
+type Budget interface {
+	Reserve(ctx context.Context) error
+}
+
+// SyncResult counts what a sync of a symbol changed
+type SyncResult struct {
+	NewActions int
+	Credited   int
+}
+
+// Sync fetches the splits and dividends of symbol, stores them and credits the dividends that
+// went ex to the users who held the symbol. Every provider call is reserved from budget first.
+func Sync(ctx context.Context, store db.MongoStorage, provider marketdata.ActionProvider, budget Budget, symbol string) (SyncResult, error) {
+	var result SyncResult
+	var fetched []types.CorporateAction
+	for _, fetch := range []func(context.Context, string) ([]types.CorporateAction, error){provider.Splits, provider.Dividends} {
+		if err := budget.Reserve(ctx); err != nil {
+			return result, err
+		}
+		actions, err := fetch(ctx, symbol)
+		if err != nil {
+			return result, fmt.Errorf("failed to fetch the corporate actions of %s: %w", symbol, err)
+		}
+		fetched = append(fetched, actions...)
+	}
+
+	var err error
+	if result.NewActions, err = Upsert(ctx, store, fetched); err != nil {
+		return result, err
+	}
+
+	// Reloaded for their IDs, which make crediting idempotent
+	actions, err := Load(ctx, store, symbol)
+	if err != nil {
+		return result, err
+	}
+	for _, action := range actions {
+		credited, err := CreditDividends(ctx, store, action, actions)
+		result.Credited += credited
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// TradedSymbols returns the symbols users have bought or sold
+func TradedSymbols(ctx context.Context, store db.MongoStorage) ([]string, error) {
+	values, err := store.Collection("transactions").Distinct(ctx, "symbol", bson.M{
+		"type":   bson.M{"$in": bson.A{"buy", "sell"}},
+		"symbol": bson.M{"$nin": bson.A{"", nil}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list traded symbols: %w", err)
+	}
+
+	symbols := make([]string, 0, len(values))
+	for _, value := range values {
+		if symbol, ok := value.(string); ok {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols, nil
+}
@@ -2,51 +2,52 @@ package data
 
 import "github.com/arcedo/financial-ai-backend/types"
 
+// Products are seeded into the products collection on startup, see config.ProductsConfig
 var Products = []types.Product{
-	{Symbol: "TSLA", Name: "Tesla Inc."},
-	{Symbol: "SQQQ", Name: "ProShares UltraPro Short QQQ ETF"},
-	{Symbol: "TQQQ", Name: "ProShares UltraPro QQQ"},
-	{Symbol: "NVDA", Name: "NVSymbolIA Corporation"},
-	{Symbol: "SOXS", Name: "Direxion Daily Semiconductor Bear 3X Shares"},
-	{Symbol: "SOXL", Name: "Direxion Daily Semiconductor Bull 3X Shares"},
-	{Symbol: "AAPL", Name: "Apple Inc."},
-	{Symbol: "TSM", Name: "Taiwan Semiconductor Manufacturing Co. Ltd."},
-	{Symbol: "QQQ", Name: "Invesco QQQ Trust Series 1"},
-	{Symbol: "AMZN", Name: "Amazon.com, Inc."},
-	{Symbol: "AMD", Name: "Advanced Micro Devices, Inc."},
-	{Symbol: "MSFT", Name: "Microsoft Corp"},
-	{Symbol: "SDOW", Name: "ProShares UltraPro Short Dow 30 ETF"},
-	{Symbol: "COMS", Name: "COMSovereign Holding Corp"},
-	{Symbol: "XELA", Name: "Exela Technologies Inc"},
-	{Symbol: "VOO", Name: "Vanguard 500 Index Fund ETF"},
-	{Symbol: "GMBL", Name: "Esports Entertainment Group Inc"},
-	{Symbol: "BOIL", Name: "ProShares Ultra Bloomberg Natural Gas"},
-	{Symbol: "UVXY", Name: "ProShares Ultra VIX Short-Term Futures ETF"},
-	{Symbol: "VTI", Name: "Vanguard Total Stock Market Index Fund ETF"},
-	{Symbol: "SPY", Name: "SPDR S&P 500 ETF Trust"},
-	{Symbol: "GOOGL", Name: "Alphabet Inc Class A"},
-	{Symbol: "TLT", Name: "iShares 20 Plus Year Treasury Bond"},
-	{Symbol: "BABA", Name: "Alibaba Group Holding Ltd - ADR"},
-	{Symbol: "SPXU", Name: "ProShares UltraPro Short S&P500"},
-	{Symbol: "SPOT", Name: "Spotify Technology S.A."},
-	{Symbol: "PLTR", Name: "Palantir Technologies Inc."},
-	{Symbol: "SMCI", Name: "Super Micro Computer Inc."},
-	{Symbol: "DJT", Name: "Trump Media & Technology Group Corp"},
-	{Symbol: "TSLL", Name: "Direxion Daily TSLA Bull 2X Shares"},
-	{Symbol: "YINN", Name: "Direxion Daily FTSE China Bull 3X Shares"},
-	{Symbol: "TMF", Name: "Direxion Daily 20+ Year Treasury Bull 3X Shares ETF"},
-	{Symbol: "AMIX", Name: "Autonomix Medical Inc."},
-	{Symbol: "XLG", Name: "Invesco S&P 500 Top 50 ETF"},
-	{Symbol: "HYG", Name: "iShares iBoxx $ High Yield Corporate Bond"},
-	{Symbol: "XLF", Name: "Financial Select Sector SPDR"},
-	{Symbol: "FXI", Name: "iShares China Large-Cap"},
-	{Symbol: "LQD", Name: "iShares iBoxx $ Investment Grade Corporate Bond"},
-	{Symbol: "SLV", Name: "iShares Silver"},
-	{Symbol: "EEM", Name: "iShares MSCI Emerging Markets"},
-	{Symbol: "EWZ", Name: "iShares MSCI Brazil Capped"},
-	{Symbol: "IWM", Name: "iShares Russell 2000"},
-	{Symbol: "GDX", Name: "VanEck Vectors Gold Miners"},
-	{Symbol: "KWEB", Name: "KraneShares CSI China Internet"},
-	{Symbol: "TZA", Name: "Direxion Daily Small Cap Bear 3X Shares"},
-	{Symbol: "SCHD", Name: "Schwab US DivSymbolend Equity"},
+	{Symbol: "TSLA", Name: "Tesla Inc.", AssetClass: types.AssetStock, Sector: "Consumer Discretionary", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "SQQQ", Name: "ProShares UltraPro Short QQQ ETF", AssetClass: types.AssetETF, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 3, Inverse: true, Underlying: "QQQ"},
+	{Symbol: "TQQQ", Name: "ProShares UltraPro QQQ", AssetClass: types.AssetETF, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 3, Underlying: "QQQ"},
+	{Symbol: "NVDA", Name: "NVIDIA Corporation", AssetClass: types.AssetStock, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "SOXS", Name: "Direxion Daily Semiconductor Bear 3X Shares", AssetClass: types.AssetETF, Sector: "Semiconductors", Exchange: "NYSE Arca", Currency: "USD", Leverage: 3, Inverse: true},
+	{Symbol: "SOXL", Name: "Direxion Daily Semiconductor Bull 3X Shares", AssetClass: types.AssetETF, Sector: "Semiconductors", Exchange: "NYSE Arca", Currency: "USD", Leverage: 3},
+	{Symbol: "AAPL", Name: "Apple Inc.", AssetClass: types.AssetStock, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "TSM", Name: "Taiwan Semiconductor Manufacturing Co. Ltd.", AssetClass: types.AssetStock, Sector: "Semiconductors", Exchange: "NYSE", Currency: "USD", Leverage: 1},
+	{Symbol: "QQQ", Name: "Invesco QQQ Trust Series 1", AssetClass: types.AssetETF, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "AMZN", Name: "Amazon.com, Inc.", AssetClass: types.AssetStock, Sector: "Consumer Discretionary", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "AMD", Name: "Advanced Micro Devices, Inc.", AssetClass: types.AssetStock, Sector: "Semiconductors", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "MSFT", Name: "Microsoft Corp", AssetClass: types.AssetStock, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "SDOW", Name: "ProShares UltraPro Short Dow 30 ETF", AssetClass: types.AssetETF, Sector: "Broad Market", Exchange: "NYSE Arca", Currency: "USD", Leverage: 3, Inverse: true},
+	{Symbol: "COMS", Name: "COMSovereign Holding Corp", AssetClass: types.AssetStock, Sector: "Communication Services", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "XELA", Name: "Exela Technologies Inc", AssetClass: types.AssetStock, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "VOO", Name: "Vanguard 500 Index Fund ETF", AssetClass: types.AssetETF, Sector: "Broad Market", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "GMBL", Name: "Esports Entertainment Group Inc", AssetClass: types.AssetStock, Sector: "Consumer Discretionary", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "BOIL", Name: "ProShares Ultra Bloomberg Natural Gas", AssetClass: types.AssetETF, Sector: "Energy", Exchange: "NYSE Arca", Currency: "USD", Leverage: 2},
+	{Symbol: "UVXY", Name: "ProShares Ultra VIX Short-Term Futures ETF", AssetClass: types.AssetETF, Sector: "Volatility", Exchange: "CBOE", Currency: "USD", Leverage: 1.5},
+	{Symbol: "VTI", Name: "Vanguard Total Stock Market Index Fund ETF", AssetClass: types.AssetETF, Sector: "Broad Market", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "SPY", Name: "SPDR S&P 500 ETF Trust", AssetClass: types.AssetETF, Sector: "Broad Market", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "GOOGL", Name: "Alphabet Inc Class A", AssetClass: types.AssetStock, Sector: "Communication Services", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "TLT", Name: "iShares 20 Plus Year Treasury Bond", AssetClass: types.AssetETF, Sector: "Government Bonds", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "BABA", Name: "Alibaba Group Holding Ltd - ADR", AssetClass: types.AssetStock, Sector: "Consumer Discretionary", Exchange: "NYSE", Currency: "USD", Leverage: 1},
+	{Symbol: "SPXU", Name: "ProShares UltraPro Short S&P500", AssetClass: types.AssetETF, Sector: "Broad Market", Exchange: "NYSE Arca", Currency: "USD", Leverage: 3, Inverse: true, Underlying: "SPY"},
+	{Symbol: "SPOT", Name: "Spotify Technology S.A.", AssetClass: types.AssetStock, Sector: "Communication Services", Exchange: "NYSE", Currency: "USD", Leverage: 1},
+	{Symbol: "PLTR", Name: "Palantir Technologies Inc.", AssetClass: types.AssetStock, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "SMCI", Name: "Super Micro Computer Inc.", AssetClass: types.AssetStock, Sector: "Technology", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "DJT", Name: "Trump Media & Technology Group Corp", AssetClass: types.AssetStock, Sector: "Communication Services", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "TSLL", Name: "Direxion Daily TSLA Bull 2X Shares", AssetClass: types.AssetETF, Sector: "Consumer Discretionary", Exchange: "NASDAQ", Currency: "USD", Leverage: 2, Underlying: "TSLA"},
+	{Symbol: "YINN", Name: "Direxion Daily FTSE China Bull 3X Shares", AssetClass: types.AssetETF, Sector: "China", Exchange: "NYSE Arca", Currency: "USD", Leverage: 3, Underlying: "FXI"},
+	{Symbol: "TMF", Name: "Direxion Daily 20+ Year Treasury Bull 3X Shares ETF", AssetClass: types.AssetETF, Sector: "Government Bonds", Exchange: "NYSE Arca", Currency: "USD", Leverage: 3, Underlying: "TLT"},
+	{Symbol: "AMIX", Name: "Autonomix Medical Inc.", AssetClass: types.AssetStock, Sector: "Health Care", Exchange: "NASDAQ", Currency: "USD", Leverage: 1},
+	{Symbol: "XLG", Name: "Invesco S&P 500 Top 50 ETF", AssetClass: types.AssetETF, Sector: "Broad Market", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "HYG", Name: "iShares iBoxx $ High Yield Corporate Bond", AssetClass: types.AssetETF, Sector: "Corporate Bonds", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "XLF", Name: "Financial Select Sector SPDR", AssetClass: types.AssetETF, Sector: "Financials", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "FXI", Name: "iShares China Large-Cap", AssetClass: types.AssetETF, Sector: "China", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "LQD", Name: "iShares iBoxx $ Investment Grade Corporate Bond", AssetClass: types.AssetETF, Sector: "Corporate Bonds", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "SLV", Name: "iShares Silver", AssetClass: types.AssetETF, Sector: "Precious Metals", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "EEM", Name: "iShares MSCI Emerging Markets", AssetClass: types.AssetETF, Sector: "Emerging Markets", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "EWZ", Name: "iShares MSCI Brazil Capped", AssetClass: types.AssetETF, Sector: "Brazil", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "IWM", Name: "iShares Russell 2000", AssetClass: types.AssetETF, Sector: "Small Cap", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "GDX", Name: "VanEck Vectors Gold Miners", AssetClass: types.AssetETF, Sector: "Materials", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "KWEB", Name: "KraneShares CSI China Internet", AssetClass: types.AssetETF, Sector: "China", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
+	{Symbol: "TZA", Name: "Direxion Daily Small Cap Bear 3X Shares", AssetClass: types.AssetETF, Sector: "Small Cap", Exchange: "NYSE Arca", Currency: "USD", Leverage: 3, Inverse: true, Underlying: "IWM"},
+	{Symbol: "SCHD", Name: "Schwab US Dividend Equity", AssetClass: types.AssetETF, Sector: "Dividend", Exchange: "NYSE Arca", Currency: "USD", Leverage: 1},
 }
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/arcedo/financial-ai-backend/metrics"
 	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -22,7 +25,7 @@ func NewMongoStorage(uri, dbName string) (*MongoStorage, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	clientOpts := options.Client().ApplyURI(uri)
+	clientOpts := options.Client().ApplyURI(uri).SetMonitor(commandMonitor())
 	client, err := mongo.Connect(ctx, clientOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
@@ -37,35 +40,263 @@ func NewMongoStorage(uri, dbName string) (*MongoStorage, error) {
 	return &MongoStorage{client: client, database: db}, nil
 }
 
+// commandMonitor records the timing of every Mongo command and logs it at debug level with
+// the logger of the request that issued it, so database calls show up under the same request ID
+func commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			metrics.MongoDuration.WithLabelValues(evt.CommandName).Observe(evt.Duration.Seconds())
+			metrics.MongoCommands.WithLabelValues(evt.CommandName, "success").Inc()
+			utils.LoggerFrom(ctx).Debug("mongo command",
+				"command", evt.CommandName,
+				"database", evt.DatabaseName,
+				"duration_ms", evt.Duration.Milliseconds(),
+			)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			metrics.MongoDuration.WithLabelValues(evt.CommandName).Observe(evt.Duration.Seconds())
+			metrics.MongoCommands.WithLabelValues(evt.CommandName, "error").Inc()
+			utils.LoggerFrom(ctx).Warn("mongo command failed",
+				"command", evt.CommandName,
+				"database", evt.DatabaseName,
+				"duration_ms", evt.Duration.Milliseconds(),
+				"error", evt.Failure,
+			)
+		},
+	}
+}
+
 // Collection returns a reference to a MongoDB collection
 func (m *MongoStorage) Collection(name string) *mongo.Collection {
 	return m.database.Collection(name)
 }
 
+// Ping checks that the database is reachable
+func (m *MongoStorage) Ping(ctx context.Context) error {
+	return m.client.Ping(ctx, nil)
+}
+
 // Close gracefully disconnects the MongoDB client
 func (m *MongoStorage) Close(ctx context.Context) error {
 	return m.client.Disconnect(ctx)
 }
 
-func (m *MongoStorage) InitProducts(ctx context.Context, collection string, products []types.Product) error {
+// InitProducts creates the unique symbol index and seeds the products. In "reconcile" mode
+// the missing products are added and stored ones get the fields they lack from the seed, while
+// fields already set, e.g. by an admin, are kept. "once" only seeds an empty collection, "off"
+// only creates the index.
+func (m *MongoStorage) InitProducts(ctx context.Context, collection string, products []types.Product, mode string) error {
 	col := m.database.Collection(collection)
 
-	count, err := col.CountDocuments(ctx, bson.D{})
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "symbol", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("symbol_unique"),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to count documents: %w", err)
+		return fmt.Errorf("failed to create product indexes: %w", err)
+	}
+
+	switch mode {
+	case "off":
+		return nil
+	case "once":
+		count, err := col.CountDocuments(ctx, bson.D{})
+		if err != nil {
+			return fmt.Errorf("failed to count documents: %w", err)
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	models := make([]mongo.WriteModel, 0, len(products))
+	for _, product := range products {
+		raw, err := bson.Marshal(product)
+		if err != nil {
+			return fmt.Errorf("failed to encode product %s: %w", product.Symbol, err)
+		}
+		var fields bson.M
+		if err := bson.Unmarshal(raw, &fields); err != nil {
+			return fmt.Errorf("failed to encode product %s: %w", product.Symbol, err)
+		}
+
+		// A pipeline update so each field is only set where it is missing
+		set := bson.M{}
+		for key, value := range fields {
+			set[key] = bson.M{"$ifNull": bson.A{"$" + key, bson.M{"$literal": value}}}
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"symbol": product.Symbol}).
+			SetUpdate(mongo.Pipeline{{{Key: "$set", Value: set}}}).
+			SetUpsert(true))
 	}
-	if count > 0 {
+
+	if len(models) == 0 {
 		return nil
 	}
+	if _, err := col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to seed products: %w", err)
+	}
+
+	return nil
+}
+
+// InitUsers creates the unique index that keeps an email to a single user. It fails on
+// databases where several users share an email, which have to be merged by hand first.
+func (m *MongoStorage) InitUsers(ctx context.Context, collection string) error {
+	col := m.database.Collection(collection)
 
-	docs := make([]interface{}, len(products))
-	for i, product := range products {
-		docs[i] = product
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("email_unique"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create user indexes: %w", err)
 	}
 
-	_, err = col.InsertMany(ctx, docs)
+	return nil
+}
+
+// InitSessions creates the indexes used to look up sessions and expire them with their token
+func (m *MongoStorage) InitSessions(ctx context.Context, collection string) error {
+	col := m.database.Collection(collection)
+
+	_, err := col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_seen_at", Value: -1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session indexes: %w", err)
+	}
+
+	return nil
+}
+
+// InitRateLimits creates the index that drops rate limit counters once they no longer matter
+func (m *MongoStorage) InitRateLimits(ctx context.Context, collection string) error {
+	col := m.database.Collection(collection)
+
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create rate limit indexes: %w", err)
+	}
+
+	return nil
+}
+
+// InitStocks creates the unique index that keeps a single bar per symbol and day. It fails on
+// databases holding duplicates, which DedupeStocks removes.
+func (m *MongoStorage) InitStocks(ctx context.Context, collection string) error {
+	col := m.database.Collection(collection)
+
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("symbol_date_unique"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create stock indexes: %w", err)
+	}
+
+	return nil
+}
+
+// DedupeStocks deletes the duplicated bars of a symbol and day, keeping the first one stored,
+// and returns how many were deleted
+func (m *MongoStorage) DedupeStocks(ctx context.Context, collection string) (int64, error) {
+	col := m.database.Collection(collection)
+
+	cursor, err := col.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"symbol": "$symbol", "date": "$date"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find duplicated stocks: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var deleted int64
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs []any `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return deleted, fmt.Errorf("failed to decode duplicated stocks: %w", err)
+		}
+
+		res, err := col.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete duplicated stocks: %w", err)
+		}
+		deleted += res.DeletedCount
+	}
+	if err := cursor.Err(); err != nil {
+		return deleted, fmt.Errorf("failed to read duplicated stocks: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// InitStockSync creates the indexes of the stock sync run history and provider call counters
+func (m *MongoStorage) InitStockSync(ctx context.Context) error {
+	_, err := m.database.Collection("sync_runs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "provider", Value: 1}, {Key: "status", Value: 1}, {Key: "started_at", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create sync run indexes: %w", err)
+	}
+
+	_, err = m.database.Collection("provider_usage").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create provider usage indexes: %w", err)
+	}
+
+	return nil
+}
+
+// InitCorporateActions creates the index keeping one action per symbol, type and ex-date, and
+// the one making sure a dividend is credited once per user
+func (m *MongoStorage) InitCorporateActions(ctx context.Context) error {
+	_, err := m.database.Collection("corporate_actions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "type", Value: 1}, {Key: "ex_date", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("symbol_type_ex_date_unique"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create corporate action indexes: %w", err)
+	}
+
+	_, err = m.database.Collection("transactions").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "action_id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("user_action_unique").
+			SetPartialFilterExpression(bson.M{"action_id": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create dividend transaction indexes: %w", err)
+	}
+
+	return nil
+}
+
+// InitIdempotencyKeys creates the index that forgets idempotency keys once their TTL is over
+func (m *MongoStorage) InitIdempotencyKeys(ctx context.Context, collection string) error {
+	col := m.database.Collection(collection)
+
+	_, err := col.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to insert products: %w", err)
+		return fmt.Errorf("failed to create idempotency key indexes: %w", err)
 	}
 
 	return nil
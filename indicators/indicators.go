@@ -0,0 +1,187 @@
+// Package indicators computes technical indicators over daily bars. Every function takes
+// values sorted oldest first and returns a series of the same length, NaN where the indicator
+// is not defined yet because the window is not full.
+package indicators
+
+import (
+	"math"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+// TradingDays annualizes daily volatility
+const TradingDays = 252
+
+// Closes returns the closing prices of the bars
+func Closes(bars []types.Bar) []float64 {
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		closes[i] = float64(bar.Close)
+	}
+	return closes
+}
+
+// SMA is the simple moving average over period values
+func SMA(values []float64, period int) []float64 {
+	out := undefined(len(values))
+	sum := 0.0
+	for i, value := range values {
+		sum += value
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA is the exponential moving average with smoothing 2/(period+1), seeded with the SMA of
+// the first period values. NaN inputs are skipped, so an EMA can be taken of another series.
+func EMA(values []float64, period int) []float64 {
+	out := undefined(len(values))
+	alpha := 2 / float64(period+1)
+
+	seen, sum, previous := 0, 0.0, math.NaN()
+	for i, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		seen++
+		switch {
+		case seen < period:
+			sum += value
+			continue
+		case seen == period:
+			previous = (sum + value) / float64(period)
+		default:
+			previous = alpha*value + (1-alpha)*previous
+		}
+		out[i] = previous
+	}
+	return out
+}
+
+// RSI is the relative strength index with Wilder's smoothing, between 0 and 100
+func RSI(values []float64, period int) []float64 {
+	out := undefined(len(values))
+	if len(values) <= period {
+		return out
+	}
+
+	var avgGain, avgLoss float64
+	for i := 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain, loss := math.Max(change, 0), math.Max(-change, 0)
+		if i <= period {
+			avgGain += gain / float64(period)
+			avgLoss += loss / float64(period)
+			if i < period {
+				continue
+			}
+		} else {
+			avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+			avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		}
+
+		if avgLoss == 0 {
+			out[i] = 100
+		} else {
+			out[i] = 100 - 100/(1+avgGain/avgLoss)
+		}
+	}
+	return out
+}
+
+// MACD returns the difference of the fast and slow EMAs, its signal EMA and the histogram
+// between both
+func MACD(values []float64, fast, slow, signal int) (macd, signalLine, histogram []float64) {
+	fastEMA, slowEMA := EMA(values, fast), EMA(values, slow)
+	macd = make([]float64, len(values))
+	for i := range values {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+
+	signalLine = EMA(macd, signal)
+	histogram = make([]float64, len(values))
+	for i := range values {
+		histogram[i] = macd[i] - signalLine[i]
+	}
+	return macd, signalLine, histogram
+}
+
+// Bollinger returns the SMA over period and the bands k population standard deviations away
+func Bollinger(values []float64, period int, k float64) (upper, middle, lower []float64) {
+	middle = SMA(values, period)
+	upper, lower = undefined(len(values)), undefined(len(values))
+	for i := period - 1; i < len(values); i++ {
+		variance := 0.0
+		for _, value := range values[i-period+1 : i+1] {
+			variance += (value - middle[i]) * (value - middle[i])
+		}
+		deviation := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + k*deviation
+		lower[i] = middle[i] - k*deviation
+	}
+	return upper, middle, lower
+}
+
+// ATR is the average true range with Wilder's smoothing, in price units
+func ATR(bars []types.Bar, period int) []float64 {
+	out := undefined(len(bars))
+	atr := 0.0
+	for i := 1; i < len(bars); i++ {
+		high, low, previousClose := float64(bars[i].High), float64(bars[i].Low), float64(bars[i-1].Close)
+		trueRange := math.Max(high-low, math.Max(math.Abs(high-previousClose), math.Abs(low-previousClose)))
+
+		if i <= period {
+			atr += trueRange / float64(period)
+			if i < period {
+				continue
+			}
+		} else {
+			atr = (atr*float64(period-1) + trueRange) / float64(period)
+		}
+		out[i] = atr
+	}
+	return out
+}
+
+// Volatility is the annualized sample standard deviation of the daily log returns over period
+// returns
+func Volatility(values []float64, period int) []float64 {
+	out := undefined(len(values))
+	if period < 2 {
+		return out
+	}
+
+	returns := make([]float64, len(values))
+	for i := 1; i < len(values); i++ {
+		if values[i-1] > 0 && values[i] > 0 {
+			returns[i] = math.Log(values[i] / values[i-1])
+		}
+	}
+
+	for i := period; i < len(values); i++ {
+		window := returns[i-period+1 : i+1]
+		mean := 0.0
+		for _, r := range window {
+			mean += r / float64(period)
+		}
+		variance := 0.0
+		for _, r := range window {
+			variance += (r - mean) * (r - mean)
+		}
+		out[i] = math.Sqrt(variance/float64(period-1)) * math.Sqrt(TradingDays)
+	}
+	return out
+}
+
+func undefined(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
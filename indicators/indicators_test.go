@@ -0,0 +1,151 @@
+package indicators
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+var nan = math.NaN()
+
+func equalSeries(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.IsNaN(want[i]) != math.IsNaN(got[i]) || !math.IsNaN(want[i]) && math.Abs(got[i]-want[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSeries(t *testing.T) {
+	bars := []types.Bar{
+		{Date: "2025-07-01", High: 10, Low: 8, Close: 9},
+		{Date: "2025-07-02", High: 11, Low: 9, Close: 10},
+		{Date: "2025-07-03", High: 12, Low: 10, Close: 11},
+		{Date: "2025-07-07", High: 15, Low: 13, Close: 14},
+	}
+	upper, middle, lower := Bollinger([]float64{1, 3, 5}, 2, 2)
+	_, _, short := Bollinger([]float64{1}, 2, 2)
+
+	tests := []struct {
+		name string
+		got  []float64
+		want []float64
+	}{
+		{"SMA", SMA([]float64{1, 2, 3, 4, 5, 6}, 3), []float64{nan, nan, 2, 3, 4, 5}},
+		{"SMA longer than the values", SMA([]float64{1, 2}, 3), []float64{nan, nan}},
+		{"EMA seeded with the SMA", EMA([]float64{2, 4, 6, 8, 4}, 3), []float64{nan, nan, 4, 6, 5}},
+		{"EMA skips undefined values", EMA([]float64{nan, nan, 2, 4, 6, 8}, 2), []float64{nan, nan, nan, 3, 5, 7}},
+		{"RSI", RSI([]float64{1, 2, 3, 2, 3}, 2), []float64{nan, nan, 100, 50, 75}},
+		{"RSI without enough values", RSI([]float64{1, 2}, 2), []float64{nan, nan}},
+		{"Bollinger upper", upper, []float64{nan, 4, 6}},
+		{"Bollinger middle", middle, []float64{nan, 2, 4}},
+		{"Bollinger lower", lower, []float64{nan, 0, 2}},
+		{"Bollinger without enough values", short, []float64{nan}},
+		{"ATR", ATR(bars, 2), []float64{nan, nan, 2, 3}},
+		{"Volatility of a steady trend", Volatility([]float64{1, 2, 4, 8}, 2), []float64{nan, nan, 0, 0}},
+		{"Volatility", Volatility([]float64{100, 110, 99}, 2), []float64{nan, nan, math.Abs(math.Log(1.1)-math.Log(0.9)) / math.Sqrt2 * math.Sqrt(TradingDays)}},
+		{"Volatility needs two returns", Volatility([]float64{1, 2, 4}, 1), []float64{nan, nan, nan}},
+		{"Closes", Closes(bars), []float64{9, 10, 11, 14}},
+	}
+	for _, tt := range tests {
+		if !equalSeries(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestMACD(t *testing.T) {
+	values := make([]float64, 40)
+	for i := range values {
+		values[i] = 50
+	}
+	macd, signal, histogram := MACD(values, 12, 26, 9)
+
+	// The slow EMA needs 26 values and the signal 9 more MACD values
+	if !math.IsNaN(macd[24]) || macd[25] != 0 {
+		t.Errorf("macd[24:26] = %v, want NaN then 0", macd[24:26])
+	}
+	if !math.IsNaN(signal[32]) || signal[33] != 0 || histogram[39] != 0 {
+		t.Errorf("signal[32:34] = %v, histogram[39] = %v", signal[32:34], histogram[39])
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Spec
+		wantErr string
+	}{
+		{"sma", Spec{Name: "sma", Period: 20}, ""},
+		{" SMA:50 ", Spec{Name: "sma", Period: 50}, ""},
+		{"rsi", Spec{Name: "rsi", Period: 14}, ""},
+		{"macd", Spec{Name: "macd"}, ""},
+		{"macd:10", Spec{}, "does not take a period"},
+		{"vwap", Spec{}, "unknown indicator"},
+		{"ema:1", Spec{}, "invalid period"},
+		{"ema:501", Spec{}, "invalid period"},
+		{"ema:abc", Spec{}, "invalid period"},
+	}
+	for _, tt := range tests {
+		got, err := ParseSpec(tt.text)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseSpec(%q) error = %v, want %q", tt.text, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSpec(%q) = %+v, %v, want %+v", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	bars := make([]types.Bar, 30)
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	for i := range bars {
+		price := float32(100 + i)
+		bars[i] = types.Bar{Date: start.AddDate(0, 0, i).Format("2006-01-02"), High: price + 1, Low: price - 1, Close: price}
+	}
+
+	result := Compute(bars, []Spec{{Name: "sma", Period: 5}, {Name: "bollinger", Period: 20}, {Name: "macd"}})
+	var keys []string
+	for key := range result {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	want := []string{"bollinger_20_lower", "bollinger_20_middle", "bollinger_20_upper", "macd", "macd_histogram", "macd_signal", "sma_5"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("keys = %v, want %v", keys, want)
+	}
+
+	// Only the defined values are returned, dated like their bar
+	if sma := result["sma_5"]; len(sma) != 26 || sma[0].Date != bars[4].Date || sma[0].Value != 102 {
+		t.Errorf("sma_5 has %d values starting with %+v", len(sma), sma[0])
+	}
+	if len(result["macd_signal"]) != 0 {
+		t.Errorf("macd_signal has %d values with 30 bars, want none", len(result["macd_signal"]))
+	}
+
+	snapshot := Snapshot("AAPL", bars)
+	if snapshot.Date != bars[29].Date || snapshot.Close != 129 {
+		t.Errorf("snapshot of %s closing %v", snapshot.Date, snapshot.Close)
+	}
+	if _, ok := snapshot.Values["sma_50"]; ok {
+		t.Error("sma_50 is not defined over 30 bars")
+	}
+	if got := snapshot.Values["sma_20"]; got != 119.5 {
+		t.Errorf("sma_20 = %v, want 119.5", got)
+	}
+	if empty := Snapshot("AAPL", nil); empty.Date != "" || len(empty.Values) != 0 {
+		t.Errorf("snapshot without bars = %+v", empty)
+	}
+}
@@ -0,0 +1,148 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+// defaultPeriods lists the supported indicators and the period used when none is given
+var defaultPeriods = map[string]int{
+	"sma":        20,
+	"ema":        20,
+	"rsi":        14,
+	"macd":       0,
+	"bollinger":  20,
+	"atr":        14,
+	"volatility": 20,
+}
+
+// maxPeriod bounds the requested periods, longer windows have no use on daily bars
+const maxPeriod = 500
+
+// Spec is an indicator with its period, written "name" or "name:period" (e.g. "sma:50")
+type Spec struct {
+	Name   string
+	Period int
+}
+
+// Names returns the names of the supported indicators
+func Names() []string {
+	return []string{"sma", "ema", "rsi", "macd", "bollinger", "atr", "volatility"}
+}
+
+// ParseSpec parses "name" or "name:period". MACD always uses 12, 26 and 9 and takes no
+// period.
+func ParseSpec(text string) (Spec, error) {
+	name, param, hasParam := strings.Cut(strings.ToLower(strings.TrimSpace(text)), ":")
+	period, ok := defaultPeriods[name]
+	if !ok {
+		return Spec{}, fmt.Errorf("unknown indicator %q, expected one of %s", name, strings.Join(Names(), ", "))
+	}
+
+	if hasParam {
+		if name == "macd" {
+			return Spec{}, fmt.Errorf("macd does not take a period")
+		}
+		parsed, err := strconv.Atoi(param)
+		// Volatility needs at least two returns for a sample deviation
+		if err != nil || parsed < 2 || parsed > maxPeriod {
+			return Spec{}, fmt.Errorf("invalid period %q for %s, expected an integer between 2 and %d", param, name, maxPeriod)
+		}
+		period = parsed
+	}
+	return Spec{Name: name, Period: period}, nil
+}
+
+// Key names the series of the indicator, e.g. "sma_50" or "macd". Indicators with several
+// lines add a suffix per line, see Compute.
+func (s Spec) Key() string {
+	if s.Period == 0 {
+		return s.Name
+	}
+	return s.Name + "_" + strconv.Itoa(s.Period)
+}
+
+// Compute calculates the indicators over the bars, sorted oldest first. The result maps the
+// series names to their values, only where they are defined: "macd" also yields
+// "macd_signal" and "macd_histogram", "bollinger_20" yields "bollinger_20_upper",
+// "bollinger_20_middle" and "bollinger_20_lower".
+func Compute(bars []types.Bar, specs []Spec) map[string][]types.IndicatorValue {
+	closes := Closes(bars)
+	result := map[string][]types.IndicatorValue{}
+	add := func(key string, values []float64) {
+		result[key] = series(bars, values)
+	}
+
+	for _, spec := range specs {
+		switch spec.Name {
+		case "sma":
+			add(spec.Key(), SMA(closes, spec.Period))
+		case "ema":
+			add(spec.Key(), EMA(closes, spec.Period))
+		case "rsi":
+			add(spec.Key(), RSI(closes, spec.Period))
+		case "macd":
+			macd, signal, histogram := MACD(closes, 12, 26, 9)
+			add("macd", macd)
+			add("macd_signal", signal)
+			add("macd_histogram", histogram)
+		case "bollinger":
+			upper, middle, lower := Bollinger(closes, spec.Period, 2)
+			add(spec.Key()+"_upper", upper)
+			add(spec.Key()+"_middle", middle)
+			add(spec.Key()+"_lower", lower)
+		case "atr":
+			add(spec.Key(), ATR(bars, spec.Period))
+		case "volatility":
+			add(spec.Key(), Volatility(closes, spec.Period))
+		}
+	}
+	return result
+}
+
+// DefaultSpecs is the set of indicators summarized by Snapshot
+func DefaultSpecs() []Spec {
+	return []Spec{
+		{Name: "sma", Period: 20},
+		{Name: "sma", Period: 50},
+		{Name: "ema", Period: 20},
+		{Name: "rsi", Period: 14},
+		{Name: "macd"},
+		{Name: "bollinger", Period: 20},
+		{Name: "atr", Period: 14},
+		{Name: "volatility", Period: 20},
+	}
+}
+
+// Snapshot returns the last value of each default indicator, e.g. to describe the recent
+// price action of a symbol in a prompt. Indicators without enough bars are left out.
+func Snapshot(symbol string, bars []types.Bar) types.IndicatorSnapshot {
+	snapshot := types.IndicatorSnapshot{Symbol: symbol, Values: map[string]float64{}}
+	if len(bars) == 0 {
+		return snapshot
+	}
+
+	last := bars[len(bars)-1]
+	snapshot.Date = last.Date
+	snapshot.Close = last.Close
+	for key, values := range Compute(bars, DefaultSpecs()) {
+		if len(values) > 0 && values[len(values)-1].Date == last.Date {
+			snapshot.Values[key] = values[len(values)-1].Value
+		}
+	}
+	return snapshot
+}
+
+func series(bars []types.Bar, values []float64) []types.IndicatorValue {
+	out := []types.IndicatorValue{}
+	for i, value := range values {
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			out = append(out, types.IndicatorValue{Date: bars[i].Date, Value: value})
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Group runs the background jobs of the process and stops them together on shutdown
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewGroup(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Go starts fn in the background. fn must return once its context is cancelled.
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("background job panicked", "job", name, "panic", r)
+			}
+		}()
+
+		slog.Info("background job started", "job", name)
+		fn(g.ctx)
+		slog.Info("background job stopped", "job", name)
+	}()
+}
+
+// Every runs fn every interval until the group is stopped, optionally once right away
+func (g *Group) Every(name string, interval time.Duration, runNow bool, fn func(ctx context.Context)) {
+	g.Go(name, func(ctx context.Context) {
+		if runNow {
+			fn(ctx)
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	})
+}
+
+// Stop cancels every job and waits for them to return, at most until ctx is done
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background jobs did not stop in time: %w", ctx.Err())
+	}
+}
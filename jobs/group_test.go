@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupStop(t *testing.T) {
+	group := NewGroup(context.Background())
+	var stopped atomic.Bool
+	group.Go("waits", func(ctx context.Context) {
+		<-ctx.Done()
+		stopped.Store(true)
+	})
+	group.Go("panics", func(ctx context.Context) { panic("boom") })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := group.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !stopped.Load() {
+		t.Error("Stop returned before the job did")
+	}
+}
+
+func TestGroupStopTimeout(t *testing.T) {
+	group := NewGroup(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+	group.Go("stuck", func(ctx context.Context) { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := group.Stop(ctx); err == nil {
+		t.Error("expected an error for a job not stopping in time")
+	}
+}
+
+func TestGroupEvery(t *testing.T) {
+	group := NewGroup(context.Background())
+	var runs atomic.Int32
+	ran := make(chan struct{}, 1)
+	group.Every("ticks", time.Hour, true, func(ctx context.Context) {
+		runs.Add(1)
+		ran <- struct{}{}
+	})
+
+	<-ran
+	if err := group.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if runs.Load() != 1 {
+		t.Errorf("job ran %d times, want once right away", runs.Load())
+	}
+}
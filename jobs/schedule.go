@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Schedule tells when a job runs next
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// daily runs at fixed times of the day, stored as offsets from midnight UTC
+type daily []time.Duration
+
+// DailyAt returns a schedule running every day at the given UTC times, formatted "15:04"
+func DailyAt(times []string) (Schedule, error) {
+	if len(times) == 0 {
+		return nil, fmt.Errorf("at least one time of the day is required")
+	}
+
+	schedule := make(daily, 0, len(times))
+	for _, raw := range times {
+		at, err := time.Parse("15:04", raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of the day %q, expected HH:MM", raw)
+		}
+		schedule = append(schedule, time.Duration(at.Hour())*time.Hour+time.Duration(at.Minute())*time.Minute)
+	}
+	sort.Slice(schedule, func(i, j int) bool { return schedule[i] < schedule[j] })
+	return schedule, nil
+}
+
+func (d daily) Next(after time.Time) time.Time {
+	midnight := after.UTC().Truncate(24 * time.Hour)
+	for _, offset := range d {
+		if next := midnight.Add(offset); next.After(after) {
+			return next
+		}
+	}
+	return midnight.Add(24 * time.Hour).Add(d[0])
+}
+
+// Schedule runs fn every time the schedule fires until the group is stopped
+func (g *Group) Schedule(name string, schedule Schedule, fn func(ctx context.Context)) {
+	g.Go(name, func(ctx context.Context) {
+		for {
+			timer := time.NewTimer(time.Until(schedule.Next(time.Now())))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				fn(ctx)
+			}
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyAt(t *testing.T) {
+	schedule, err := DailyAt([]string{"21:30", "06:00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2025, 7, 8, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		after time.Time
+		want  time.Time
+	}{
+		{day, day.Add(6 * time.Hour)},
+		{day.Add(6 * time.Hour), day.Add(21*time.Hour + 30*time.Minute)},
+		{day.Add(12 * time.Hour), day.Add(21*time.Hour + 30*time.Minute)},
+		{day.Add(22 * time.Hour), day.Add(30 * time.Hour)},
+		// Times are UTC whatever the zone of after
+		{time.Date(2025, 7, 8, 1, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), day.Add(6 * time.Hour)},
+	}
+	for _, tt := range tests {
+		if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestDailyAtInvalid(t *testing.T) {
+	tests := [][]string{nil, {"25:00"}, {"9am"}, {"06:00", ""}}
+	for _, times := range tests {
+		if _, err := DailyAt(times); err == nil {
+			t.Errorf("DailyAt(%q) expected an error", times)
+		}
+	}
+}
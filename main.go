@@ -2,60 +2,196 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/arcedo/financial-ai-backend/api"
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/arcedo/financial-ai-backend/corporate"
 	"github.com/arcedo/financial-ai-backend/data"
 	db "github.com/arcedo/financial-ai-backend/database"
-	"github.com/joho/godotenv"
+	"github.com/arcedo/financial-ai-backend/jobs"
+	"github.com/arcedo/financial-ai-backend/marketdata"
+	"github.com/arcedo/financial-ai-backend/stocksync"
+	"github.com/arcedo/financial-ai-backend/utils"
 )
 
 func main() {
-	err := godotenv.Load()
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	configFile := os.Getenv("CONFIG_FILE")
+	if configFile == "" {
+		configFile = "config.yaml"
+	}
+	cfg, err := config.Load(configFile)
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatalf("Error loading configuration: %v", err)
 	}
 
-	mongoUri := fmt.Sprintf("mongodb://%s:%s@%s:%s",
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASS"),
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-	)
-	mongoStorage, err := db.NewMongoStorage(mongoUri, "financial-ai")
+	logger, err := utils.NewLogger(cfg.Log.Level, cfg.Log.Format)
 	if err != nil {
-		log.Fatalf("Mongo connection failed: %v", err)
+		log.Fatalf("Error creating logger: %v", err)
+	}
+	slog.SetDefault(logger)
+	logger.Info("loaded configuration", "config", cfg.String())
+	if err := utils.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		fatal("error loading trusted proxies", err)
 	}
-	defer mongoStorage.Close(context.Background())
 
-	if err := mongoStorage.InitProducts(context.Background(), "products", data.Products); err != nil {
-		log.Fatalf("Error initializing products: %v", err)
+	mongoStorage, err := db.NewMongoStorage(cfg.Database.MongoURI(), cfg.Database.Name)
+	if err != nil {
+		fatal("mongo connection failed", err)
+	}
+
+	if err := mongoStorage.InitProducts(context.Background(), "products", data.Products, cfg.Products.Seed); err != nil {
+		fatal("error initializing products", err)
+	}
+	if err := mongoStorage.InitUsers(context.Background(), "users"); err != nil {
+		fatal("error initializing users, users sharing an email must be merged first", err)
+	}
+	if err := mongoStorage.InitSessions(context.Background(), "sessions"); err != nil {
+		fatal("error initializing sessions", err)
+	}
+	if err := mongoStorage.InitStocks(context.Background(), "stocks"); err != nil {
+		fatal("error initializing stocks, run the dedupe-stocks command if duplicates exist", err)
+	}
+	if err := mongoStorage.InitStockSync(context.Background()); err != nil {
+		fatal("error initializing stock sync", err)
+	}
+	if err := mongoStorage.InitCorporateActions(context.Background()); err != nil {
+		fatal("error initializing corporate actions", err)
+	}
+	if err := mongoStorage.InitIdempotencyKeys(context.Background(), "idempotency_keys"); err != nil {
+		fatal("error initializing idempotency keys", err)
+	}
+	if cfg.RateLimit.Backend == "mongo" {
+		if err := mongoStorage.InitRateLimits(context.Background(), "rate_limits"); err != nil {
+			fatal("error initializing rate limits", err)
+		}
 	}
 	/*if err := mongoStorage.RemoveCollection(context.Background(), "products"); err != nil {
-		log.Fatalf("Error removing products: %v", err)
+		fatal("error removing products", err)
 	}*/
 
-	/* We can't execute more queries in max 25 per day ;(
+	keys, err := utils.NewKeyManager(cfg.JWT)
+	if err != nil {
+		fatal("error initializing JWT keys", err)
+	}
 
-	ctx := context.Background()
-	go func() {
-		for {
-			log.Println("Running stock sync...")
-			if err := requests.SyncDailyStockData(ctx, *mongoStorage); err != nil {
-				log.Printf("Sync error: %v", err)
-			} else {
-				log.Println("Stock sync completed successfully.")
-			}
+	// Cancelled on SIGINT/SIGTERM, which starts the shutdown sequence
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	background := jobs.NewGroup(utils.WithLogger(context.Background(), logger))
+	background.Every("jwt-key-rotation", time.Hour, false, keys.CheckRotation)
 
-			time.Sleep(24 * time.Hour)
+	// Products are ranked by their holders in search, counting them replays every transaction
+	interest := corporate.NewInterestCache(*mongoStorage)
+	background.Every("symbol-interest", 15*time.Minute, true, func(ctx context.Context) {
+		if err := interest.Refresh(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("error counting symbol holders", "error", err)
+		}
+	})
+
+	provider, err := marketdata.New(cfg.MarketData)
+	if err != nil {
+		fatal("error initializing market data provider", err)
+	}
+	if cfg.MarketData.SyncEnabled {
+		schedule, err := jobs.DailyAt(cfg.MarketData.SyncAt)
+		if err != nil {
+			fatal("invalid stock sync schedule", err)
 		}
-	}()*/
+		syncer := stocksync.New(*mongoStorage, provider, cfg.MarketData.DailyBudget)
+
+		// A run interrupted by the previous shutdown picks up where it stopped
+		background.Go("stock-sync-resume", func(ctx context.Context) {
+			if err := syncer.ResumeUnfinished(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				logger.Error("error resuming stock sync", "error", err)
+			}
+		})
+		// The corporate actions follow the bars, on the instance that synced them, and credit the
+		// dividends that went ex since
+		actionProvider, hasActions := provider.(marketdata.ActionProvider)
+		budget := stocksync.NewBudget(*mongoStorage, provider.Name(), cfg.MarketData.DailyBudget)
+		background.Schedule("stock-sync", schedule, func(ctx context.Context) {
+			err := syncer.Run(ctx)
+			if errors.Is(err, stocksync.ErrAlreadyRunning) || errors.Is(err, context.Canceled) {
+				return
+			}
+			if err != nil {
+				logger.Error("stock sync failed", "error", err)
+			}
+			if hasActions {
+				syncCorporateActions(ctx, *mongoStorage, actionProvider, budget)
+			}
+		})
+	}
+
+	server := api.NewServer(cfg, logger, *mongoStorage, keys, interest)
+	serverErr := server.Start(ctx)
+	// A second signal from now on kills the process right away
+	stop()
+	if serverErr != nil {
+		logger.Error("server stopped with error", "error", serverErr)
+	}
+
+	// The server has drained, now stop the jobs and only then release the database they use
+	stopCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := background.Stop(stopCtx); err != nil {
+		logger.Error("error stopping background jobs", "error", err)
+	}
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelClose()
+	if err := mongoStorage.Close(closeCtx); err != nil {
+		logger.Error("error disconnecting from mongo", "error", err)
+	}
 
-	server := api.NewServer(os.Getenv("LISTEN_ADDRESS"), *mongoStorage)
+	if serverErr != nil {
+		os.Exit(1)
+	}
+	logger.Info("shutdown complete")
+}
 
-	if err := server.Start(); err != nil {
-		log.Fatalf("Error starting server: %v", err)
+// syncCorporateActions fetches the splits and dividends of every traded symbol and credits the
+// dividends to their holders, stopping when the market data budget is used up
+func syncCorporateActions(ctx context.Context, store db.MongoStorage, provider marketdata.ActionProvider, budget corporate.Budget) {
+	logger := utils.LoggerFrom(ctx)
+	symbols, err := corporate.TradedSymbols(ctx, store)
+	if err != nil {
+		logger.Error("corporate actions sync failed", "error", err)
+		return
 	}
+
+	newActions, credited := 0, 0
+	for _, symbol := range symbols {
+		result, err := corporate.Sync(ctx, store, provider, budget, symbol)
+		newActions += result.NewActions
+		credited += result.Credited
+		if errors.Is(err, stocksync.ErrBudgetExhausted) || errors.Is(err, marketdata.ErrRateLimited) {
+			logger.Warn("market data budget exhausted, the corporate actions sync continues with the next one", "symbol", symbol, "error", err)
+			break
+		}
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			logger.Error("error syncing corporate actions", "symbol", symbol, "error", err)
+		}
+	}
+	logger.Info("corporate actions synced", "symbols", len(symbols), "new_actions", newActions, "credited", credited)
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,266 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/metrics"
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+)
+
+const (
+	// alphaVantageTimeout bounds a single Alpha Vantage request
+	alphaVantageTimeout = 10 * time.Second
+	// compactBars is how many of the latest bars the compact daily series holds, older ones
+	// need the full series
+	compactBars = 100
+)
+
+// AlphaVantage fetches the daily series of the Alpha Vantage API. Calls are spaced by at
+// least minInterval to stay under the per-minute limit of the plan.
+type AlphaVantage struct {
+	baseURL     string
+	apiKey      string
+	minInterval time.Duration
+
+	mu       sync.Mutex
+	lastCall time.Time
+}
+
+func NewAlphaVantage(baseURL, apiKey string, minInterval time.Duration) *AlphaVantage {
+	return &AlphaVantage{baseURL: baseURL, apiKey: apiKey, minInterval: minInterval}
+}
+
+func (av *AlphaVantage) Name() string {
+	return ProviderAlphaVantage
+}
+
+func (av *AlphaVantage) DailyBars(ctx context.Context, symbol, since string) ([]types.NewStock, error) {
+	outputSize := "compact"
+	if !compactCovers(since, time.Now()) {
+		outputSize = "full"
+	}
+	respBody, err := av.query(ctx, "TIME_SERIES_DAILY", symbol, url.Values{"outputsize": {outputSize}})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		ErrorMessage string                       `json:"Error Message"`
+		Note         string                       `json:"Note"`
+		Information  string                       `json:"Information"`
+		TimeSeries   map[string]map[string]string `json:"Time Series (Daily)"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, utils.NewUpstreamError("alpha_vantage", fmt.Errorf("failed to decode response: %w", err))
+	}
+
+	if err := payloadError(result.ErrorMessage, result.Note, result.Information); err != nil {
+		return nil, err
+	}
+	if result.TimeSeries == nil {
+		return nil, utils.NewUpstreamError("alpha_vantage", fmt.Errorf("invalid response format from Alpha Vantage: missing 'Time Series (Daily)'"))
+	}
+
+	bars := make([]types.NewStock, 0, len(result.TimeSeries))
+	for date, values := range result.TimeSeries {
+		bar, err := parseAlphaVantageBar(symbol, date, values)
+		if err != nil {
+			return nil, err
+		}
+		bars = append(bars, bar)
+	}
+	return after(bars, since), nil
+}
+
+// Splits returns the splits of symbol from the SPLITS function
+func (av *AlphaVantage) Splits(ctx context.Context, symbol string) ([]types.CorporateAction, error) {
+	var rows []struct {
+		EffectiveDate string `json:"effective_date"`
+		SplitFactor   string `json:"split_factor"`
+	}
+	if err := av.queryData(ctx, "SPLITS", symbol, &rows); err != nil {
+		return nil, err
+	}
+
+	actions := make([]types.CorporateAction, 0, len(rows))
+	for _, row := range rows {
+		ratio, err := strconv.ParseFloat(row.SplitFactor, 64)
+		if err != nil || ratio <= 0 {
+			return nil, fmt.Errorf("invalid split factor %q for %s on %s", row.SplitFactor, symbol, row.EffectiveDate)
+		}
+		if _, err := time.Parse("2006-01-02", row.EffectiveDate); err != nil {
+			return nil, fmt.Errorf("invalid split date %q for %s", row.EffectiveDate, symbol)
+		}
+		actions = append(actions, types.CorporateAction{
+			Symbol: symbol,
+			Type:   types.ActionSplit,
+			ExDate: row.EffectiveDate,
+			Ratio:  ratio,
+		})
+	}
+	return actions, nil
+}
+
+// Dividends returns the cash dividends of symbol from the DIVIDENDS function
+func (av *AlphaVantage) Dividends(ctx context.Context, symbol string) ([]types.CorporateAction, error) {
+	var rows []struct {
+		ExDividendDate string `json:"ex_dividend_date"`
+		PaymentDate    string `json:"payment_date"`
+		Amount         string `json:"amount"`
+	}
+	if err := av.queryData(ctx, "DIVIDENDS", symbol, &rows); err != nil {
+		return nil, err
+	}
+
+	actions := make([]types.CorporateAction, 0, len(rows))
+	for _, row := range rows {
+		amount, err := strconv.ParseFloat(row.Amount, 64)
+		if err != nil || amount < 0 {
+			return nil, fmt.Errorf("invalid dividend amount %q for %s on %s", row.Amount, symbol, row.ExDividendDate)
+		}
+		if _, err := time.Parse("2006-01-02", row.ExDividendDate); err != nil {
+			return nil, fmt.Errorf("invalid ex-dividend date %q for %s", row.ExDividendDate, symbol)
+		}
+		// Announced dividends may not have a payment date yet, "None" in the response
+		payDate := row.PaymentDate
+		if _, err := time.Parse("2006-01-02", payDate); err != nil {
+			payDate = ""
+		}
+		actions = append(actions, types.CorporateAction{
+			Symbol:  symbol,
+			Type:    types.ActionDividend,
+			ExDate:  row.ExDividendDate,
+			PayDate: payDate,
+			Amount:  amount,
+		})
+	}
+	return actions, nil
+}
+
+// query calls an Alpha Vantage function for symbol, once minInterval has passed
+func (av *AlphaVantage) query(ctx context.Context, function, symbol string, params url.Values) ([]byte, error) {
+	if err := av.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{
+		"function": {function},
+		"symbol":   {symbol},
+		"apikey":   {av.apiKey},
+	}
+	for name, values := range params {
+		query[name] = values
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	start := time.Now()
+	respBody, err := utils.MakeHTTPRequest(ctx, "GET", av.baseURL+"/query?"+query.Encode(), headers, nil, alphaVantageTimeout)
+	metrics.ObserveOutbound("alpha_vantage", strings.ToLower(function), start, err)
+	if err != nil {
+		return nil, utils.NewUpstreamError("alpha_vantage", fmt.Errorf("failed to fetch %s: %w", strings.ToLower(function), err))
+	}
+	return respBody, nil
+}
+
+// queryData calls a function answering {"symbol": ..., "data": [...]} and decodes data
+func (av *AlphaVantage) queryData(ctx context.Context, function, symbol string, data any) error {
+	respBody, err := av.query(ctx, function, symbol, nil)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		ErrorMessage string          `json:"Error Message"`
+		Note         string          `json:"Note"`
+		Information  string          `json:"Information"`
+		Data         json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return utils.NewUpstreamError("alpha_vantage", fmt.Errorf("failed to decode response: %w", err))
+	}
+	if err := payloadError(result.ErrorMessage, result.Note, result.Information); err != nil {
+		return err
+	}
+	if result.Data == nil {
+		return utils.NewUpstreamError("alpha_vantage", fmt.Errorf("invalid response format from Alpha Vantage: missing 'data'"))
+	}
+	if err := json.Unmarshal(result.Data, data); err != nil {
+		return utils.NewUpstreamError("alpha_vantage", fmt.Errorf("failed to decode %s: %w", strings.ToLower(function), err))
+	}
+	return nil
+}
+
+// wait blocks until minInterval has passed since the previous call
+// payloadError turns the errors and rate limits Alpha Vantage answers with a 200 into upstream
+// errors, nil when the response carries none
+func payloadError(errorMessage, note, information string) error {
+	switch {
+	case errorMessage != "":
+		return utils.NewUpstreamError("alpha_vantage", fmt.Errorf("Alpha Vantage error: %s", errorMessage))
+	case note != "":
+		return utils.NewUpstreamError("alpha_vantage", fmt.Errorf("%w: %s", ErrRateLimited, note))
+	case information != "":
+		return utils.NewUpstreamError("alpha_vantage", fmt.Errorf("%w: %s", ErrRateLimited, information))
+	}
+	return nil
+}
+
+func (av *AlphaVantage) wait(ctx context.Context) error {
+	av.mu.Lock()
+	defer av.mu.Unlock()
+
+	if delay := av.minInterval - time.Since(av.lastCall); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	av.lastCall = time.Now()
+	return nil
+}
+
+// compactCovers reports whether the compact series still holds the bars after since at now,
+// with a few bars of margin for the holidays the calendar does not know
+func compactCovers(since string, now time.Time) bool {
+	day, err := time.Parse("2006-01-02", since)
+	if err != nil {
+		return false
+	}
+	missing := TradingDays(day.AddDate(0, 0, 1), now)
+	return len(missing) <= compactBars-5
+}
+
+func parseAlphaVantageBar(symbol, date string, values map[string]string) (types.NewStock, error) {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return types.NewStock{}, fmt.Errorf("invalid date %q for %s", date, symbol)
+	}
+
+	var parsed [5]float64
+	for i, key := range []string{"1. open", "2. high", "3. low", "4. close", "5. volume"} {
+		value, err := strconv.ParseFloat(values[key], 32)
+		if err != nil {
+			return types.NewStock{}, fmt.Errorf("invalid %q value %q for %s on %s", key, values[key], symbol, date)
+		}
+		parsed[i] = value
+	}
+
+	return types.NewStock{
+		Symbol:     symbol,
+		Date:       date,
+		OpenPrice:  float32(parsed[0]),
+		HighPrice:  float32(parsed[1]),
+		LowPrice:   float32(parsed[2]),
+		ClosePrice: float32(parsed[3]),
+		Volume:     float32(parsed[4]),
+	}, nil
+}
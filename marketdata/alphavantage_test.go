@@ -0,0 +1,241 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+)
+
+func newTestAlphaVantage(t *testing.T) (*AlphaVantage, *FakeAlphaVantage) {
+	fake := NewFakeAlphaVantage()
+	t.Cleanup(fake.Close)
+	return NewAlphaVantage(fake.URL(), "test", 0), fake
+}
+
+func TestAlphaVantageDailyBars(t *testing.T) {
+	av, fake := newTestAlphaVantage(t)
+	fake.SetBars("AAPL", []types.NewStock{
+		{Symbol: "AAPL", Date: "2025-07-03", OpenPrice: 3, HighPrice: 3.5, LowPrice: 2.5, ClosePrice: 3.25, Volume: 300},
+		{Symbol: "AAPL", Date: "2025-07-01", OpenPrice: 1, HighPrice: 1.5, LowPrice: 0.5, ClosePrice: 1.25, Volume: 100},
+		{Symbol: "AAPL", Date: "2025-07-02", OpenPrice: 2, HighPrice: 2.5, LowPrice: 1.5, ClosePrice: 2.25, Volume: 200},
+	})
+
+	tests := []struct {
+		since string
+		want  []string
+	}{
+		{"", []string{"2025-07-01", "2025-07-02", "2025-07-03"}},
+		{"2025-07-01", []string{"2025-07-02", "2025-07-03"}},
+		{"2025-07-03", []string{}},
+	}
+	for _, tt := range tests {
+		bars, err := av.DailyBars(context.Background(), "AAPL", tt.since)
+		if err != nil {
+			t.Fatalf("DailyBars(since %q): %v", tt.since, err)
+		}
+		dates := []string{}
+		for _, bar := range bars {
+			dates = append(dates, bar.Date)
+		}
+		if !reflect.DeepEqual(dates, tt.want) {
+			t.Errorf("DailyBars(since %q) dates = %v, want %v", tt.since, dates, tt.want)
+		}
+	}
+
+	bars, _ := av.DailyBars(context.Background(), "AAPL", "2025-07-02")
+	want := types.NewStock{Symbol: "AAPL", Date: "2025-07-03", OpenPrice: 3, HighPrice: 3.5, LowPrice: 2.5, ClosePrice: 3.25, Volume: 300}
+	if len(bars) != 1 || bars[0] != want {
+		t.Errorf("DailyBars parsed %+v, want %+v", bars, want)
+	}
+}
+
+func TestAlphaVantageErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(*FakeAlphaVantage)
+		contains    string
+		upstream    bool
+		rateLimited bool
+	}{
+		{
+			name:     "error message",
+			setup:    func(f *FakeAlphaVantage) { f.SetError("X", "Invalid API call") },
+			contains: "Alpha Vantage error: Invalid API call",
+			upstream: true,
+		},
+		{
+			name:        "rate limit note",
+			setup:       func(f *FakeAlphaVantage) { f.SetResponse("X", http.StatusOK, `{"Note": "5 calls per minute"}`) },
+			contains:    "rate limit hit: 5 calls per minute",
+			upstream:    true,
+			rateLimited: true,
+		},
+		{
+			name:        "rate limit information",
+			setup:       func(f *FakeAlphaVantage) { f.SetResponse("X", http.StatusOK, `{"Information": "25 requests per day"}`) },
+			contains:    "rate limit hit: 25 requests per day",
+			upstream:    true,
+			rateLimited: true,
+		},
+		{
+			name:     "HTTP error",
+			setup:    func(f *FakeAlphaVantage) { f.SetResponse("X", http.StatusServiceUnavailable, "down") },
+			contains: "unexpected status code: 503",
+			upstream: true,
+		},
+		{
+			name:     "malformed JSON",
+			setup:    func(f *FakeAlphaVantage) { f.SetResponse("X", http.StatusOK, `{"Time Series (Daily)": {`) },
+			contains: "failed to decode response",
+			upstream: true,
+		},
+		{
+			name:     "missing series",
+			setup:    func(f *FakeAlphaVantage) { f.SetResponse("X", http.StatusOK, `{}`) },
+			contains: "missing 'Time Series (Daily)'",
+			upstream: true,
+		},
+		{
+			name: "invalid value",
+			setup: func(f *FakeAlphaVantage) {
+				f.SetResponse("X", http.StatusOK, `{"Time Series (Daily)": {"2025-07-01": {"1. open": "n/a"}}}`)
+			},
+			contains: `invalid "1. open" value "n/a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			av, fake := newTestAlphaVantage(t)
+			tt.setup(fake)
+
+			_, err := av.DailyBars(context.Background(), "X", "")
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("error %q does not contain %q", err, tt.contains)
+			}
+			if got := errors.Is(err, utils.ErrUpstreamUnavailable); got != tt.upstream {
+				t.Errorf("upstream error = %v, want %v", got, tt.upstream)
+			}
+			if got := errors.Is(err, ErrRateLimited); got != tt.rateLimited {
+				t.Errorf("rate limited = %v, want %v", got, tt.rateLimited)
+			}
+		})
+	}
+}
+
+func TestAlphaVantageActions(t *testing.T) {
+	av, fake := newTestAlphaVantage(t)
+	fake.SetActions("AAPL", []types.CorporateAction{
+		{Type: types.ActionSplit, ExDate: "2020-08-31", Ratio: 4},
+		{Type: types.ActionDividend, ExDate: "2025-05-12", PayDate: "2025-05-15", Amount: 0.26},
+		{Type: types.ActionDividend, ExDate: "2025-08-11", Amount: 0.26},
+	})
+
+	splits, err := av.Splits(context.Background(), "AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSplits := []types.CorporateAction{{Symbol: "AAPL", Type: types.ActionSplit, ExDate: "2020-08-31", Ratio: 4}}
+	if !reflect.DeepEqual(splits, wantSplits) {
+		t.Errorf("Splits() = %+v, want %+v", splits, wantSplits)
+	}
+
+	dividends, err := av.Dividends(context.Background(), "AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDividends := []types.CorporateAction{
+		{Symbol: "AAPL", Type: types.ActionDividend, ExDate: "2025-05-12", PayDate: "2025-05-15", Amount: 0.26},
+		// Announced dividends answer "None" as payment date
+		{Symbol: "AAPL", Type: types.ActionDividend, ExDate: "2025-08-11", Amount: 0.26},
+	}
+	if !reflect.DeepEqual(dividends, wantDividends) {
+		t.Errorf("Dividends() = %+v, want %+v", dividends, wantDividends)
+	}
+
+	fake.SetResponse("BAD", http.StatusOK, `{"data": [{"effective_date": "2020-08-31", "split_factor": "0"}]}`)
+	if _, err := av.Splits(context.Background(), "BAD"); err == nil {
+		t.Error("expected an error for a zero split factor")
+	}
+	fake.SetResponse("LIMIT", http.StatusOK, `{"Information": "25 requests per day"}`)
+	if _, err := av.Dividends(context.Background(), "LIMIT"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Dividends() error = %v, want a rate limit error", err)
+	}
+}
+
+func TestFakeProvider(t *testing.T) {
+	fake := NewFakeProvider()
+	first, err := fake.DailyBars(context.Background(), "AAPL", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) < fakeHistoryDays {
+		t.Fatalf("got %d bars, want at least %d", len(first), fakeHistoryDays)
+	}
+	again, _ := fake.DailyBars(context.Background(), "AAPL", "")
+	if !reflect.DeepEqual(first, again) {
+		t.Error("the random walk of a symbol must be the same on every call")
+	}
+
+	since := first[len(first)-3].Date
+	latest, _ := fake.DailyBars(context.Background(), "AAPL", since)
+	if len(latest) != 2 {
+		t.Errorf("got %d bars after %s, want 2", len(latest), since)
+	}
+	for _, bar := range first {
+		if bar.LowPrice > bar.OpenPrice || bar.LowPrice > bar.ClosePrice || bar.HighPrice < bar.OpenPrice || bar.HighPrice < bar.ClosePrice {
+			t.Fatalf("bar %s is outside its range: %+v", bar.Date, bar)
+		}
+	}
+}
+
+func TestAlphaVantageOutputSize(t *testing.T) {
+	recent := time.Now().UTC().AddDate(0, 0, -10).Format("2006-01-02")
+	old := time.Now().UTC().AddDate(-1, 0, 0).Format("2006-01-02")
+	tests := []struct {
+		since string
+		want  string
+	}{
+		{recent, "compact"},
+		{old, "full"},
+		{"", "full"},
+	}
+	for _, tt := range tests {
+		av, fake := newTestAlphaVantage(t)
+		if _, err := av.DailyBars(context.Background(), "AAPL", tt.since); err != nil {
+			t.Fatal(err)
+		}
+		calls := fake.Calls()
+		if len(calls) != 1 || calls[0].Get("outputsize") != tt.want {
+			t.Errorf("DailyBars(since %q) queried %v, want outputsize %s", tt.since, calls, tt.want)
+		}
+	}
+}
+
+func TestCompactCovers(t *testing.T) {
+	now := time.Date(2025, 7, 9, 22, 0, 0, 0, time.UTC)
+	tests := []struct {
+		since string
+		want  bool
+	}{
+		{"2025-07-08", true},
+		{"2025-03-03", true},
+		{"2025-02-03", false},
+		{"2024-07-09", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := compactCovers(tt.since, now); got != tt.want {
+			t.Errorf("compactCovers(%q) = %v, want %v", tt.since, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package marketdata
+
+import "time"
+
+// marketClose is when the daily bar of the US markets is available (UTC)
+const marketClose = 21 * time.Hour
+
+// IsTradingDay reports whether the US stock markets open on day: weekdays other than the NYSE
+// holidays. Unscheduled closures, e.g. national days of mourning, are not known.
+func IsTradingDay(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	return !isHoliday(day)
+}
+
+// TradingDays lists the trading days from from to to, both included, as YYYY-MM-DD
+func TradingDays(from, to time.Time) []string {
+	var days []string
+	for day := truncateDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if IsTradingDay(day) {
+			days = append(days, day.Format("2006-01-02"))
+		}
+	}
+	return days
+}
+
+// ExpectedLatestDate is the date of the newest bar a provider can have at now: today's once
+// the market has closed, the previous trading day's before
+func ExpectedLatestDate(now time.Time) string {
+	day := truncateDay(now)
+	if now.UTC().Sub(day) < marketClose {
+		day = day.AddDate(0, 0, -1)
+	}
+	for !IsTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day.Format("2006-01-02")
+}
+
+// PreviousTradingDay is the last trading day before day, as YYYY-MM-DD
+func PreviousTradingDay(day time.Time) string {
+	day = truncateDay(day).AddDate(0, 0, -1)
+	for !IsTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day.Format("2006-01-02")
+}
+
+func truncateDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// isHoliday reports the full day NYSE holidays, on the day they are observed
+func isHoliday(day time.Time) bool {
+	year, month, date := day.Date()
+	on := func(m time.Month, d int) bool { return month == m && date == d }
+	observed := func(m time.Month, d int) bool {
+		holiday := time.Date(year, m, d, 0, 0, 0, 0, time.UTC)
+		switch holiday.Weekday() {
+		case time.Saturday:
+			holiday = holiday.AddDate(0, 0, -1)
+		case time.Sunday:
+			holiday = holiday.AddDate(0, 0, 1)
+		}
+		return month == holiday.Month() && date == holiday.Day()
+	}
+
+	switch {
+	// New Year's Day is not moved back to the last trading day of the previous year
+	case on(time.January, 1), day.Weekday() == time.Monday && on(time.January, 2):
+		return true
+	case year >= 1998 && month == time.January && day.Weekday() == time.Monday && nth(date) == 3:
+		return true // Martin Luther King Jr. Day
+	case month == time.February && day.Weekday() == time.Monday && nth(date) == 3:
+		return true // Washington's Birthday
+	case on(easter(year).AddDate(0, 0, -2).Month(), easter(year).AddDate(0, 0, -2).Day()):
+		return true // Good Friday
+	case month == time.May && day.Weekday() == time.Monday && date > 24:
+		return true // Memorial Day
+	case year >= 2022 && observed(time.June, 19):
+		return true // Juneteenth
+	case observed(time.July, 4):
+		return true
+	case month == time.September && day.Weekday() == time.Monday && nth(date) == 1:
+		return true // Labor Day
+	case month == time.November && day.Weekday() == time.Thursday && nth(date) == 4:
+		return true // Thanksgiving
+	case observed(time.December, 25):
+		return true
+	}
+	return false
+}
+
+// nth is the occurrence of a weekday in its month falling on date
+func nth(date int) int {
+	return (date-1)/7 + 1
+}
+
+// easter returns the Gregorian Easter Sunday of year (anonymous Gregorian algorithm)
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,122 @@
+package marketdata
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	day, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return day
+}
+
+func TestHolidays(t *testing.T) {
+	holidays := map[int][]string{
+		2021: {"2021-01-01", "2021-01-18", "2021-02-15", "2021-04-02", "2021-05-31", "2021-07-05", "2021-09-06", "2021-11-25", "2021-12-24"},
+		2022: {"2022-01-17", "2022-02-21", "2022-04-15", "2022-05-30", "2022-06-20", "2022-07-04", "2022-09-05", "2022-11-24", "2022-12-26"},
+		2023: {"2023-01-02", "2023-01-16", "2023-02-20", "2023-04-07", "2023-05-29", "2023-06-19", "2023-07-04", "2023-09-04", "2023-11-23", "2023-12-25"},
+		2024: {"2024-01-01", "2024-01-15", "2024-02-19", "2024-03-29", "2024-05-27", "2024-06-19", "2024-07-04", "2024-09-02", "2024-11-28", "2024-12-25"},
+		2025: {"2025-01-01", "2025-01-20", "2025-02-17", "2025-04-18", "2025-05-26", "2025-06-19", "2025-07-04", "2025-09-01", "2025-11-27", "2025-12-25"},
+		2026: {"2026-01-01", "2026-01-19", "2026-02-16", "2026-04-03", "2026-05-25", "2026-06-19", "2026-07-03", "2026-09-07", "2026-11-26", "2026-12-25"},
+		2027: {"2027-01-01", "2027-01-18", "2027-02-15", "2027-03-26", "2027-05-31", "2027-06-18", "2027-07-05", "2027-09-06", "2027-11-25", "2027-12-24"},
+	}
+	for year, days := range holidays {
+		want := map[string]bool{}
+		for _, day := range days {
+			want[day] = true
+		}
+		for day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); day.Year() == year; day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+				continue
+			}
+			formatted := day.Format("2006-01-02")
+			if got := isHoliday(day); got != want[formatted] {
+				t.Errorf("isHoliday(%s %s) = %v, want %v", day.Weekday(), formatted, got, want[formatted])
+			}
+		}
+	}
+}
+
+func TestIsTradingDay(t *testing.T) {
+	tests := []struct {
+		day  string
+		want bool
+	}{
+		{"2025-07-05", false}, // Saturday
+		{"2025-07-06", false}, // Sunday
+		{"2025-07-04", false}, // Independence Day
+		{"2025-07-03", true},  // early close, still a trading day
+		{"2021-12-31", true},  // New Year's Day on a Saturday is not moved back
+		{"2021-06-18", true},  // before Juneteenth was a market holiday
+		{"2024-11-29", true},  // day after Thanksgiving
+	}
+	for _, tt := range tests {
+		if got := IsTradingDay(date(tt.day)); got != tt.want {
+			t.Errorf("IsTradingDay(%s) = %v, want %v", tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestTradingDays(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     int
+	}{
+		{"2021-01-01", "2021-12-31", 252},
+		{"2022-01-01", "2022-12-31", 251},
+		{"2023-01-01", "2023-12-31", 250},
+		{"2024-01-01", "2024-12-31", 252},
+		// The closure for the national day of mourning of 2025-01-09 is not known
+		{"2025-01-01", "2025-12-31", 251},
+		{"2025-07-03", "2025-07-07", 2},
+		{"2025-07-05", "2025-07-05", 0},
+		{"2025-07-08", "2025-07-07", 0},
+	}
+	for _, tt := range tests {
+		if got := len(TradingDays(date(tt.from), date(tt.to))); got != tt.want {
+			t.Errorf("TradingDays(%s, %s) has %d days, want %d", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestExpectedLatestDate(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		{"after the close", time.Date(2025, 7, 8, 22, 0, 0, 0, time.UTC), "2025-07-08"},
+		{"before the close", time.Date(2025, 7, 8, 15, 0, 0, 0, time.UTC), "2025-07-07"},
+		{"weekend after a holiday", time.Date(2025, 7, 5, 12, 0, 0, 0, time.UTC), "2025-07-03"},
+		{"monday morning", time.Date(2025, 7, 7, 6, 0, 0, 0, time.UTC), "2025-07-03"},
+		{"local time of another zone", time.Date(2025, 7, 8, 18, 0, 0, 0, newYork), "2025-07-08"},
+	}
+	for _, tt := range tests {
+		if got := ExpectedLatestDate(tt.now); got != tt.want {
+			t.Errorf("%s: ExpectedLatestDate(%s) = %s, want %s", tt.name, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestPreviousTradingDay(t *testing.T) {
+	tests := []struct {
+		day, want string
+	}{
+		{"2025-07-09", "2025-07-08"},
+		{"2025-07-07", "2025-07-03"}, // over the weekend and Independence Day
+		{"2025-07-05", "2025-07-03"},
+		{"2025-01-02", "2024-12-31"},
+	}
+	for _, tt := range tests {
+		if got := PreviousTradingDay(date(tt.day)); got != tt.want {
+			t.Errorf("PreviousTradingDay(%s) = %s, want %s", tt.day, got, tt.want)
+		}
+	}
+}
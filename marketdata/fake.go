@@ -0,0 +1,67 @@
+package marketdata
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+// fakeHistoryDays is how many weekdays of bars the fake generates
+const fakeHistoryDays = 100
+
+// FakeProvider generates a deterministic random walk for every symbol, without corporate
+// actions, so the sync and the API can be run without network access or call limits
+type FakeProvider struct{}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *FakeProvider) DailyBars(_ context.Context, symbol, since string) ([]types.NewStock, error) {
+	return after(randomWalk(symbol, time.Now().UTC()), since), nil
+}
+
+func (p *FakeProvider) Splits(context.Context, string) ([]types.CorporateAction, error) {
+	return nil, nil
+}
+
+func (p *FakeProvider) Dividends(context.Context, string) ([]types.CorporateAction, error) {
+	return nil, nil
+}
+
+// randomWalk generates the same plausible weekday bars for a symbol on every run
+func randomWalk(symbol string, until time.Time) []types.NewStock {
+	seed := fnv.New64a()
+	seed.Write([]byte(symbol))
+	rng := rand.New(rand.NewSource(int64(seed.Sum64())))
+
+	price := 20 + rng.Float64()*180
+	var bars []types.NewStock
+	for day := until.AddDate(0, 0, -fakeHistoryDays*7/5); !day.After(until); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		open := price
+		price = math.Max(1, price*(1+rng.NormFloat64()*0.02))
+		high := math.Max(open, price) * (1 + rng.Float64()*0.01)
+		low := math.Min(open, price) * (1 - rng.Float64()*0.01)
+		bars = append(bars, types.NewStock{
+			Symbol:     symbol,
+			Date:       day.Format("2006-01-02"),
+			OpenPrice:  float32(open),
+			HighPrice:  float32(high),
+			LowPrice:   float32(low),
+			ClosePrice: float32(price),
+			Volume:     float32(100000 + rng.Intn(5000000)),
+		})
+	}
+	return bars
+}
@@ -0,0 +1,178 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+// FakeAlphaVantage is an in-process HTTP server answering like the Alpha Vantage daily series,
+// splits and dividends functions. Symbols without explicit bars get a deterministic random
+// walk and no corporate actions, and failures can be injected, so the provider can be tested
+// without network access or call limits.
+type FakeAlphaVantage struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	bars      map[string][]types.NewStock
+	actions   map[string][]types.CorporateAction
+	errors    map[string]string
+	responses map[string]fakeResponse
+	calls     []url.Values
+}
+
+// fakeResponse is a raw answer replacing the generated one
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+func NewFakeAlphaVantage() *FakeAlphaVantage {
+	fake := &FakeAlphaVantage{
+		bars:      map[string][]types.NewStock{},
+		actions:   map[string][]types.CorporateAction{},
+		errors:    map[string]string{},
+		responses: map[string]fakeResponse{},
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	return fake
+}
+
+// URL is the base URL to give NewAlphaVantage
+func (f *FakeAlphaVantage) URL() string {
+	return f.server.URL
+}
+
+func (f *FakeAlphaVantage) Close() {
+	f.server.Close()
+}
+
+// SetBars replaces the bars served for symbol
+func (f *FakeAlphaVantage) SetBars(symbol string, bars []types.NewStock) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bars[symbol] = bars
+}
+
+// SetActions replaces the splits and dividends served for symbol
+func (f *FakeAlphaVantage) SetActions(symbol string, actions []types.CorporateAction) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.actions[symbol] = actions
+}
+
+// SetError makes the requests for symbol fail with an Alpha Vantage error message, or
+// succeed again when message is empty
+func (f *FakeAlphaVantage) SetError(symbol, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if message == "" {
+		delete(f.errors, symbol)
+		return
+	}
+	f.errors[symbol] = message
+}
+
+// SetResponse makes the requests for symbol answer status and body as is, e.g. an HTTP error
+// or malformed JSON
+func (f *FakeAlphaVantage) SetResponse(symbol string, status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[symbol] = fakeResponse{status: status, body: body}
+}
+
+// Calls returns the query of every request served so far
+func (f *FakeAlphaVantage) Calls() []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+func (f *FakeAlphaVantage) serve(w http.ResponseWriter, r *http.Request) {
+	symbol := r.URL.Query().Get("symbol")
+
+	f.mu.Lock()
+	f.calls = append(f.calls, r.URL.Query())
+	response, raw := f.responses[symbol]
+	message, failing := f.errors[symbol]
+	actions := f.actions[symbol]
+	bars, ok := f.bars[symbol]
+	if !ok && !failing {
+		bars = randomWalk(symbol, time.Now().UTC())
+		f.bars[symbol] = bars
+	}
+	f.mu.Unlock()
+
+	if raw {
+		w.WriteHeader(response.status)
+		w.Write([]byte(response.body))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if failing {
+		json.NewEncoder(w).Encode(map[string]string{"Error Message": message})
+		return
+	}
+
+	switch function := r.URL.Query().Get("function"); function {
+	case "SPLITS", "DIVIDENDS":
+		json.NewEncoder(w).Encode(map[string]any{"symbol": symbol, "data": actionRows(function, actions)})
+		return
+	}
+
+	// Like Alpha Vantage, the compact series only holds the latest bars
+	if r.URL.Query().Get("outputsize") != "full" && len(bars) > compactBars {
+		bars = slices.Clone(bars)
+		slices.SortFunc(bars, func(a, b types.NewStock) int { return strings.Compare(a.Date, b.Date) })
+		bars = bars[len(bars)-compactBars:]
+	}
+
+	series := make(map[string]map[string]string, len(bars))
+	for _, bar := range bars {
+		series[bar.Date] = map[string]string{
+			"1. open":   fmt.Sprintf("%.4f", bar.OpenPrice),
+			"2. high":   fmt.Sprintf("%.4f", bar.HighPrice),
+			"3. low":    fmt.Sprintf("%.4f", bar.LowPrice),
+			"4. close":  fmt.Sprintf("%.4f", bar.ClosePrice),
+			"5. volume": fmt.Sprintf("%.0f", bar.Volume),
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"Meta Data":           map[string]string{"2. Symbol": symbol},
+		"Time Series (Daily)": series,
+	})
+}
+
+// actionRows formats the actions like the SPLITS or DIVIDENDS functions
+func actionRows(function string, actions []types.CorporateAction) []map[string]string {
+	rows := []map[string]string{}
+	for _, action := range actions {
+		switch {
+		case function == "SPLITS" && action.Type == types.ActionSplit:
+			rows = append(rows, map[string]string{
+				"effective_date": action.ExDate,
+				"split_factor":   fmt.Sprintf("%.4f", action.Ratio),
+			})
+		case function == "DIVIDENDS" && action.Type == types.ActionDividend:
+			payDate := action.PayDate
+			if payDate == "" {
+				payDate = "None"
+			}
+			rows = append(rows, map[string]string{
+				"ex_dividend_date": action.ExDate,
+				"payment_date":     payDate,
+				"amount":           fmt.Sprintf("%.4f", action.Amount),
+			})
+		}
+	}
+	return rows
+}
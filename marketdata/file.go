@@ -0,0 +1,172 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+// FileProvider loads the bars from local files, one per symbol: <dir>/<SYMBOL>.csv with a
+// date,open,high,low,close,volume header, or <dir>/<SYMBOL>.json holding an array of stocks.
+// The optional <dir>/<SYMBOL>.actions.json holds an array of corporate actions. It makes
+// offline development and imports of bought datasets possible.
+type FileProvider struct {
+	dir string
+}
+
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{dir: dir}
+}
+
+func (p *FileProvider) Name() string {
+	return ProviderFile
+}
+
+func (p *FileProvider) DailyBars(_ context.Context, symbol, since string) ([]types.NewStock, error) {
+	if err := checkFileSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	base := filepath.Join(p.dir, symbol)
+	bars, err := readCSVBars(base+".csv", symbol)
+	if errors.Is(err, os.ErrNotExist) {
+		bars, err = readJSONBars(base+".json", symbol)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no market data file for %s in %s", symbol, p.dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return after(bars, since), nil
+}
+
+func (p *FileProvider) Splits(_ context.Context, symbol string) ([]types.CorporateAction, error) {
+	return p.actions(symbol, types.ActionSplit)
+}
+
+func (p *FileProvider) Dividends(_ context.Context, symbol string) ([]types.CorporateAction, error) {
+	return p.actions(symbol, types.ActionDividend)
+}
+
+// actions reads the actions of a type from <dir>/<SYMBOL>.actions.json, none without the file
+func (p *FileProvider) actions(symbol, actionType string) ([]types.CorporateAction, error) {
+	if err := checkFileSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(p.dir, symbol+".actions.json")
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var all []types.CorporateAction
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	var actions []types.CorporateAction
+	for _, action := range all {
+		if action.Type != actionType {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", action.ExDate); err != nil {
+			return nil, fmt.Errorf("%s: invalid ex_date %q", path, action.ExDate)
+		}
+		action.Symbol = symbol
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// checkFileSymbol rejects symbols that are not plain file names. Symbols are read from the
+// database, never trust them with a path.
+func checkFileSymbol(symbol string) error {
+	if strings.ContainsAny(symbol, `/\`) || strings.Contains(symbol, "..") {
+		return fmt.Errorf("invalid symbol %q", symbol)
+	}
+	return nil
+}
+
+func readCSVBars(path, symbol string) ([]types.NewStock, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header of %s: %w", path, err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"date", "open", "high", "low", "close", "volume"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s has no %s column", path, name)
+		}
+	}
+
+	var bars []types.NewStock
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		bar := types.NewStock{Symbol: symbol, Date: strings.TrimSpace(record[columns["date"]])}
+		if _, err := time.Parse("2006-01-02", bar.Date); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid date %q", path, line, bar.Date)
+		}
+		for name, target := range map[string]*float32{
+			"open": &bar.OpenPrice, "high": &bar.HighPrice, "low": &bar.LowPrice,
+			"close": &bar.ClosePrice, "volume": &bar.Volume,
+		} {
+			value, err := strconv.ParseFloat(strings.TrimSpace(record[columns[name]]), 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid %s %q", path, line, name, record[columns[name]])
+			}
+			*target = float32(value)
+		}
+		bars = append(bars, bar)
+	}
+	return bars, nil
+}
+
+func readJSONBars(path, symbol string) ([]types.NewStock, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var bars []types.NewStock
+	if err := json.Unmarshal(raw, &bars); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	for i := range bars {
+		bars[i].Symbol = symbol
+		if _, err := time.Parse("2006-01-02", bars[i].Date); err != nil {
+			return nil, fmt.Errorf("%s: invalid date %q", path, bars[i].Date)
+		}
+	}
+	return bars, nil
+}
@@ -0,0 +1,63 @@
+// Package marketdata fetches daily OHLCV bars from the configured market data source
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+const (
+	ProviderAlphaVantage = "alphavantage"
+	ProviderFile         = "file"
+	ProviderFake         = "fake"
+)
+
+// ErrRateLimited is wrapped by the errors of the calls a provider refused over its rate limit
+// or daily quota, which succeed again later
+var ErrRateLimited = errors.New("rate limit hit")
+
+// Provider is a source of daily bars
+type Provider interface {
+	// Name identifies the provider in logs and metrics
+	Name() string
+	// DailyBars returns the bars of symbol dated after since (YYYY-MM-DD, "" for every bar
+	// available), oldest first
+	DailyBars(ctx context.Context, symbol, since string) ([]types.NewStock, error)
+}
+
+// ActionProvider is implemented by the providers that also know the corporate actions of a
+// symbol. Each method makes a single call to the provider.
+type ActionProvider interface {
+	Splits(ctx context.Context, symbol string) ([]types.CorporateAction, error)
+	Dividends(ctx context.Context, symbol string) ([]types.CorporateAction, error)
+}
+
+// New builds the provider selected in the configuration
+func New(cfg config.MarketDataConfig) (Provider, error) {
+	switch cfg.Provider {
+	case ProviderAlphaVantage:
+		return NewAlphaVantage(cfg.BaseURL, cfg.APIKey, cfg.MinInterval), nil
+	case ProviderFile:
+		return NewFileProvider(cfg.DataDir), nil
+	case ProviderFake:
+		return NewFakeProvider(), nil
+	}
+	return nil, fmt.Errorf("unknown market data provider %q", cfg.Provider)
+}
+
+// after keeps the bars dated after since and sorts them oldest first
+func after(bars []types.NewStock, since string) []types.NewStock {
+	kept := make([]types.NewStock, 0, len(bars))
+	for _, bar := range bars {
+		if bar.Date > since {
+			kept = append(kept, bar)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Date < kept[j].Date })
+	return kept
+}
@@ -0,0 +1,59 @@
+package marketdata
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+const (
+	IntervalDaily     = "daily"
+	IntervalWeekly    = "weekly"
+	IntervalMonthly   = "monthly"
+	IntervalQuarterly = "quarterly"
+)
+
+// Resample aggregates daily bars sorted oldest first into bars of the interval: the open of
+// the first day, the close of the last one, the extremes and the summed volume. Weeks are ISO
+// weeks.
+func Resample(bars []types.Bar, interval string) ([]types.Bar, error) {
+	if interval == IntervalDaily || interval == "" {
+		return bars, nil
+	}
+
+	var resampled []types.Bar
+	currentPeriod := ""
+	for _, bar := range bars {
+		date, err := time.Parse("2006-01-02", bar.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bar date %q: %w", bar.Date, err)
+		}
+
+		var period string
+		switch interval {
+		case IntervalWeekly:
+			year, week := date.ISOWeek()
+			period = fmt.Sprintf("%d-W%02d", year, week)
+		case IntervalMonthly:
+			period = date.Format("2006-01")
+		case IntervalQuarterly:
+			period = fmt.Sprintf("%d-Q%d", date.Year(), (int(date.Month())-1)/3+1)
+		default:
+			return nil, fmt.Errorf("unknown interval %q", interval)
+		}
+
+		if period != currentPeriod {
+			currentPeriod = period
+			resampled = append(resampled, bar)
+			continue
+		}
+
+		last := &resampled[len(resampled)-1]
+		last.High = max(last.High, bar.High)
+		last.Low = min(last.Low, bar.Low)
+		last.Close = bar.Close
+		last.Volume += bar.Volume
+	}
+	return resampled, nil
+}
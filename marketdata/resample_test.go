@@ -0,0 +1,63 @@
+package marketdata
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+func TestResample(t *testing.T) {
+	daily := []types.Bar{
+		{Date: "2024-12-30", Open: 10, High: 12, Low: 9, Close: 11, Volume: 100},
+		{Date: "2024-12-31", Open: 11, High: 13, Low: 10, Close: 12, Volume: 100},
+		{Date: "2025-01-02", Open: 12, High: 15, Low: 11, Close: 14, Volume: 200},
+		{Date: "2025-01-06", Open: 14, High: 14, Low: 8, Close: 9, Volume: 300},
+		{Date: "2025-03-31", Open: 9, High: 10, Low: 9, Close: 10, Volume: 50},
+		{Date: "2025-04-01", Open: 10, High: 11, Low: 10, Close: 11, Volume: 50},
+	}
+
+	tests := []struct {
+		interval string
+		want     []types.Bar
+	}{
+		{"", daily},
+		{IntervalDaily, daily},
+		{IntervalWeekly, []types.Bar{
+			// ISO week 2025-W01 starts on Monday 2024-12-30
+			{Date: "2024-12-30", Open: 10, High: 15, Low: 9, Close: 14, Volume: 400},
+			{Date: "2025-01-06", Open: 14, High: 14, Low: 8, Close: 9, Volume: 300},
+			{Date: "2025-03-31", Open: 9, High: 11, Low: 9, Close: 11, Volume: 100},
+		}},
+		{IntervalMonthly, []types.Bar{
+			{Date: "2024-12-30", Open: 10, High: 13, Low: 9, Close: 12, Volume: 200},
+			{Date: "2025-01-02", Open: 12, High: 15, Low: 8, Close: 9, Volume: 500},
+			{Date: "2025-03-31", Open: 9, High: 10, Low: 9, Close: 10, Volume: 50},
+			{Date: "2025-04-01", Open: 10, High: 11, Low: 10, Close: 11, Volume: 50},
+		}},
+		{IntervalQuarterly, []types.Bar{
+			{Date: "2024-12-30", Open: 10, High: 13, Low: 9, Close: 12, Volume: 200},
+			{Date: "2025-01-02", Open: 12, High: 15, Low: 8, Close: 10, Volume: 550},
+			{Date: "2025-04-01", Open: 10, High: 11, Low: 10, Close: 11, Volume: 50},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := Resample(append([]types.Bar{}, daily...), tt.interval)
+		if err != nil {
+			t.Fatalf("Resample(%q): %v", tt.interval, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Resample(%q) =\n%+v\nwant\n%+v", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestResampleErrors(t *testing.T) {
+	bars := []types.Bar{{Date: "2025-01-02"}}
+	if _, err := Resample(bars, "hourly"); err == nil {
+		t.Error("expected an error for an unknown interval")
+	}
+	if _, err := Resample([]types.Bar{{Date: "02/01/2025"}}, IntervalWeekly); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "financial_ai"
+
+// Registry holds every metric exposed on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests served, by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	OutboundRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbound_requests_total",
+		Help:      "Requests made to external services, by service, endpoint and outcome.",
+	}, []string{"service", "endpoint", "outcome"})
+
+	// LLM calls can take tens of seconds so the buckets go well above the HTTP ones
+	OutboundDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "outbound_request_duration_seconds",
+		Help:      "Latency of the requests made to external services, by service and endpoint.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60},
+	}, []string{"service", "endpoint"})
+
+	MongoCommands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongo_commands_total",
+		Help:      "Mongo commands executed, by command and outcome.",
+	}, []string{"command", "outcome"})
+
+	MongoDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "Latency of the Mongo commands, by command.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"command"})
+
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by a rate limit, by policy.",
+	}, []string{"policy"})
+
+	StockSyncLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stock_sync_last_success_timestamp_seconds",
+		Help:      "Unix time of the last stock sync that completed without errors.",
+	})
+
+	StockSyncSymbolsUpdated = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stock_sync_symbols_updated",
+		Help:      "Symbols that received new bars in the last stock sync.",
+	})
+
+	StockSyncRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stock_sync_runs_total",
+		Help:      "Stock sync runs, by outcome.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		OutboundRequests,
+		OutboundDuration,
+		MongoCommands,
+		MongoDuration,
+		RateLimitRejections,
+		StockSyncLastSuccess,
+		StockSyncSymbolsUpdated,
+		StockSyncRuns,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveOutbound records a call to an external service that started at start and ended with err
+func ObserveOutbound(service, endpoint string, start time.Time, err error) {
+	OutboundDuration.WithLabelValues(service, endpoint).Observe(time.Since(start).Seconds())
+	OutboundRequests.WithLabelValues(service, endpoint, Outcome(err)).Inc()
+}
+
+// Outcome classifies an error as the outcome label of a metric
+func Outcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout"
+	}
+	return "error"
+}
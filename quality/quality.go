@@ -0,0 +1,203 @@
+// Package quality finds the problems of the stored daily bars that would silently distort
+// returns and indicators: trading days without a bar, bars that are unlikely to be right and
+// symbols the sync no longer keeps up to date.
+package quality
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/corporate"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/marketdata"
+	"github.com/arcedo/financial-ai-backend/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// StaleAfter is how many trading days a symbol may lag behind the provider before it is
+	// reported stale, enough for a sync run missed or cut short by the budget
+	StaleAfter = 3
+	// JumpThreshold is the split adjusted daily move above which a close is reported, more
+	// than even 3x leveraged funds make outside of crashes
+	JumpThreshold = 0.5
+	// DefaultPeriod is the period checked for symbols without any stored bar
+	DefaultPeriod = 365 * 24 * time.Hour
+)
+
+// Check reports the gaps and outliers of bars, the stored bars of symbol from from to to
+// (YYYY-MM-DD, both included) oldest first. Splits in actions are applied before looking for
+// price jumps.
+func Check(symbol string, bars []types.Bar, actions []types.CorporateAction, from, to string) (types.QualityReport, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return types.QualityReport{}, fmt.Errorf("invalid date %q: %w", from, err)
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return types.QualityReport{}, fmt.Errorf("invalid date %q: %w", to, err)
+	}
+
+	report := types.QualityReport{
+		Symbol:   symbol,
+		From:     from,
+		To:       to,
+		Bars:     len(bars),
+		Gaps:     []types.DataGap{},
+		Outliers: []types.DataOutlier{},
+	}
+
+	stored := make(map[string]bool, len(bars))
+	for _, bar := range bars {
+		stored[bar.Date] = true
+	}
+	days := marketdata.TradingDays(start, end)
+	report.ExpectedBars = len(days)
+	for i, day := range days {
+		if stored[day] {
+			continue
+		}
+		report.MissingDays++
+		// Consecutive trading days extend the current gap, weekends and holidays in between
+		// do not break it
+		if n := len(report.Gaps); n > 0 && i > 0 && report.Gaps[n-1].To == days[i-1] {
+			report.Gaps[n-1].To = day
+			report.Gaps[n-1].Days++
+			continue
+		}
+		report.Gaps = append(report.Gaps, types.DataGap{From: day, To: day, Days: 1})
+	}
+
+	adjusted := corporate.AdjustBars(bars, actions, false)
+	for i, bar := range bars {
+		report.Outliers = append(report.Outliers, outliers(bar)...)
+		if i == 0 || adjusted[i-1].Close <= 0 || adjusted[i].Close <= 0 {
+			continue
+		}
+		change := float64(adjusted[i].Close)/float64(adjusted[i-1].Close) - 1
+		if math.Abs(change) > JumpThreshold {
+			report.Outliers = append(report.Outliers, types.DataOutlier{
+				Date:   bar.Date,
+				Reason: "price_jump",
+				Detail: fmt.Sprintf("close moved %+.1f%% since %s without a known split", change*100, bars[i-1].Date),
+			})
+		}
+	}
+	return report, nil
+}
+
+// outliers lists what is wrong with a single bar
+func outliers(bar types.Bar) []types.DataOutlier {
+	var found []types.DataOutlier
+	add := func(reason, format string, args ...any) {
+		found = append(found, types.DataOutlier{Date: bar.Date, Reason: reason, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if day, err := time.Parse("2006-01-02", bar.Date); err == nil && !marketdata.IsTradingDay(day) {
+		add("non_trading_day", "bar on a %s the market is closed", day.Weekday())
+	}
+	if bar.Volume <= 0 {
+		add("zero_volume", "volume is %.0f", bar.Volume)
+	}
+	if bar.Open <= 0 || bar.High <= 0 || bar.Low <= 0 || bar.Close <= 0 {
+		add("non_positive_price", "open %.4f, high %.4f, low %.4f, close %.4f", bar.Open, bar.High, bar.Low, bar.Close)
+		return found
+	}
+	if bar.High < bar.Low {
+		add("high_below_low", "high %.4f is below low %.4f", bar.High, bar.Low)
+		return found
+	}
+	if bar.Open > bar.High || bar.Open < bar.Low {
+		add("open_outside_range", "open %.4f is outside %.4f-%.4f", bar.Open, bar.Low, bar.High)
+	}
+	if bar.Close > bar.High || bar.Close < bar.Low {
+		add("close_outside_range", "close %.4f is outside %.4f-%.4f", bar.Close, bar.Low, bar.High)
+	}
+	return found
+}
+
+// Staleness counts the trading days after latest up to the newest bar the provider can have
+// at now
+func Staleness(latest string, now time.Time) int {
+	last, err := time.Parse("2006-01-02", latest)
+	if err != nil {
+		return 0
+	}
+	expected, _ := time.Parse("2006-01-02", marketdata.ExpectedLatestDate(now))
+	return len(marketdata.TradingDays(last.AddDate(0, 0, 1), expected))
+}
+
+// CheckStore checks the stored bars of symbol. to defaults to the newest bar the provider can
+// have and from to the first stored bar, so the history before the listing or the first sync
+// is not reported missing, or to a year before to when nothing is stored.
+func CheckStore(ctx context.Context, store db.MongoStorage, symbol, from, to string, now time.Time) (types.QualityReport, error) {
+	first, latest, err := storedRange(ctx, store, symbol)
+	if err != nil {
+		return types.QualityReport{}, err
+	}
+	if to == "" {
+		to = marketdata.ExpectedLatestDate(now)
+	}
+	if from == "" {
+		from = first
+	}
+	if from == "" {
+		end, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return types.QualityReport{}, fmt.Errorf("invalid date %q: %w", to, err)
+		}
+		from = end.Add(-DefaultPeriod).Format("2006-01-02")
+	}
+
+	cursor, err := store.Collection("stocks").Find(ctx,
+		bson.M{"symbol": symbol, "date": bson.M{"$gte": from, "$lte": to}},
+		options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
+	if err != nil {
+		return types.QualityReport{}, fmt.Errorf("error retrieving stocks: %w", err)
+	}
+	var stocks []types.Stock
+	if err := cursor.All(ctx, &stocks); err != nil {
+		return types.QualityReport{}, fmt.Errorf("error decoding stocks: %w", err)
+	}
+	bars := make([]types.Bar, len(stocks))
+	for i, stock := range stocks {
+		bars[i] = stock.Bar()
+	}
+
+	actions, err := corporate.Load(ctx, store, symbol)
+	if err != nil {
+		return types.QualityReport{}, err
+	}
+
+	report, err := Check(symbol, bars, actions, from, to)
+	if err != nil {
+		return types.QualityReport{}, err
+	}
+	report.LatestDate = latest
+	report.StaleDays = Staleness(latest, now)
+	report.Stale = latest == "" || report.StaleDays > StaleAfter
+	return report, nil
+}
+
+// storedRange returns the dates of the first and latest stored bars of symbol, empty when
+// there are none
+func storedRange(ctx context.Context, store db.MongoStorage, symbol string) (string, string, error) {
+	var dates [2]string
+	for i, order := range []int{1, -1} {
+		var stock types.Stock
+		err := store.Collection("stocks").FindOne(ctx, bson.M{"symbol": symbol},
+			options.FindOne().SetSort(bson.D{{Key: "date", Value: order}})).Decode(&stock)
+		if err == mongo.ErrNoDocuments {
+			return "", "", nil
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("error retrieving stocks: %w", err)
+		}
+		dates[i] = stock.Date
+	}
+	return dates[0], dates[1], nil
+}
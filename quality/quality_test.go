@@ -0,0 +1,189 @@
+package quality
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+// bars builds clean bars closing at 100 on the given days
+func bars(days ...string) []types.Bar {
+	out := make([]types.Bar, len(days))
+	for i, day := range days {
+		out[i] = types.Bar{Date: day, Open: 100, High: 101, Low: 99, Close: 100, Volume: 1000}
+	}
+	return out
+}
+
+func without(all []types.Bar, dates ...string) []types.Bar {
+	var kept []types.Bar
+	for _, bar := range all {
+		if !slices.Contains(dates, bar.Date) {
+			kept = append(kept, bar)
+		}
+	}
+	return kept
+}
+
+// The trading days from 2025-06-30 to 2025-07-11, around the Independence Day holiday
+var fullPeriod = bars("2025-06-30", "2025-07-01", "2025-07-02", "2025-07-03",
+	"2025-07-07", "2025-07-08", "2025-07-09", "2025-07-10", "2025-07-11")
+
+func TestCheckGaps(t *testing.T) {
+	tests := []struct {
+		name        string
+		bars        []types.Bar
+		wantMissing int
+		wantGaps    []types.DataGap
+	}{
+		{"complete", fullPeriod, 0, []types.DataGap{}},
+		{
+			name:        "gap across the holiday and the weekend",
+			bars:        without(fullPeriod, "2025-07-03", "2025-07-07"),
+			wantMissing: 2,
+			wantGaps:    []types.DataGap{{From: "2025-07-03", To: "2025-07-07", Days: 2}},
+		},
+		{
+			name:        "separate gaps",
+			bars:        without(fullPeriod, "2025-07-01", "2025-07-09", "2025-07-10"),
+			wantMissing: 3,
+			wantGaps: []types.DataGap{
+				{From: "2025-07-01", To: "2025-07-01", Days: 1},
+				{From: "2025-07-09", To: "2025-07-10", Days: 2},
+			},
+		},
+		{
+			name:        "nothing stored",
+			wantMissing: 9,
+			wantGaps:    []types.DataGap{{From: "2025-06-30", To: "2025-07-11", Days: 9}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Check("AAPL", tt.bars, nil, "2025-06-30", "2025-07-11")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.ExpectedBars != 9 || report.Bars != len(tt.bars) || report.MissingDays != tt.wantMissing {
+				t.Errorf("expected %d, bars %d, missing %d, want 9, %d, %d",
+					report.ExpectedBars, report.Bars, report.MissingDays, len(tt.bars), tt.wantMissing)
+			}
+			if !reflect.DeepEqual(report.Gaps, tt.wantGaps) {
+				t.Errorf("gaps = %+v, want %+v", report.Gaps, tt.wantGaps)
+			}
+		})
+	}
+}
+
+func TestCheckOutliers(t *testing.T) {
+	tests := []struct {
+		name    string
+		bar     types.Bar
+		actions []types.CorporateAction
+		want    []string
+	}{
+		{name: "clean bar"},
+		{
+			name: "zero volume",
+			bar:  types.Bar{Open: 100, High: 101, Low: 99, Close: 100},
+			want: []string{"zero_volume"},
+		},
+		{
+			name: "non positive price",
+			bar:  types.Bar{Open: 100, High: 101, Low: 0, Close: 100, Volume: 1},
+			want: []string{"non_positive_price"},
+		},
+		{
+			name: "high below low",
+			bar:  types.Bar{Open: 100, High: 99, Low: 101, Close: 100, Volume: 1},
+			want: []string{"high_below_low"},
+		},
+		{
+			name: "open and close outside the range",
+			bar:  types.Bar{Open: 102, High: 101, Low: 99, Close: 98, Volume: 1},
+			want: []string{"open_outside_range", "close_outside_range"},
+		},
+		{
+			name: "price jump",
+			bar:  types.Bar{Open: 49, High: 50, Low: 44, Close: 45, Volume: 1},
+			want: []string{"price_jump"},
+		},
+		{
+			name:    "price jump explained by a split",
+			bar:     types.Bar{Open: 49, High: 51, Low: 48, Close: 50, Volume: 1},
+			actions: []types.CorporateAction{{Type: types.ActionSplit, ExDate: "2025-07-02", Ratio: 2}},
+		},
+		{
+			name: "move below the threshold",
+			bar:  types.Bar{Open: 60, High: 61, Low: 59, Close: 60, Volume: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := bars("2025-07-01", "2025-07-02")
+			if tt.bar != (types.Bar{}) {
+				tt.bar.Date = "2025-07-02"
+				day[1] = tt.bar
+			}
+			report, err := Check("AAPL", day, tt.actions, "2025-07-01", "2025-07-02")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var reasons []string
+			for _, outlier := range report.Outliers {
+				if outlier.Date != "2025-07-02" {
+					t.Errorf("outlier dated %s", outlier.Date)
+				}
+				reasons = append(reasons, outlier.Reason)
+			}
+			if !reflect.DeepEqual(reasons, tt.want) {
+				t.Errorf("outliers = %v, want %v", reasons, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBarOnClosedDay(t *testing.T) {
+	report, err := Check("AAPL", bars("2025-07-03", "2025-07-04"), nil, "2025-07-03", "2025-07-04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Outliers) != 1 || report.Outliers[0].Reason != "non_trading_day" || report.Outliers[0].Date != "2025-07-04" {
+		t.Errorf("outliers = %+v, want the holiday bar", report.Outliers)
+	}
+}
+
+func TestCheckInvalidDates(t *testing.T) {
+	if _, err := Check("AAPL", nil, nil, "07/01/2025", "2025-07-02"); err == nil {
+		t.Error("expected an error for an invalid from")
+	}
+	if _, err := Check("AAPL", nil, nil, "2025-07-01", ""); err == nil {
+		t.Error("expected an error for an empty to")
+	}
+}
+
+func TestStaleness(t *testing.T) {
+	friday := time.Date(2025, 7, 11, 22, 0, 0, 0, time.UTC)
+	tests := []struct {
+		latest string
+		now    time.Time
+		want   int
+	}{
+		{"2025-07-11", friday, 0},
+		{"2025-07-10", friday, 1},
+		{"2025-07-10", friday.Add(-2 * time.Hour), 0},
+		{"2025-07-03", friday, 5},
+		// Over the weekend nothing new is expected
+		{"2025-07-11", friday.AddDate(0, 0, 2), 0},
+		{"", friday, 0},
+	}
+	for _, tt := range tests {
+		if got := Staleness(tt.latest, tt.now); got != tt.want {
+			t.Errorf("Staleness(%q, %s) = %d, want %d", tt.latest, tt.now, got, tt.want)
+		}
+	}
+}
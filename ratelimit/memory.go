@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired entries are dropped from a MemoryStore
+const sweepInterval = time.Minute
+
+// MemoryStore keeps the limits in process memory, for single instance deployments
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	tokens    float64
+	count     int
+	updatedAt time.Time
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+func (s *MemoryStore) TakeToken(_ context.Context, key string, bucket Bucket, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{tokens: float64(bucket.Burst), updatedAt: now}
+		s.entries[key] = entry
+	}
+
+	entry.tokens = bucket.refill(entry.tokens, entry.updatedAt, now)
+	entry.updatedAt = now
+	entry.expiresAt = now.Add(bucket.refillTime())
+
+	allowed := entry.tokens >= 1
+	if allowed {
+		entry.tokens--
+	}
+	return bucket.result(entry.tokens, allowed), nil
+}
+
+func (s *MemoryStore) CountHit(_ context.Context, key string, quota Quota, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	start := quota.windowStart(now)
+	key = windowKey(key, start)
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{expiresAt: start.Add(quota.Window)}
+		s.entries[key] = entry
+	}
+
+	entry.count++
+	return quota.result(entry.count, start, now), nil
+}
+
+// sweep drops the expired entries, at most once per sweepInterval. Callers hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
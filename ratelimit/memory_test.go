@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTakeToken(t *testing.T) {
+	bucket := Bucket{Name: "api", Rate: 60, Period: time.Minute, Burst: 3}
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		after         time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{"full bucket", 0, true, 2, 0},
+		{"second token", 0, true, 1, 0},
+		{"last token", 0, true, 0, 0},
+		{"empty bucket", 0, false, 0, time.Second},
+		{"refilled a token", time.Second, true, 0, 0},
+		{"half a token is not enough", 1500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+		{"refill stops at the burst", time.Hour, true, 2, 0},
+	}
+	store := NewMemoryStore()
+	for _, tt := range tests {
+		res, err := store.TakeToken(context.Background(), "ip:1.2.3.4", bucket, start.Add(tt.after))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != tt.wantAllowed || res.Remaining != tt.wantRemaining || res.RetryAfter != tt.wantRetry {
+			t.Errorf("%s: allowed %v, remaining %d, retry after %v, want %v, %d, %v",
+				tt.name, res.Allowed, res.Remaining, res.RetryAfter, tt.wantAllowed, tt.wantRemaining, tt.wantRetry)
+		}
+		if res.Limit != 3 || res.Policy != "60;w=60" {
+			t.Errorf("%s: limit %d, policy %q", tt.name, res.Limit, res.Policy)
+		}
+	}
+
+	// Every client has its own bucket
+	res, _ := store.TakeToken(context.Background(), "ip:5.6.7.8", bucket, start)
+	if !res.Allowed || res.Remaining != 2 || res.Reset != time.Second {
+		t.Errorf("other client: %+v", res)
+	}
+}
+
+func TestMemoryStoreCountHit(t *testing.T) {
+	quota := Quota{Name: "llm", Limit: 2, Window: 24 * time.Hour}
+	lateEvening := time.Date(2025, 7, 1, 23, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		now           time.Time
+		wantAllowed   bool
+		wantRemaining int
+		wantReset     time.Duration
+	}{
+		{"first call", lateEvening, true, 1, time.Hour},
+		{"last call", lateEvening.Add(30 * time.Minute), true, 0, 30 * time.Minute},
+		{"over the quota", lateEvening.Add(45 * time.Minute), false, 0, 15 * time.Minute},
+		{"new day at midnight UTC", lateEvening.Add(time.Hour), true, 1, 24 * time.Hour},
+	}
+	store := NewMemoryStore()
+	for _, tt := range tests {
+		res, err := store.CountHit(context.Background(), "user:1", quota, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != tt.wantAllowed || res.Remaining != tt.wantRemaining || res.Reset != tt.wantReset {
+			t.Errorf("%s: allowed %v, remaining %d, reset %v, want %v, %d, %v",
+				tt.name, res.Allowed, res.Remaining, res.Reset, tt.wantAllowed, tt.wantRemaining, tt.wantReset)
+		}
+		if !res.Allowed && res.RetryAfter != res.Reset {
+			t.Errorf("%s: retry after %v, want the reset %v", tt.name, res.RetryAfter, res.Reset)
+		}
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	bucket := Bucket{Name: "api", Rate: 1, Period: time.Second, Burst: 5}
+	quota := Quota{Name: "llm", Limit: 1, Window: time.Hour}
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	store := NewMemoryStore()
+	store.TakeToken(context.Background(), "a", bucket, now)
+	store.CountHit(context.Background(), "b", quota, now)
+	if len(store.entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(store.entries))
+	}
+
+	// The bucket refills in 5s and the window ends at 13:00
+	store.TakeToken(context.Background(), "c", bucket, now.Add(10*time.Minute))
+	if _, ok := store.entries["a"]; ok || len(store.entries) != 2 {
+		t.Errorf("entries after the bucket refilled: %v", store.entries)
+	}
+	store.TakeToken(context.Background(), "c", bucket, now.Add(time.Hour))
+	if len(store.entries) != 1 {
+		t.Errorf("entries after the window ended: %v", store.entries)
+	}
+}
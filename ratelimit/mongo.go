@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps the limits in a Mongo collection so every instance shares them. The
+// collection needs a TTL index on expires_at to drop the stale entries.
+type MongoStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoStore(coll *mongo.Collection) *MongoStore {
+	return &MongoStore{coll: coll}
+}
+
+func (s *MongoStore) TakeToken(ctx context.Context, key string, bucket Bucket, now time.Time) (Result, error) {
+	// Refill, take and store the token in a single atomic pipeline update
+	refilled := bson.M{"$min": bson.A{
+		bucket.Burst,
+		bson.M{"$add": bson.A{
+			bson.M{"$ifNull": bson.A{"$tokens", bucket.Burst}},
+			bson.M{"$multiply": bson.A{
+				bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}}}},
+				bucket.tokensPerSecond() / 1000,
+			}},
+		}},
+	}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilled, "updated_at": now}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens":     bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"expires_at": now.Add(bucket.refillTime()),
+		}}},
+	}
+
+	var state struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	if err := s.upsert(ctx, key, pipeline, &state); err != nil {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return bucket.result(state.Tokens, state.Allowed), nil
+}
+
+func (s *MongoStore) CountHit(ctx context.Context, key string, quota Quota, now time.Time) (Result, error) {
+	start := quota.windowStart(now)
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expires_at": start.Add(quota.Window)},
+	}
+
+	var state struct {
+		Count int `bson:"count"`
+	}
+	if err := s.upsert(ctx, windowKey(key, start), update, &state); err != nil {
+		return Result{}, fmt.Errorf("failed to count rate limit hit: %w", err)
+	}
+	return quota.result(state.Count, start, now), nil
+}
+
+// upsert applies update to the entry of key and decodes the updated entry into out
+func (s *MongoStore) upsert(ctx context.Context, key string, update any, out any) error {
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(out)
+	// Two instances inserting the same new key race on _id, the loser just retries as an update
+	if mongo.IsDuplicateKeyError(err) {
+		err = s.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(out)
+	}
+	return err
+}
+
+// windowKey identifies the counter of key for the window starting at start
+func windowKey(key string, start time.Time) string {
+	return key + ":" + strconv.FormatInt(start.Unix(), 10)
+}
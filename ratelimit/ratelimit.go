@@ -0,0 +1,108 @@
+// Package ratelimit implements the token buckets and fixed window quotas used to throttle
+// API clients, on top of a pluggable Store so several instances can share their counters.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Bucket is a token bucket holding up to Burst tokens, refilled with Rate tokens every Period.
+// Every request takes one token and is rejected when the bucket is empty.
+type Bucket struct {
+	Name   string
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// Quota allows Limit requests per fixed Window, aligned to UTC (a 24h window resets at midnight UTC)
+type Quota struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Result describes the state of a limit after a request was counted against it
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully available again
+	Reset time.Duration
+	// RetryAfter is the time until a rejected request can be retried
+	RetryAfter time.Duration
+	// Policy is the RateLimit-Policy description of the limit
+	Policy string
+}
+
+// Store keeps the state of the limits. Implementations must be safe for concurrent use and
+// update each key atomically.
+type Store interface {
+	// TakeToken takes a token from the bucket identified by key
+	TakeToken(ctx context.Context, key string, bucket Bucket, now time.Time) (Result, error)
+	// CountHit counts a request against the quota identified by key
+	CountHit(ctx context.Context, key string, quota Quota, now time.Time) (Result, error)
+}
+
+// tokensPerSecond is the refill speed of the bucket
+func (b Bucket) tokensPerSecond() float64 {
+	return float64(b.Rate) / b.Period.Seconds()
+}
+
+// refillTime is how long an empty bucket takes to fill up, after which its state can be dropped
+func (b Bucket) refillTime() time.Duration {
+	return time.Duration(float64(b.Burst) / b.tokensPerSecond() * float64(time.Second))
+}
+
+// refill returns the tokens left in a bucket that had tokens at updated
+func (b Bucket) refill(tokens float64, updated, now time.Time) float64 {
+	elapsed := now.Sub(updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(b.Burst), tokens+elapsed*b.tokensPerSecond())
+}
+
+// result describes the bucket holding tokens after a request that was allowed or not
+func (b Bucket) result(tokens float64, allowed bool) Result {
+	rate := b.tokensPerSecond()
+	res := Result{
+		Allowed:   allowed,
+		Limit:     b.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(b.Burst) - tokens) / rate),
+		Policy:    fmt.Sprintf("%d;w=%d", b.Rate, int(b.Period.Seconds())),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return res
+}
+
+// windowStart returns the start of the window now falls in
+func (q Quota) windowStart(now time.Time) time.Time {
+	return now.UTC().Truncate(q.Window)
+}
+
+// result describes the quota after count requests in the window starting at start
+func (q Quota) result(count int, start, now time.Time) Result {
+	reset := start.Add(q.Window).Sub(now)
+	res := Result{
+		Allowed:   count <= q.Limit,
+		Limit:     q.Limit,
+		Remaining: max(q.Limit-count, 0),
+		Reset:     reset,
+		Policy:    fmt.Sprintf("%d;w=%d", q.Limit, int(q.Window.Seconds())),
+	}
+	if !res.Allowed {
+		res.RetryAfter = reset
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
@@ -1,12 +1,17 @@
 package requests
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
+	"net/http"
+	"time"
 
 	"bytes"
 
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/arcedo/financial-ai-backend/metrics"
 	"github.com/arcedo/financial-ai-backend/types"
 	"github.com/arcedo/financial-ai-backend/utils"
 )
@@ -15,91 +20,142 @@ type ProfileWrapper struct {
 	Profile types.Insights `json:"profile"`
 }
 
-func RequestUpdateUserProfile(data types.Insights) (types.UserProfile, error) {
-	url := fmt.Sprintf("%s/update?id=%s", os.Getenv("LLM_HOST"), data.User.ID.Hex())
-	headers := map[string]string{
-		"x-api-key":    os.Getenv("LLM_API_KEY"),
+// LLMClient talks to the llama microservice
+type LLMClient struct {
+	host           string
+	apiKey         string
+	timeout        time.Duration
+	sendIndicators bool
+}
+
+func NewLLMClient(cfg config.LLMConfig) *LLMClient {
+	return &LLMClient{
+		host:           cfg.Host,
+		apiKey:         cfg.APIKey,
+		timeout:        cfg.Timeout,
+		sendIndicators: cfg.SendIndicators,
+	}
+}
+
+func (c *LLMClient) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":    c.apiKey,
 		"Content-Type": "application/json",
 	}
+}
+
+// do calls an endpoint of the llama service and records its latency and outcome
+func (c *LLMClient) do(ctx context.Context, endpoint, method, url string, body io.Reader) ([]byte, error) {
+	start := time.Now()
+	respBody, err := utils.MakeHTTPRequest(ctx, method, url, c.headers(), body, c.timeout)
+	metrics.ObserveOutbound("llm", endpoint, start, err)
+	return respBody, err
+}
+
+// Ping checks that the llama service answers at all; any HTTP response below 500 counts
+func (c *LLMClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("llm host unreachable: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("llm host answered with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (c *LLMClient) RequestUpdateUserProfile(ctx context.Context, data types.Insights) (types.UserProfile, error) {
+	url := fmt.Sprintf("%s/update?id=%s", c.host, data.User.ID.Hex())
 
 	wrapped := ProfileWrapper{Profile: data}
 
 	bodyBytes, err := json.Marshal(wrapped)
 	if err != nil {
-		return types.UserProfile{}, fmt.Errorf("failed to marshal data: %v", err)
+		return types.UserProfile{}, fmt.Errorf("failed to marshal data: %w", err)
 	}
 
 	// Wrap the byte slice into an io.Reader
 	bodyReader := bytes.NewReader(bodyBytes)
 
-	respBody, err := utils.MakeHTTPRequest("POST", url, headers, bodyReader)
+	respBody, err := c.do(ctx, "update", "POST", url, bodyReader)
 	if err != nil {
-		return types.UserProfile{}, fmt.Errorf("failed to make request: %v", err)
+		return types.UserProfile{}, utils.NewUpstreamError("llm", fmt.Errorf("failed to make request: %w", err))
 	}
 
 	var result types.UserProfile
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return types.UserProfile{}, fmt.Errorf("failed to decode LLM response: %w", err)
+		return types.UserProfile{}, utils.NewUpstreamError("llm", fmt.Errorf("failed to decode LLM response: %w", err))
 	}
 
 	return result, nil
 }
 
-func GetRecommendations(userID string) ([]types.Recommendation, error) {
-	url := fmt.Sprintf("%s/get_recommendations?id=%s", os.Getenv("LLM_HOST"), userID)
-	headers := map[string]string{
-		"x-api-key":    os.Getenv("LLM_API_KEY"),
-		"Content-Type": "application/json",
-	}
+func (c *LLMClient) GetRecommendations(ctx context.Context, userID string) ([]types.Recommendation, error) {
+	url := fmt.Sprintf("%s/get_recommendations?id=%s", c.host, userID)
 
-	respBody, err := utils.MakeHTTPRequest("GET", url, headers, nil)
+	respBody, err := c.do(ctx, "get_recommendations", "GET", url, nil)
 	if err != nil {
-		return []types.Recommendation{}, fmt.Errorf("failed to make request: %v", err)
+		return []types.Recommendation{}, utils.NewUpstreamError("llm", fmt.Errorf("failed to make request: %w", err))
 	}
 
 	var result []types.Recommendation
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return []types.Recommendation{}, fmt.Errorf("failed to decode LLM response: %w", err)
+		return []types.Recommendation{}, utils.NewUpstreamError("llm", fmt.Errorf("failed to decode LLM response: %w", err))
 	}
 
 	return result, nil
 }
 
-func GetAdvice(userID string) (types.Advice, error) {
-	url := fmt.Sprintf("%s/get_ai_assisted_investments?id=%s", os.Getenv("LLM_HOST"), userID)
-	headers := map[string]string{
-		"x-api-key":    os.Getenv("LLM_API_KEY"),
-		"Content-Type": "application/json",
-	}
+func (c *LLMClient) GetAdvice(ctx context.Context, userID string) (types.Advice, error) {
+	url := fmt.Sprintf("%s/get_ai_assisted_investments?id=%s", c.host, userID)
 
-	respBody, err := utils.MakeHTTPRequest("GET", url, headers, nil)
+	respBody, err := c.do(ctx, "get_ai_assisted_investments", "GET", url, nil)
 	if err != nil {
-		return types.Advice{}, fmt.Errorf("failed to make request: %v", err)
+		return types.Advice{}, utils.NewUpstreamError("llm", fmt.Errorf("failed to make request: %w", err))
 	}
 
 	var result types.Advice
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return types.Advice{}, fmt.Errorf("failed to decode LLM response: %w", err)
+		return types.Advice{}, utils.NewUpstreamError("llm", fmt.Errorf("failed to decode LLM response: %w", err))
 	}
 
 	return result, nil
 }
 
-func GetAssetRecommendation(userID string, symbol string) (int, error) {
-	url := fmt.Sprintf("%s/get_asset_recommendation?id=%s&symbol=%s", os.Getenv("LLM_HOST"), userID, symbol)
-	headers := map[string]string{
-		"x-api-key":    os.Getenv("LLM_API_KEY"),
-		"Content-Type": "application/json",
+// SendsIndicators reports whether asset recommendations should carry the indicators
+func (c *LLMClient) SendsIndicators() bool {
+	return c.sendIndicators
+}
+
+// GetAssetRecommendation scores an asset for the user. When indicators is not nil it is
+// posted as {"indicators": ...} so the answer accounts for the recent price action.
+func (c *LLMClient) GetAssetRecommendation(ctx context.Context, userID string, symbol string, indicators *types.IndicatorSnapshot) (int, error) {
+	url := fmt.Sprintf("%s/get_asset_recommendation?id=%s&symbol=%s", c.host, userID, symbol)
+
+	method, body := "GET", io.Reader(nil)
+	if indicators != nil {
+		bodyBytes, err := json.Marshal(map[string]any{"indicators": indicators})
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal indicators: %w", err)
+		}
+		method, body = "POST", bytes.NewReader(bodyBytes)
 	}
 
-	respBody, err := utils.MakeHTTPRequest("GET", url, headers, nil)
+	respBody, err := c.do(ctx, "get_asset_recommendation", method, url, body)
 	if err != nil {
-		return 0, fmt.Errorf("failed to make request: %v", err)
+		return 0, utils.NewUpstreamError("llm", fmt.Errorf("failed to make request: %w", err))
 	}
 
 	var result int
 	if err := json.Unmarshal(respBody, &result); err != nil {
-		return 0, fmt.Errorf("failed to decode LLM response: %w", err)
+		return 0, utils.NewUpstreamError("llm", fmt.Errorf("failed to decode LLM response: %w", err))
 	}
 
 	return result, nil
@@ -0,0 +1,64 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/arcedo/financial-ai-backend/utils"
+)
+
+const mailTimeout = 10 * time.Second
+
+type verificationMail struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer sends transactional emails through a webhook
+type Mailer struct {
+	appURL     string
+	webhookURL string
+}
+
+func NewMailer(cfg config.MailConfig) *Mailer {
+	return &Mailer{appURL: cfg.AppURL, webhookURL: cfg.WebhookURL}
+}
+
+// SendEmailVerification sends the link that confirms an email change.
+// Without a webhook configured the link is only logged at debug level, which is enough for
+// local development. Its token verifies the change, so it stays out of the logs otherwise.
+func (m *Mailer) SendEmailVerification(ctx context.Context, email, token string) error {
+	link := fmt.Sprintf("%s/verify-email?token=%s", m.appURL, url.QueryEscape(token))
+
+	webhook := m.webhookURL
+	if webhook == "" {
+		logger := utils.LoggerFrom(ctx)
+		logger.Warn("no mail webhook configured, the email verification link is only logged at debug level", "email", email)
+		logger.Debug("email verification link", "email", email, "link", link)
+		return nil
+	}
+
+	bodyBytes, err := json.Marshal(verificationMail{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body:    fmt.Sprintf("Open this link to confirm your new email address: %s", link),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal mail: %w", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	if _, err := utils.MakeHTTPRequest(ctx, "POST", webhook, headers, bytes.NewReader(bodyBytes), mailTimeout); err != nil {
+		return utils.NewUpstreamError("mail", fmt.Errorf("failed to send verification mail: %w", err))
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/arcedo/financial-ai-backend/utils"
+)
+
+func TestEmailVerificationLinkOnlyLoggedAtDebug(t *testing.T) {
+	tests := []struct {
+		level     slog.Level
+		wantToken bool
+	}{
+		{slog.LevelInfo, false},
+		{slog.LevelDebug, true},
+	}
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: tt.level}))
+		ctx := utils.WithLogger(context.Background(), logger)
+
+		mailer := NewMailer(config.MailConfig{AppURL: "https://app.example.com"})
+		if err := mailer.SendEmailVerification(ctx, "user@example.com", "secret-token"); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(logs.String(), "secret-token"); got != tt.wantToken {
+			t.Errorf("at %s the token logged = %v, want %v:\n%s", tt.level, got, tt.wantToken, logs.String())
+		}
+	}
+}
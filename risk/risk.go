@@ -0,0 +1,189 @@
+// Package risk discloses the risks of products whose return drifts from what their name
+// suggests: leveraged and inverse funds, which reset their exposure every day and decay
+// against their underlying when held longer.
+package risk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/corporate"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/indicators"
+	"github.com/arcedo/financial-ai-backend/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// ErrNoUnderlying is returned for products without an underlying in the catalog
+	ErrNoUnderlying = errors.New("product has no underlying")
+	// ErrNotEnoughData is returned when the fund and its underlying share less than two days
+	// of prices in the period
+	ErrNotEnoughData = errors.New("not enough prices to compare with the underlying")
+)
+
+// DefaultPeriod is the period analyzed when none is given
+const DefaultPeriod = 365 * 24 * time.Hour
+
+// DecayProne reports the leveraged and inverse funds
+func DecayProne(product types.Product) bool {
+	return product.Inverse || product.Leverage > 0 && product.Leverage != 1
+}
+
+// Multiple is the daily multiple of the underlying's return a product targets, negative for
+// inverse funds
+func Multiple(product types.Product) float64 {
+	multiple := product.Leverage
+	if multiple <= 0 {
+		multiple = 1
+	}
+	if product.Inverse {
+		multiple = -multiple
+	}
+	return multiple
+}
+
+// Warning describes the risk of a decay prone product, with its recent decay when known
+func Warning(product types.Product, decay *types.DecayAnalysis) types.RiskWarning {
+	code, severity := "leveraged_fund", "medium"
+	if product.Inverse {
+		code = "inverse_fund"
+	}
+	if product.Leverage >= 2 {
+		severity = "high"
+	}
+
+	underlying := product.Underlying
+	if underlying == "" {
+		underlying = "its benchmark"
+	}
+	message := fmt.Sprintf("%s targets %sx the daily return of %s and resets its exposure every day. "+
+		"Held for longer, its return drifts from that multiple, usually downwards as volatility rises, "+
+		"and losses can quickly exceed those of %s.",
+		product.Symbol, strconv.FormatFloat(Multiple(product), 'f', -1, 64), underlying, underlying)
+	if decay != nil {
+		message += fmt.Sprintf(" From %s to %s it returned %.1f%% while %sx %s returned %.1f%%.",
+			decay.From, decay.To, decay.FundReturn*100,
+			strconv.FormatFloat(Multiple(product), 'f', -1, 64), decay.Underlying, decay.TargetReturn*100)
+	}
+
+	return types.RiskWarning{Code: code, Severity: severity, Message: message, Decay: decay}
+}
+
+// Decay compares the bars of a fund and its underlying, adjusted and sorted oldest first, on
+// the days both have a price
+func Decay(fund, underlying []types.Bar, multiple float64) (types.DecayAnalysis, error) {
+	underlyingCloses := make(map[string]float64, len(underlying))
+	for _, bar := range underlying {
+		underlyingCloses[bar.Date] = float64(bar.Close)
+	}
+
+	var dates []string
+	var fundCloses, closes []float64
+	for _, bar := range fund {
+		if underlyingClose, ok := underlyingCloses[bar.Date]; ok && underlyingClose > 0 && bar.Close > 0 {
+			dates = append(dates, bar.Date)
+			fundCloses = append(fundCloses, float64(bar.Close))
+			closes = append(closes, underlyingClose)
+		}
+	}
+	n := len(dates)
+	if n < 2 {
+		return types.DecayAnalysis{}, ErrNotEnoughData
+	}
+
+	dailyTarget := 1.0
+	for i := 1; i < n; i++ {
+		dailyTarget *= 1 + multiple*(closes[i]/closes[i-1]-1)
+	}
+
+	analysis := types.DecayAnalysis{
+		Leverage:          math.Abs(multiple),
+		Inverse:           multiple < 0,
+		From:              dates[0],
+		To:                dates[n-1],
+		FundReturn:        fundCloses[n-1]/fundCloses[0] - 1,
+		UnderlyingReturn:  closes[n-1]/closes[0] - 1,
+		DailyTargetReturn: dailyTarget - 1,
+	}
+	analysis.TargetReturn = multiple * analysis.UnderlyingReturn
+	analysis.VolatilityDecay = analysis.TargetReturn - analysis.DailyTargetReturn
+	analysis.Decay = analysis.TargetReturn - analysis.FundReturn
+
+	from, _ := time.Parse("2006-01-02", analysis.From)
+	to, _ := time.Parse("2006-01-02", analysis.To)
+	analysis.Days = int(to.Sub(from).Hours() / 24)
+	if n > 2 {
+		analysis.UnderlyingVolatility = indicators.Volatility(closes, n-1)[n-1]
+	}
+	return analysis, nil
+}
+
+// Analyze computes the decay of product against its underlying between from and to
+// (YYYY-MM-DD, inclusive) from the stored bars adjusted for splits and dividends. An empty to
+// means today and an empty from DefaultPeriod before to.
+func Analyze(ctx context.Context, store db.MongoStorage, product types.Product, from, to string) (*types.DecayAnalysis, error) {
+	if product.Underlying == "" {
+		return nil, ErrNoUnderlying
+	}
+	if to == "" {
+		to = time.Now().UTC().Format("2006-01-02")
+	}
+	if from == "" {
+		end, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: %w", to, err)
+		}
+		from = end.Add(-DefaultPeriod).Format("2006-01-02")
+	}
+
+	actions, err := corporate.Load(ctx, store, product.Symbol, product.Underlying)
+	if err != nil {
+		return nil, err
+	}
+	bySymbol := map[string][]types.CorporateAction{}
+	for _, action := range actions {
+		bySymbol[action.Symbol] = append(bySymbol[action.Symbol], action)
+	}
+
+	var series [2][]types.Bar
+	for i, symbol := range []string{product.Symbol, product.Underlying} {
+		bars, err := loadBars(ctx, store, symbol, from, to)
+		if err != nil {
+			return nil, err
+		}
+		series[i] = corporate.AdjustBars(bars, bySymbol[symbol], true)
+	}
+
+	analysis, err := Decay(series[0], series[1], Multiple(product))
+	if err != nil {
+		return nil, err
+	}
+	analysis.Symbol = product.Symbol
+	analysis.Underlying = product.Underlying
+	return &analysis, nil
+}
+
+func loadBars(ctx context.Context, store db.MongoStorage, symbol, from, to string) ([]types.Bar, error) {
+	cursor, err := store.Collection("stocks").Find(ctx,
+		bson.M{"symbol": symbol, "date": bson.M{"$gte": from, "$lte": to}},
+		options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving stocks: %w", err)
+	}
+	var stocks []types.Stock
+	if err := cursor.All(ctx, &stocks); err != nil {
+		return nil, fmt.Errorf("error decoding stocks: %w", err)
+	}
+
+	bars := make([]types.Bar, len(stocks))
+	for i, stock := range stocks {
+		bars[i] = stock.Bar()
+	}
+	return bars, nil
+}
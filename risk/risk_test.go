@@ -0,0 +1,123 @@
+package risk
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+func TestMultiple(t *testing.T) {
+	tests := []struct {
+		product    types.Product
+		wantMult   float64
+		wantProne  bool
+		wantCode   string
+		wantSevere string
+	}{
+		{types.Product{Symbol: "AAPL"}, 1, false, "leveraged_fund", "medium"},
+		{types.Product{Symbol: "SPY", Leverage: 1}, 1, false, "leveraged_fund", "medium"},
+		{types.Product{Symbol: "SSO", Leverage: 2}, 2, true, "leveraged_fund", "high"},
+		{types.Product{Symbol: "TQQQ", Leverage: 3}, 3, true, "leveraged_fund", "high"},
+		{types.Product{Symbol: "SH", Leverage: 1, Inverse: true}, -1, true, "inverse_fund", "medium"},
+		{types.Product{Symbol: "SQQQ", Leverage: 3, Inverse: true}, -3, true, "inverse_fund", "high"},
+		{types.Product{Symbol: "UPRO", Leverage: 1.5}, 1.5, true, "leveraged_fund", "medium"},
+	}
+	for _, tt := range tests {
+		if got := Multiple(tt.product); got != tt.wantMult {
+			t.Errorf("Multiple(%s) = %v, want %v", tt.product.Symbol, got, tt.wantMult)
+		}
+		if got := DecayProne(tt.product); got != tt.wantProne {
+			t.Errorf("DecayProne(%s) = %v, want %v", tt.product.Symbol, got, tt.wantProne)
+		}
+		warning := Warning(tt.product, nil)
+		if warning.Code != tt.wantCode || warning.Severity != tt.wantSevere {
+			t.Errorf("Warning(%s) = %s/%s, want %s/%s", tt.product.Symbol, warning.Code, warning.Severity, tt.wantCode, tt.wantSevere)
+		}
+	}
+}
+
+func TestWarningMessage(t *testing.T) {
+	product := types.Product{Symbol: "SQQQ", Leverage: 3, Inverse: true, Underlying: "QQQ"}
+	if message := Warning(product, nil).Message; !strings.Contains(message, "SQQQ targets -3x the daily return of QQQ") {
+		t.Errorf("message = %q", message)
+	}
+	if message := Warning(types.Product{Symbol: "SSO", Leverage: 2}, nil).Message; !strings.Contains(message, "of its benchmark") {
+		t.Errorf("message without underlying = %q", message)
+	}
+
+	decay := &types.DecayAnalysis{Underlying: "QQQ", From: "2025-01-02", To: "2025-07-01", FundReturn: -0.42, TargetReturn: -0.3}
+	warning := Warning(product, decay)
+	if !strings.Contains(warning.Message, "From 2025-01-02 to 2025-07-01 it returned -42.0% while -3x QQQ returned -30.0%.") {
+		t.Errorf("message with decay = %q", warning.Message)
+	}
+	if warning.Decay != decay {
+		t.Error("the decay analysis must be attached to the warning")
+	}
+}
+
+func TestDecay(t *testing.T) {
+	underlying := []types.Bar{
+		{Date: "2025-07-01", Close: 100},
+		{Date: "2025-07-02", Close: 110},
+		{Date: "2025-07-03", Close: 99},
+	}
+	// A 2x fund rising 20% then falling 20%, with a bar the underlying lacks
+	fund := []types.Bar{
+		{Date: "2025-06-30", Close: 90},
+		{Date: "2025-07-01", Close: 100},
+		{Date: "2025-07-02", Close: 120},
+		{Date: "2025-07-03", Close: 96},
+	}
+
+	analysis, err := Decay(fund, underlying, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.From != "2025-07-01" || analysis.To != "2025-07-03" || analysis.Days != 2 || analysis.Leverage != 2 || analysis.Inverse {
+		t.Errorf("analysis = %+v", analysis)
+	}
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"fund return", analysis.FundReturn, -0.04},
+		{"underlying return", analysis.UnderlyingReturn, -0.01},
+		{"target return", analysis.TargetReturn, -0.02},
+		{"daily target return", analysis.DailyTargetReturn, -0.04},
+		{"volatility decay", analysis.VolatilityDecay, 0.02},
+		{"decay", analysis.Decay, 0.02},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-6 {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if analysis.UnderlyingVolatility <= 0 {
+		t.Errorf("underlying volatility = %v", analysis.UnderlyingVolatility)
+	}
+
+	inverse, err := Decay(fund, underlying, -1)
+	if err != nil || !inverse.Inverse || inverse.Leverage != 1 || math.Abs(inverse.TargetReturn-0.01) > 1e-6 {
+		t.Errorf("inverse analysis = %+v, %v", inverse, err)
+	}
+}
+
+func TestDecayNotEnoughData(t *testing.T) {
+	tests := []struct {
+		name             string
+		fund, underlying []types.Bar
+	}{
+		{"no bars", nil, nil},
+		{"single shared day", []types.Bar{{Date: "2025-07-01", Close: 1}, {Date: "2025-07-02", Close: 1}}, []types.Bar{{Date: "2025-07-02", Close: 1}}},
+		{"prices missing", []types.Bar{{Date: "2025-07-01", Close: 0}, {Date: "2025-07-02", Close: 1}}, []types.Bar{{Date: "2025-07-01", Close: 1}, {Date: "2025-07-02", Close: 1}}},
+	}
+	for _, tt := range tests {
+		if _, err := Decay(tt.fund, tt.underlying, 2); !errors.Is(err, ErrNotEnoughData) {
+			t.Errorf("%s: Decay() error = %v, want ErrNotEnoughData", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package stocksync
+
+import (
+	"testing"
+
+	"github.com/arcedo/financial-ai-backend/types"
+)
+
+func TestCheckCoverage(t *testing.T) {
+	bars := func(dates ...string) []types.NewStock {
+		var result []types.NewStock
+		for _, date := range dates {
+			result = append(result, types.NewStock{Symbol: "AAPL", Date: date})
+		}
+		return result
+	}
+	days := []string{"2025-07-01", "2025-07-02", "2025-07-03", "2025-07-07"}
+
+	tests := []struct {
+		name    string
+		bars    []types.NewStock
+		days    []string
+		wantErr bool
+	}{
+		{"whole period", bars("2025-07-01", "2025-07-03", "2025-07-07"), days, false},
+		{"nothing to cover", nil, nil, false},
+		{"no bars", nil, days, true},
+		{"history starts later", bars("2025-07-02", "2025-07-07"), days, true},
+		{"history ends earlier", bars("2025-07-01", "2025-07-03"), days, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCoverage("AAPL", tt.bars, tt.days)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkCoverage() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package stocksync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/arcedo/financial-ai-backend/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrBudgetExhausted is returned once the provider's calls for the day are used up
+var ErrBudgetExhausted = errors.New("daily market data call budget exhausted")
+
+// Budget counts the calls made to a provider per UTC day in Mongo, so every instance and
+// every run of the day share the same allowance
+type Budget struct {
+	coll     *mongo.Collection
+	provider string
+	limit    int
+}
+
+// NewBudget allows limit calls per day to provider, or any number when limit is 0
+func NewBudget(store db.MongoStorage, provider string, limit int) *Budget {
+	return &Budget{coll: store.Collection("provider_usage"), provider: provider, limit: limit}
+}
+
+// Reserve takes one call out of today's budget
+func (b *Budget) Reserve(ctx context.Context) error {
+	now := time.Now().UTC()
+	day := now.Format("2006-01-02")
+	filter := bson.M{"_id": b.provider + ":" + day}
+	if b.limit > 0 {
+		filter["calls"] = bson.M{"$lt": b.limit}
+	}
+
+	_, err := b.coll.UpdateOne(ctx, filter, bson.M{
+		"$inc":         bson.M{"calls": 1},
+		"$setOnInsert": bson.M{"provider": b.provider, "day": day, "expires_at": now.AddDate(0, 0, 7)},
+	}, options.Update().SetUpsert(true))
+	// With the budget used up the filter matches nothing and the upsert collides with today's entry
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrBudgetExhausted
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reserve market data call: %w", err)
+	}
+	return nil
+}
+
+// Used returns the calls made today
+func (b *Budget) Used(ctx context.Context) (int, error) {
+	var usage struct {
+		Calls int `bson:"calls"`
+	}
+	day := time.Now().UTC().Format("2006-01-02")
+	err := b.coll.FindOne(ctx, bson.M{"_id": b.provider + ":" + day}).Decode(&usage)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, fmt.Errorf("failed to read market data usage: %w", err)
+	}
+	return usage.Calls, nil
+}
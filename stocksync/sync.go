@@ -0,0 +1,456 @@
+// Package stocksync keeps the stored daily prices up to date from the market data provider,
+// within the provider's daily call budget and starting with the symbols users care about
+package stocksync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/corporate"
+	db "github.com/arcedo/financial-ai-backend/database"
+	"github.com/arcedo/financial-ai-backend/marketdata"
+	"github.com/arcedo/financial-ai-backend/metrics"
+	"github.com/arcedo/financial-ai-backend/types"
+	"github.com/arcedo/financial-ai-backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// leaseDuration is how long a run owns the sync without renewing its lease, it must be
+	// longer than the slowest provider call
+	leaseDuration = 2 * time.Minute
+)
+
+// ErrAlreadyRunning is returned when another instance holds the sync lease
+var ErrAlreadyRunning = errors.New("stock sync already running on another instance")
+
+type Syncer struct {
+	store    db.MongoStorage
+	provider marketdata.Provider
+	budget   *Budget
+	owner    string
+}
+
+func New(store db.MongoStorage, provider marketdata.Provider, dailyBudget int) *Syncer {
+	host, _ := os.Hostname()
+	return &Syncer{
+		store:    store,
+		provider: provider,
+		budget:   NewBudget(store, provider.Name(), dailyBudget),
+		owner:    fmt.Sprintf("%s-%d", host, os.Getpid()),
+	}
+}
+
+// Run resumes the unfinished run if there is one, otherwise starts a new run over the symbols
+// needing new bars. A resumed run started before newer bars became available is followed by a
+// new run, since the symbols it had already done miss them. Runs stop early when the budget is
+// used up, leaving the run to be resumed.
+func (s *Syncer) Run(ctx context.Context) error {
+	logger := utils.LoggerFrom(ctx).With("provider", s.provider.Name())
+
+	if err := s.acquireLease(ctx); err != nil {
+		return err
+	}
+	defer s.releaseLease()
+
+	run, err := s.unfinishedRun(ctx)
+	if err != nil {
+		return err
+	}
+	if run != nil {
+		if err := s.resumeRun(ctx, run); err != nil {
+			return err
+		}
+		logger.Info("stock sync resumed", "run_id", run.ID.Hex(), "remaining", len(run.Remaining()))
+
+		status, err := s.complete(ctx, run)
+		if err != nil || status != types.SyncRunCompleted {
+			return err
+		}
+		if marketdata.ExpectedLatestDate(run.StartedAt) >= marketdata.ExpectedLatestDate(time.Now()) {
+			return nil
+		}
+	}
+
+	if run, err = s.startRun(ctx); err != nil {
+		metrics.StockSyncRuns.WithLabelValues("error").Inc()
+		return err
+	}
+	logger.Info("stock sync started", "run_id", run.ID.Hex(), "symbols", len(run.Queue))
+	_, err = s.complete(ctx, run)
+	return err
+}
+
+// complete processes the remaining symbols of run and records how it ended
+func (s *Syncer) complete(ctx context.Context, run *types.SyncRun) (string, error) {
+	logger := utils.LoggerFrom(ctx).With("provider", s.provider.Name(), "run_id", run.ID.Hex())
+
+	status, err := s.process(ctx, run)
+	if finishErr := s.finishRun(run.ID, status); finishErr != nil {
+		logger.Error("error recording stock sync run", "error", finishErr)
+	}
+	metrics.StockSyncRuns.WithLabelValues(status).Inc()
+
+	// Shared with the other runs and instances of the day, so it tells how much is left
+	used, usedErr := s.budget.Used(context.WithoutCancel(ctx))
+	if usedErr != nil {
+		logger.Error("error reading market data usage", "error", usedErr)
+	}
+	logger.Info("stock sync finished", "status", status, "calls_today", used, "daily_budget", s.budget.limit)
+	return status, err
+}
+
+// ResumeUnfinished runs the sync only when a previous run was interrupted, e.g. on startup
+func (s *Syncer) ResumeUnfinished(ctx context.Context) error {
+	run, err := s.unfinishedRun(ctx)
+	if err != nil || run == nil {
+		return err
+	}
+	return s.Run(ctx)
+}
+
+// process syncs the remaining symbols of run and returns the status the run ends with
+func (s *Syncer) process(ctx context.Context, run *types.SyncRun) (string, error) {
+	logger := utils.LoggerFrom(ctx).With("provider", s.provider.Name(), "run_id", run.ID.Hex())
+	runs := s.store.Collection("sync_runs")
+	latest, err := s.latestDates(ctx)
+	if err != nil {
+		return types.SyncRunFailed, err
+	}
+
+	symbolsUpdated, failures := 0, 0
+	for _, symbol := range run.Remaining() {
+		if err := s.renewLease(ctx); err != nil {
+			return types.SyncRunFailed, err
+		}
+
+		err := s.budget.Reserve(ctx)
+		if errors.Is(err, ErrBudgetExhausted) {
+			logger.Warn("market data budget exhausted, the run resumes with the next one", "remaining", len(run.Remaining()))
+			return types.SyncRunBudgetExhausted, nil
+		}
+		if err != nil {
+			return types.SyncRunFailed, err
+		}
+
+		bars, err := s.provider.DailyBars(ctx, symbol, latest[symbol])
+		if ctx.Err() != nil {
+			return types.SyncRunCancelled, ctx.Err()
+		}
+		// Refused calls are not the symbol's fault, it is retried when the run resumes
+		if errors.Is(err, marketdata.ErrRateLimited) {
+			logger.Warn("market data provider rate limited, the run resumes with the next one", "symbol", symbol, "error", err)
+			return types.SyncRunBudgetExhausted, nil
+		}
+
+		var update bson.M
+		if err != nil {
+			logger.Error("error fetching stock data", "symbol", symbol, "error", err)
+			failures++
+			failure := types.SyncFailure{Symbol: symbol, Error: err.Error()}
+			update = bson.M{"$push": bson.M{"failed": failure}, "$inc": bson.M{"calls": 1}}
+			run.Failed = append(run.Failed, failure)
+		} else {
+			inserted := s.insertBars(ctx, symbol, bars)
+			if inserted > 0 {
+				symbolsUpdated++
+			}
+			update = bson.M{"$push": bson.M{"done": symbol}, "$inc": bson.M{"calls": 1, "bars_inserted": inserted}}
+			run.Done = append(run.Done, symbol)
+		}
+
+		// Saved after every symbol so a crash or a restart loses at most the current one
+		if _, err := runs.UpdateByID(ctx, run.ID, update); err != nil {
+			return types.SyncRunFailed, fmt.Errorf("failed to save stock sync progress: %w", err)
+		}
+	}
+
+	// Failed symbols are listed in the run and retried by the next one
+	metrics.StockSyncSymbolsUpdated.Set(float64(symbolsUpdated))
+	if failures == 0 {
+		metrics.StockSyncLastSuccess.SetToCurrentTime()
+	}
+	return types.SyncRunCompleted, nil
+}
+
+// insertBars upserts the bars on (symbol, date), so overlapping fetches never duplicate a bar,
+// and returns how many were new
+func (s *Syncer) insertBars(ctx context.Context, symbol string, bars []types.NewStock) int {
+	inserted, err := UpsertBars(ctx, s.store, bars)
+	if err != nil {
+		utils.LoggerFrom(ctx).Error("failed to store stock data", "symbol", symbol, "error", err)
+	}
+	return inserted
+}
+
+// UpsertBars writes the bars in a single bulk write keyed on (symbol, date), replacing the
+// stored values of existing bars, and returns how many bars were new
+func UpsertBars(ctx context.Context, store db.MongoStorage, bars []types.NewStock) (int, error) {
+	if len(bars) == 0 {
+		return 0, nil
+	}
+
+	models := make([]mongo.WriteModel, 0, len(bars))
+	for _, bar := range bars {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"symbol": bar.Symbol, "date": bar.Date}).
+			SetUpdate(bson.M{"$set": bar}).
+			SetUpsert(true))
+	}
+
+	// Unordered so one bad bar does not stop the others from being written
+	res, err := store.Collection("stocks").BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if res == nil {
+		return 0, fmt.Errorf("failed to write stock bars: %w", err)
+	}
+	if err != nil {
+		return int(res.UpsertedCount), fmt.Errorf("failed to write some stock bars: %w", err)
+	}
+	return int(res.UpsertedCount), nil
+}
+
+// startRun records a new run queueing the symbols that are missing bars, by priority
+func (s *Syncer) startRun(ctx context.Context) (*types.SyncRun, error) {
+	queue, err := s.prioritizedSymbols(ctx, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	run := &types.SyncRun{
+		Provider:  s.provider.Name(),
+		Status:    types.SyncRunRunning,
+		Queue:     queue,
+		Done:      []string{},
+		Failed:    []types.SyncFailure{},
+		StartedAt: time.Now().UTC(),
+	}
+	res, err := s.store.Collection("sync_runs").InsertOne(ctx, run)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record stock sync run: %w", err)
+	}
+	run.ID = res.InsertedID.(primitive.ObjectID)
+	return run, nil
+}
+
+func (s *Syncer) unfinishedRun(ctx context.Context) (*types.SyncRun, error) {
+	var run types.SyncRun
+	err := s.store.Collection("sync_runs").FindOne(ctx,
+		bson.M{
+			"provider": s.provider.Name(),
+			"status":   bson.M{"$in": bson.A{types.SyncRunRunning, types.SyncRunCancelled, types.SyncRunBudgetExhausted}},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "started_at", Value: -1}}),
+	).Decode(&run)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unfinished stock sync run: %w", err)
+	}
+	return &run, nil
+}
+
+func (s *Syncer) resumeRun(ctx context.Context, run *types.SyncRun) error {
+	now := time.Now().UTC()
+	_, err := s.store.Collection("sync_runs").UpdateByID(ctx, run.ID, bson.M{"$set": bson.M{
+		"status":     types.SyncRunRunning,
+		"resumed_at": now,
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to resume stock sync run: %w", err)
+	}
+	run.Status, run.ResumedAt = types.SyncRunRunning, &now
+	return nil
+}
+
+// finishRun records how the run ended. It runs on a fresh context so a cancelled run is still
+// marked as such and gets resumed.
+func (s *Syncer) finishRun(id primitive.ObjectID, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"status": status}
+	if status == types.SyncRunCompleted || status == types.SyncRunFailed {
+		set["finished_at"] = time.Now().UTC()
+	}
+	_, err := s.store.Collection("sync_runs").UpdateByID(ctx, id, bson.M{"$set": set})
+	return err
+}
+
+// prioritizedSymbols lists the products missing bars: the ones most users hold first, then the
+// ones most users traded, then the stalest ones
+func (s *Syncer) prioritizedSymbols(ctx context.Context, now time.Time) ([]string, error) {
+	cursor, err := s.store.Collection("products").Find(ctx, bson.M{"archived": bson.M{"$ne": true}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	var products []types.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, fmt.Errorf("failed to decode products: %w", err)
+	}
+
+	interest, err := corporate.SymbolInterest(ctx, s.store)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := s.latestDates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := marketdata.ExpectedLatestDate(now)
+	var symbols []string
+	for _, product := range products {
+		if latest[product.Symbol] < expected {
+			symbols = append(symbols, product.Symbol)
+		}
+	}
+
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := interest[symbols[i]], interest[symbols[j]]
+		if a.Holders != b.Holders {
+			return a.Holders > b.Holders
+		}
+		if a.Traders != b.Traders {
+			return a.Traders > b.Traders
+		}
+		if latest[symbols[i]] != latest[symbols[j]] {
+			return latest[symbols[i]] < latest[symbols[j]]
+		}
+		return symbols[i] < symbols[j]
+	})
+	return symbols, nil
+}
+
+// latestDates returns the date of the latest stored bar of every symbol
+func (s *Syncer) latestDates(ctx context.Context) (map[string]string, error) {
+	cursor, err := s.store.Collection("stocks").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$symbol", "date": bson.M{"$max": "$date"}}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate latest stock dates: %w", err)
+	}
+	var rows []struct {
+		Symbol string `bson:"_id"`
+		Date   string `bson:"date"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode latest stock dates: %w", err)
+	}
+
+	latest := make(map[string]string, len(rows))
+	for _, row := range rows {
+		latest[row.Symbol] = row.Date
+	}
+	return latest, nil
+}
+
+// acquireLease makes sure a single instance syncs at a time
+func (s *Syncer) acquireLease(ctx context.Context) error {
+	now := time.Now().UTC()
+	_, err := s.store.Collection("sync_locks").UpdateOne(ctx,
+		bson.M{"_id": "stock-sync", "$or": bson.A{
+			bson.M{"locked_until": bson.M{"$lt": now}},
+			bson.M{"owner": s.owner},
+		}},
+		bson.M{"$set": bson.M{"owner": s.owner, "locked_until": now.Add(leaseDuration)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyRunning
+	}
+	if err != nil {
+		return fmt.Errorf("failed to acquire stock sync lease: %w", err)
+	}
+	return nil
+}
+
+func (s *Syncer) renewLease(ctx context.Context) error {
+	res, err := s.store.Collection("sync_locks").UpdateOne(ctx,
+		bson.M{"_id": "stock-sync", "owner": s.owner},
+		bson.M{"$set": bson.M{"locked_until": time.Now().UTC().Add(leaseDuration)}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to renew stock sync lease: %w", err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrAlreadyRunning
+	}
+	return nil
+}
+
+func (s *Syncer) releaseLease() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.store.Collection("sync_locks").DeleteOne(ctx, bson.M{"_id": "stock-sync", "owner": s.owner})
+}
+
+// BackfillResult counts the bars a backfill got from the provider and how many were missing
+type BackfillResult struct {
+	Fetched  int
+	Inserted int
+}
+
+// Backfill fetches the bars of symbol from from to to (YYYY-MM-DD, to "" for up to the latest)
+// and stores them over the ones stored, taking one call from the budget. The bars fetched are
+// stored even when they do not reach both ends of the period, which is reported as an error
+// since the provider has no older or newer history to fill it with.
+func Backfill(ctx context.Context, store db.MongoStorage, provider marketdata.Provider, budget *Budget, symbol, from, to string) (BackfillResult, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return BackfillResult{}, fmt.Errorf("invalid date %q: %w", from, err)
+	}
+	last := marketdata.ExpectedLatestDate(time.Now())
+	if to != "" && to < last {
+		last = to
+	}
+	end, err := time.Parse("2006-01-02", last)
+	if err != nil {
+		return BackfillResult{}, fmt.Errorf("invalid date %q: %w", to, err)
+	}
+	if err := budget.Reserve(ctx); err != nil {
+		return BackfillResult{}, err
+	}
+
+	bars, err := provider.DailyBars(ctx, symbol, start.AddDate(0, 0, -1).Format("2006-01-02"))
+	if err != nil {
+		return BackfillResult{}, fmt.Errorf("failed to fetch the bars of %s: %w", symbol, err)
+	}
+	kept := bars[:0]
+	for _, bar := range bars {
+		if bar.Date <= last {
+			kept = append(kept, bar)
+		}
+	}
+	bars = kept
+
+	inserted, err := UpsertBars(ctx, store, bars)
+	result := BackfillResult{Fetched: len(bars), Inserted: inserted}
+	if err != nil {
+		return result, err
+	}
+
+	return result, checkCoverage(symbol, bars, marketdata.TradingDays(start, end))
+}
+
+// checkCoverage reports bars, oldest first, that do not reach the first or the last of days
+func checkCoverage(symbol string, bars []types.NewStock, days []string) error {
+	if len(days) == 0 {
+		return nil
+	}
+	if len(bars) == 0 {
+		return fmt.Errorf("the provider has no bars of %s from %s to %s", symbol, days[0], days[len(days)-1])
+	}
+	if first, latest := bars[0].Date, bars[len(bars)-1].Date; first > days[0] || latest < days[len(days)-1] {
+		return fmt.Errorf("the provider only has bars of %s from %s to %s, %s to %s is not covered",
+			symbol, first, latest, days[0], days[len(days)-1])
+	}
+	return nil
+}
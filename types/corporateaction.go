@@ -0,0 +1,34 @@
+package types
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+const (
+	ActionSplit    = "split"
+	ActionDividend = "dividend"
+)
+
+// CorporateAction is a split or a cash dividend of a symbol. Ratio is the number of shares
+// after a split per share before it (10 for a 10-for-1 split, 0.1 for a 1-for-10 reverse
+// split), Amount the cash paid per share by a dividend.
+type CorporateAction struct {
+	ID      primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Symbol  string             `json:"symbol" bson:"symbol"`
+	Type    string             `json:"type" bson:"type"`
+	ExDate  string             `json:"ex_date" bson:"ex_date"`
+	PayDate string             `json:"pay_date,omitempty" bson:"pay_date,omitempty"`
+	Ratio   float64            `json:"ratio,omitempty" bson:"ratio,omitempty"`
+	Amount  float64            `json:"amount,omitempty" bson:"amount,omitempty"`
+}
+
+// Holding is a position of a user in today's shares, after the splits since it was bought.
+// OpenedAt is the date of the buy that opened the current position, HeldDays the days since.
+type Holding struct {
+	Symbol      string       `json:"symbol"`
+	Shares      float64      `json:"shares"`
+	CostBasis   float64      `json:"cost_basis"`
+	AverageCost float64      `json:"average_cost"`
+	Dividends   float64      `json:"dividends"`
+	OpenedAt    string       `json:"opened_at,omitempty"`
+	HeldDays    int          `json:"held_days"`
+	Risk        *RiskWarning `json:"risk,omitempty"`
+}
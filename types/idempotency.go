@@ -0,0 +1,17 @@
+package types
+
+import "time"
+
+// IdempotencyRecord remembers the response to a request sent with an Idempotency-Key, so
+// retries of the same request get it replayed instead of running twice
+type IdempotencyRecord struct {
+	ID          string              `bson:"_id"`
+	RequestHash string              `bson:"request_hash"`
+	Completed   bool                `bson:"completed"`
+	LockedUntil time.Time           `bson:"locked_until"`
+	Status      int                 `bson:"status,omitempty"`
+	Header      map[string][]string `bson:"header,omitempty"`
+	Body        []byte              `bson:"body,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at"`
+	ExpiresAt   time.Time           `bson:"expires_at"`
+}
@@ -5,8 +5,10 @@ type PositionSummary struct {
 	TotalSells float64 `json:"total_sells"`
 	TotalSaves float64 `json:"total_saves"`
 	TotalEntry float64 `json:"total_entry"`
-	NetMarket  float64 `json:"net_market"`  // TotalBuys - TotalSells
-	NetBalance float64 `json:"net_balance"` // NetMarket + TotalSaves + TotalEntry
+	// TotalDividends is the dividend income received
+	TotalDividends float64 `json:"total_dividends"`
+	NetMarket      float64 `json:"net_market"`  // TotalBuys - TotalSells
+	NetBalance     float64 `json:"net_balance"` // NetMarket + TotalSaves + TotalEntry + TotalDividends
 }
 
 type Insights struct {
@@ -1,6 +1,104 @@
 package types
 
+const (
+	AssetStock     = "stock"
+	AssetETF       = "etf"
+	AssetBond      = "bond"
+	AssetCommodity = "commodity"
+	AssetCrypto    = "crypto"
+)
+
+// Product is an asset users can trade. Leverage is the daily multiple of the underlying
+// exposure a fund targets (1 for plain assets) and Inverse marks funds betting against it.
+// Underlying is the product the fund tracks, when it is in the catalog. Archived products are
+// kept for the history of their transactions but no longer offered.
 type Product struct {
-	Symbol string `json:"symbol"`
-	Name   string `json:"name"`
+	Symbol     string  `json:"symbol" bson:"symbol"`
+	Name       string  `json:"name" bson:"name"`
+	AssetClass string  `json:"asset_class" bson:"asset_class"`
+	Sector     string  `json:"sector,omitempty" bson:"sector,omitempty"`
+	Exchange   string  `json:"exchange,omitempty" bson:"exchange,omitempty"`
+	Currency   string  `json:"currency" bson:"currency"`
+	Leverage   float64 `json:"leverage" bson:"leverage"`
+	Inverse    bool    `json:"inverse" bson:"inverse"`
+	Underlying string  `json:"underlying,omitempty" bson:"underlying,omitempty"`
+	Archived   bool    `json:"archived,omitempty" bson:"archived,omitempty"`
+}
+
+type NewProduct struct {
+	Symbol     string   `json:"symbol" validate:"required,max=10"`
+	Name       string   `json:"name" validate:"required,max=100"`
+	AssetClass string   `json:"asset_class" validate:"required,enum=stock|etf|bond|commodity|crypto"`
+	Sector     string   `json:"sector" validate:"max=50"`
+	Exchange   string   `json:"exchange" validate:"max=20"`
+	Currency   string   `json:"currency" validate:"required,min=3,max=3"`
+	Leverage   *float64 `json:"leverage" validate:"positive,max=5"`
+	Inverse    *bool    `json:"inverse"`
+	Underlying string   `json:"underlying" validate:"symbol"`
+}
+
+// UpdateProduct changes the given fields of a product, Archived false restores it
+type UpdateProduct struct {
+	Name       *string  `json:"name" validate:"nonblank,max=100"`
+	AssetClass *string  `json:"asset_class" validate:"nonblank,enum=stock|etf|bond|commodity|crypto"`
+	Sector     *string  `json:"sector" validate:"max=50"`
+	Exchange   *string  `json:"exchange" validate:"max=20"`
+	Currency   *string  `json:"currency" validate:"nonblank,min=3,max=3"`
+	Leverage   *float64 `json:"leverage" validate:"positive,max=5"`
+	Inverse    *bool    `json:"inverse"`
+	Underlying *string  `json:"underlying" validate:"symbol"`
+	Archived   *bool    `json:"archived"`
+}
+
+type ProductSearchQuery struct {
+	Q          string `json:"q" validate:"max=50"`
+	AssetClass string `json:"asset_class" validate:"enum=stock|etf|bond|commodity|crypto"`
+	// Leveraged keeps the products with a leverage above 1, or false the others
+	Leveraged *bool `json:"leveraged"`
+	Inverse   *bool `json:"inverse"`
+	Limit     int   `json:"limit" validate:"min=1,max=50"`
+}
+
+// ProductSearchResult is a product matching a search. Match tells how it matched the query:
+// "symbol" (exact), "prefix", "name", "contains" or "fuzzy", best first. Holders is how many
+// users hold it, the popularity ranking matches of the same kind.
+type ProductSearchResult struct {
+	Product Product `json:"product"`
+	Match   string  `json:"match,omitempty"`
+	Holders int     `json:"holders"`
+}
+
+// DecayAnalysis compares the return of a leveraged or inverse fund over a period with the
+// multiple of its underlying's return it targets each day. DailyTargetReturn compounds the
+// daily target, so TargetReturn - DailyTargetReturn is the decay caused by the underlying's
+// volatility, and Decay the total shortfall including fees and tracking error. Returns are
+// fractions, 0.1 for 10%.
+type DecayAnalysis struct {
+	Symbol               string  `json:"symbol"`
+	Underlying           string  `json:"underlying"`
+	Leverage             float64 `json:"leverage"`
+	Inverse              bool    `json:"inverse"`
+	From                 string  `json:"from"`
+	To                   string  `json:"to"`
+	Days                 int     `json:"days"`
+	FundReturn           float64 `json:"fund_return"`
+	UnderlyingReturn     float64 `json:"underlying_return"`
+	TargetReturn         float64 `json:"target_return"`
+	DailyTargetReturn    float64 `json:"daily_target_return"`
+	VolatilityDecay      float64 `json:"volatility_decay"`
+	Decay                float64 `json:"decay"`
+	UnderlyingVolatility float64 `json:"underlying_volatility"`
+}
+
+type DecayQuery struct {
+	From string `json:"from" validate:"date"`
+	To   string `json:"to" validate:"date"`
+}
+
+// RiskWarning is a disclosure attached to trades and holdings of risky products
+type RiskWarning struct {
+	Code     string         `json:"code"`
+	Severity string         `json:"severity"`
+	Message  string         `json:"message"`
+	Decay    *DecayAnalysis `json:"decay,omitempty"`
 }
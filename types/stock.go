@@ -22,3 +22,128 @@ type NewStock struct {
 	LowPrice   float32 `json:"low"`
 	Volume     float32 `json:"volume"`
 }
+
+// Bar returns the stored daily prices as a Bar
+func (s Stock) Bar() Bar {
+	return Bar{
+		Date:   s.Date,
+		Open:   s.OpenPrice,
+		High:   s.HighPrice,
+		Low:    s.LowPrice,
+		Close:  s.ClosePrice,
+		Volume: s.Volume,
+	}
+}
+
+// Bar is an OHLCV bar over a day, or over a longer period when resampled. Date is the first
+// trading day of the period.
+type Bar struct {
+	Date   string  `json:"date"`
+	Open   float32 `json:"open"`
+	High   float32 `json:"high"`
+	Low    float32 `json:"low"`
+	Close  float32 `json:"close"`
+	Volume float32 `json:"volume"`
+}
+
+type StockHistoryQuery struct {
+	From     string `json:"from" validate:"date"`
+	To       string `json:"to" validate:"date"`
+	Interval string `json:"interval" validate:"enum=daily|weekly|monthly|quarterly"`
+	Page     int    `json:"page" validate:"min=1,max=100000"`
+	PageSize int    `json:"page_size" validate:"min=1,max=500"`
+	// Adjusted scales the prices before splits and dividends to be comparable with today's
+	Adjusted bool `json:"adjusted"`
+}
+
+// StockHistory is a page of the bars of a symbol, oldest first
+type StockHistory struct {
+	Symbol   string `json:"symbol"`
+	Interval string `json:"interval"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"page_size"`
+	Total    int    `json:"total"`
+	Bars     []Bar  `json:"bars"`
+}
+
+// Quote is the latest bar of a symbol with its change over the previous close
+type Quote struct {
+	Symbol        string  `json:"symbol"`
+	Date          string  `json:"date"`
+	Open          float32 `json:"open"`
+	High          float32 `json:"high"`
+	Low           float32 `json:"low"`
+	Close         float32 `json:"close"`
+	Volume        float32 `json:"volume"`
+	PreviousClose float32 `json:"previous_close"`
+	Change        float32 `json:"change"`
+	ChangePercent float32 `json:"change_percent"`
+}
+
+type IndicatorsQuery struct {
+	Names []string `json:"names" validate:"required"`
+	From  string   `json:"from" validate:"date"`
+	To    string   `json:"to" validate:"date"`
+}
+
+type IndicatorValue struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+// Indicators maps series names such as "sma_50" or "macd_signal" to their values, oldest
+// first
+type Indicators struct {
+	Symbol string                      `json:"symbol"`
+	Series map[string][]IndicatorValue `json:"series"`
+}
+
+// IndicatorSnapshot holds the latest value of a set of indicators
+type IndicatorSnapshot struct {
+	Symbol string             `json:"symbol"`
+	Date   string             `json:"date"`
+	Close  float32            `json:"close"`
+	Values map[string]float64 `json:"values"`
+}
+
+type QualityQuery struct {
+	From string `json:"from" validate:"date"`
+	To   string `json:"to" validate:"date"`
+}
+
+// DataGap is a run of consecutive trading days without a stored bar
+type DataGap struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Days int    `json:"days"`
+}
+
+// DataOutlier is a stored bar that is unlikely to be right, Reason is one of zero_volume,
+// non_positive_price, high_below_low, open_outside_range, close_outside_range, price_jump or
+// non_trading_day
+type DataOutlier struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+// QualityReport lists the problems of the stored bars of a symbol over a period. Stale
+// symbols lag more than a few trading days behind what the provider can have.
+type QualityReport struct {
+	Symbol       string        `json:"symbol"`
+	From         string        `json:"from"`
+	To           string        `json:"to"`
+	Bars         int           `json:"bars"`
+	ExpectedBars int           `json:"expected_bars"`
+	MissingDays  int           `json:"missing_days"`
+	LatestDate   string        `json:"latest_date"`
+	Stale        bool          `json:"stale"`
+	StaleDays    int           `json:"stale_days"`
+	Gaps         []DataGap     `json:"gaps"`
+	Outliers     []DataOutlier `json:"outliers"`
+}
+
+// OK reports a period without gaps, outliers or staleness
+func (q QualityReport) OK() bool {
+	return len(q.Gaps) == 0 && len(q.Outliers) == 0 && !q.Stale
+}
@@ -0,0 +1,55 @@
+package types
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	SyncRunRunning         = "running"
+	SyncRunCompleted       = "completed"
+	SyncRunBudgetExhausted = "budget_exhausted"
+	SyncRunCancelled       = "cancelled"
+	SyncRunFailed          = "failed"
+)
+
+// SyncRun is one run of the stock sync. Unfinished runs keep their queue so the next run
+// resumes them, and the finished ones are the sync history.
+type SyncRun struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Provider     string             `json:"provider" bson:"provider"`
+	Status       string             `json:"status" bson:"status"`
+	Queue        []string           `json:"queue" bson:"queue"`
+	Done         []string           `json:"done" bson:"done"`
+	Failed       []SyncFailure      `json:"failed" bson:"failed"`
+	Calls        int                `json:"calls" bson:"calls"`
+	BarsInserted int                `json:"bars_inserted" bson:"bars_inserted"`
+	StartedAt    time.Time          `json:"started_at" bson:"started_at"`
+	ResumedAt    *time.Time         `json:"resumed_at,omitempty" bson:"resumed_at,omitempty"`
+	FinishedAt   *time.Time         `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+type SyncFailure struct {
+	Symbol string `json:"symbol" bson:"symbol"`
+	Error  string `json:"error" bson:"error"`
+}
+
+// Remaining returns the queued symbols not processed yet, in queue order
+func (run SyncRun) Remaining() []string {
+	processed := make(map[string]bool, len(run.Done)+len(run.Failed))
+	for _, symbol := range run.Done {
+		processed[symbol] = true
+	}
+	for _, failure := range run.Failed {
+		processed[failure.Symbol] = true
+	}
+
+	var remaining []string
+	for _, symbol := range run.Queue {
+		if !processed[symbol] {
+			remaining = append(remaining, symbol)
+		}
+	}
+	return remaining
+}
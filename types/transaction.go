@@ -1,51 +1,46 @@
 package types
 
 import (
-	"fmt"
-
-	"github.com/arcedo/financial-ai-backend/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Amount is the cash moved by a transaction. Quantity is the number of shares bought or sold,
+// at the time of the transaction, before any later split.
 type Transaction struct {
-	ID     int                `json:"_id"`
-	UserID primitive.ObjectID `json:"user_id" bson:"user_id"`
-	Type   string             `json:"type"`
-	Amount float64            `json:"amount`
-	Date   string             `json:"date"`
-	Symbol string             `json:"symbol", bson:"symbol"`
+	ID       int                `json:"_id"`
+	UserID   primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Type     string             `json:"type"`
+	Amount   float64            `json:"amount"`
+	Quantity float64            `json:"quantity,omitempty" bson:"quantity,omitempty"`
+	Date     string             `json:"date"`
+	Symbol   string             `json:"symbol" bson:"symbol"`
 }
 
 type TransactionPublic struct {
-	Type   string  `json:"type"`
-	Amount float64 `json:"amount"`
-	Date   string  `json:"date"`
-	Symbol string  `json:"symbol", bson:"symbol"`
+	Type     string   `json:"type" validate:"required,enum=buy|sell|entry|save"`
+	Amount   float64  `json:"amount" validate:"positive"`
+	Quantity *float64 `json:"quantity,omitempty" bson:"quantity,omitempty" validate:"positive"`
+	Date     string   `json:"date" validate:"required,date"`
+	Symbol   string   `json:"symbol" bson:"symbol" validate:"required_if=type:buy|sell,symbol"`
 }
 
 type NewTransaction struct {
-	Type   string             `json:"type"`
-	Amount float64            `json:"amount"`
-	Date   string             `json:"date"`
-	Symbol string             `json:"symbol", bson:"symbol"`
-	UserID primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Type     string             `json:"type"`
+	Amount   float64            `json:"amount"`
+	Quantity float64            `json:"quantity,omitempty" bson:"quantity,omitempty"`
+	Date     string             `json:"date"`
+	Symbol   string             `json:"symbol" bson:"symbol"`
+	UserID   primitive.ObjectID `json:"user_id" bson:"user_id"`
+	// ActionID is the dividend a transaction was credited for
+	ActionID primitive.ObjectID `json:"-" bson:"action_id,omitempty"`
 }
 
-func ValidateTransaction(transaction TransactionPublic) error {
-	if err := utils.ValidateStringField(transaction.Type, "type"); err != nil {
-		return err
-	}
-
-	if transaction.Type != "buy" && transaction.Type != "sell" && transaction.Type != "entry" && transaction.Type != "save" {
-		return fmt.Errorf("invalid transaction type: %s, must be buy, sell, entry or save", transaction.Type)
-	}
-
-	if err := utils.ValidateStringField(transaction.Date, "date"); err != nil {
-		return err
-	}
-
-	if err := utils.ValidateStringField(transaction.Symbol, "symbol"); err != nil && transaction.Type != "entry" && transaction.Type != "save" {
-		return err
-	}
-	return nil
+// TransactionReceipt is a recorded transaction with the risk disclosures of its product
+type TransactionReceipt struct {
+	Type     string        `json:"type"`
+	Amount   float64       `json:"amount"`
+	Quantity *float64      `json:"quantity,omitempty"`
+	Date     string        `json:"date"`
+	Symbol   string        `json:"symbol"`
+	Warnings []RiskWarning `json:"warnings,omitempty"`
 }
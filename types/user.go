@@ -1,7 +1,8 @@
 package types
 
 import (
-	"github.com/arcedo/financial-ai-backend/utils"
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -13,6 +14,19 @@ type User struct {
 	Password       string             `json:"password,omitempty"`
 	RiskScore      int                `json:"risk_score"`
 	FinancialScore int                `json:"financial_score"`
+	EmailChange    *EmailChange       `json:"-" bson:"email_change,omitempty"`
+	// Role is RoleAdmin for administrators, empty for everyone else
+	Role string `json:"role,omitempty" bson:"role,omitempty"`
+}
+
+// RoleAdmin lets a user manage the products
+const RoleAdmin = "admin"
+
+// EmailChange is a requested email address waiting to be verified by its owner
+type EmailChange struct {
+	Email     string    `bson:"email"`
+	TokenHash string    `bson:"token_hash"`
+	ExpiresAt time.Time `bson:"expires_at"`
 }
 
 type UserProfile struct {
@@ -28,41 +42,39 @@ type PublicUser struct {
 	FinancialScore int    `json:"financial_score"`
 }
 
-type NewUser struct {
-	Name     string `json:"name"`
-	LastName string `json:"last_name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+type Credentials struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
-func (u *NewUser) ValidateUserCreation() error {
-	if err := utils.ValidateStringField(u.Name, "Name"); err != nil {
-		return err
-	}
-
-	if err := utils.ValidateStringField(u.Name, "Last name"); err != nil {
-		return err
-	}
-
-	if err := utils.ValidateEmail(u.Email); err != nil {
-		return err
-	}
+// AuthResponse is returned when a session is opened, by logging in or registering
+type AuthResponse struct {
+	Token string     `json:"token"`
+	User  PublicUser `json:"user"`
+}
 
-	if err := utils.ValidatePassword(u.Password); err != nil {
-		return err
-	}
+type NewUser struct {
+	Name     string `json:"name" validate:"required,max=50"`
+	LastName string `json:"last_name" validate:"required,max=50"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
 
-	return nil
+type UpdateUser struct {
+	Name     *string `json:"name" validate:"nonblank,max=50"`
+	LastName *string `json:"last_name" validate:"nonblank,max=50"`
 }
 
-func (u *User) ValidateUserLogin() error {
-	if err := utils.ValidateStringField(u.Email, "email"); err != nil {
-		return err
-	}
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=72"`
+}
 
-	if err := utils.ValidateStringField(u.Password, "password"); err != nil {
-		return err
-	}
+type ChangeEmail struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
 
-	return nil
+type VerifyEmail struct {
+	Token string `json:"token" validate:"required"`
 }
@@ -1,13 +1,22 @@
 package utils
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type APIError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Details []FieldError `json:"details,omitempty"`
 }
 
 var (
@@ -17,48 +26,225 @@ var (
 	ErrInvalidInput       = errors.New("INVALID_INPUT")
 	ErrInvalidMethod      = errors.New("METHOD_NOT_ALLOWED")
 	ErrInvalidCredentials = errors.New("INVALID_CREDENTIALS")
+	ErrConflict           = errors.New("CONFLICT")
+	ErrTooManyRequests    = errors.New("RATE_LIMITED")
+
+	ErrIdempotencyKeyReused = errors.New("IDEMPOTENCY_KEY_REUSED")
 
 	// Specific errors
-	ErrDatabase     = errors.New("DATABASE_ERROR")
-	ErrCache        = errors.New("CACHE_ERROR")
-	ErrUnauthorized = errors.New("UNAUTHORIZED")
-	ErrForbidden    = errors.New("FORBIDDEN")
+	ErrDatabase            = errors.New("DATABASE_ERROR")
+	ErrDatabaseUnavailable = errors.New("DATABASE_UNAVAILABLE")
+	ErrCache               = errors.New("CACHE_ERROR")
+	ErrUnauthorized        = errors.New("UNAUTHORIZED")
+	ErrForbidden           = errors.New("FORBIDDEN")
+	ErrUpstreamUnavailable = errors.New("UPSTREAM_UNAVAILABLE")
+	ErrUpstreamTimeout     = errors.New("UPSTREAM_TIMEOUT")
 )
 
 // Predefined APIError objects with messages
 var ErrorMap = map[error]APIError{
-	ErrInternalServer:     {Code: "INTERNAL_SERVER_ERROR", Message: "An unexpected error occurred"},
-	ErrNotFound:           {Code: "NOT_FOUND", Message: "The requested resource was not found"},
-	ErrInvalidInput:       {Code: "INVALID_INPUT", Message: "The input provided is invalid"},
-	ErrInvalidMethod:      {Code: "METHOD_NOT_ALLOWED", Message: "The HTTP method is not allowed for this endpoint"},
-	ErrDatabase:           {Code: "DATABASE_ERROR", Message: "A database error occurred"},
-	ErrCache:              {Code: "CACHE_ERROR", Message: "A cache error occurred"},
-	ErrUnauthorized:       {Code: "UNAUTHORIZED", Message: "You are not authorized to access this resource"},
-	ErrForbidden:          {Code: "FORBIDDEN", Message: "You don't have permission to perform this action"},
-	ErrInvalidCredentials: {Code: "INVALID_CREDENTIALS", Message: "Invalid credentials"},
+	ErrInternalServer:       {Code: "INTERNAL_SERVER_ERROR", Message: "An unexpected error occurred"},
+	ErrNotFound:             {Code: "NOT_FOUND", Message: "The requested resource was not found"},
+	ErrInvalidInput:         {Code: "INVALID_INPUT", Message: "The input provided is invalid"},
+	ErrInvalidMethod:        {Code: "METHOD_NOT_ALLOWED", Message: "The HTTP method is not allowed for this endpoint"},
+	ErrConflict:             {Code: "CONFLICT", Message: "The resource conflicts with an existing one"},
+	ErrTooManyRequests:      {Code: "RATE_LIMITED", Message: "Too many requests, try again later"},
+	ErrIdempotencyKeyReused: {Code: "IDEMPOTENCY_KEY_REUSED", Message: "The Idempotency-Key was already used for a different request"},
+	ErrDatabase:             {Code: "DATABASE_ERROR", Message: "A database error occurred"},
+	ErrDatabaseUnavailable:  {Code: "DATABASE_UNAVAILABLE", Message: "The database is temporarily unavailable"},
+	ErrCache:                {Code: "CACHE_ERROR", Message: "A cache error occurred"},
+	ErrUnauthorized:         {Code: "UNAUTHORIZED", Message: "You are not authorized to access this resource"},
+	ErrForbidden:            {Code: "FORBIDDEN", Message: "You don't have permission to perform this action"},
+	ErrInvalidCredentials:   {Code: "INVALID_CREDENTIALS", Message: "Invalid credentials"},
+	ErrUpstreamUnavailable:  {Code: "UPSTREAM_UNAVAILABLE", Message: "A service we depend on is unavailable, try again later"},
+	ErrUpstreamTimeout:      {Code: "UPSTREAM_TIMEOUT", Message: "A service we depend on took too long to answer, try again later"},
 }
 
 // HTTP status codes for predefined errors
 var StatusMap = map[error]int{
-	ErrInternalServer:     http.StatusInternalServerError,
-	ErrNotFound:           http.StatusNotFound,
-	ErrInvalidInput:       http.StatusBadRequest,
-	ErrInvalidMethod:      http.StatusMethodNotAllowed,
-	ErrDatabase:           http.StatusInternalServerError,
-	ErrCache:              http.StatusInternalServerError,
-	ErrUnauthorized:       http.StatusUnauthorized,
-	ErrForbidden:          http.StatusForbidden,
-	ErrInvalidCredentials: http.StatusBadRequest,
+	ErrInternalServer:       http.StatusInternalServerError,
+	ErrNotFound:             http.StatusNotFound,
+	ErrInvalidInput:         http.StatusBadRequest,
+	ErrInvalidMethod:        http.StatusMethodNotAllowed,
+	ErrConflict:             http.StatusConflict,
+	ErrTooManyRequests:      http.StatusTooManyRequests,
+	ErrIdempotencyKeyReused: http.StatusUnprocessableEntity,
+	ErrDatabase:             http.StatusInternalServerError,
+	ErrDatabaseUnavailable:  http.StatusServiceUnavailable,
+	ErrCache:                http.StatusInternalServerError,
+	ErrUnauthorized:         http.StatusUnauthorized,
+	ErrForbidden:            http.StatusForbidden,
+	ErrInvalidCredentials:   http.StatusBadRequest,
+	ErrUpstreamUnavailable:  http.StatusBadGateway,
+	ErrUpstreamTimeout:      http.StatusGatewayTimeout,
+}
+
+// sentinelOrder is the order sentinels are matched in, most specific first
+var sentinelOrder = []error{
+	ErrInvalidMethod,
+	ErrInvalidCredentials,
+	ErrUnauthorized,
+	ErrForbidden,
+	ErrInvalidInput,
+	ErrNotFound,
+	ErrConflict,
+	ErrTooManyRequests,
+	ErrIdempotencyKeyReused,
+	ErrUpstreamTimeout,
+	ErrUpstreamUnavailable,
+	ErrDatabaseUnavailable,
+	ErrDatabase,
+	ErrCache,
+	ErrInternalServer,
+}
+
+// FieldError describes why a single input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when the input is invalid, with one entry per offending field
+type ValidationError struct {
+	Fields []FieldError
 }
 
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrInvalidInput }
+
+// NotFoundError is returned when the requested resource doesn't exist
+type NotFoundError struct {
+	Resource string
+}
+
+func NewNotFoundError(resource string) *NotFoundError {
+	return &NotFoundError{Resource: resource}
+}
+
+func (e *NotFoundError) Error() string { return e.Resource + " not found" }
+
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// ConflictError is returned when the request clashes with existing data
+type ConflictError struct {
+	Message string
+}
+
+func NewConflictError(message string) *ConflictError {
+	return &ConflictError{Message: message}
+}
+
+func (e *ConflictError) Error() string { return e.Message }
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// UpstreamError wraps a failure talking to an external service (LLM, market data...)
+type UpstreamError struct {
+	Service string
+	Err     error
+}
+
+func NewUpstreamError(service string, err error) *UpstreamError {
+	return &UpstreamError{Service: service, Err: err}
+}
+
+func (e *UpstreamError) Error() string { return fmt.Sprintf("%s: %v", e.Service, e.Err) }
+
+func (e *UpstreamError) Unwrap() error { return e.Err }
+
+func (e *UpstreamError) Is(target error) bool {
+	if target == ErrUpstreamTimeout {
+		return isTimeout(e.Err)
+	}
+	return target == ErrUpstreamUnavailable
+}
+
+// MapErrorToAPIError turns any error returned by a handler into the response sent to the client.
+// Only the typed errors carry their own message; everything else gets the generic message of
+// its category so internal details never leak.
 func MapErrorToAPIError(err error) (*APIError, int) {
-	if apiErr, found := ErrorMap[err]; found {
-		return &apiErr, StatusMap[err]
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return &APIError{
+			Code:    ErrorMap[ErrInvalidInput].Code,
+			Message: validationErr.Error(),
+			Details: validationErr.Fields,
+		}, http.StatusBadRequest
+	}
+
+	var notFoundErr *NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return &APIError{Code: ErrorMap[ErrNotFound].Code, Message: notFoundErr.Error()}, http.StatusNotFound
+	}
+
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		return &APIError{Code: ErrorMap[ErrConflict].Code, Message: conflictErr.Error()}, http.StatusConflict
+	}
+
+	// Decode failures of upstream responses are the upstream's fault, not the client's
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		if apiErr, status, ok := mapDecodeError(err); ok {
+			return apiErr, status
+		}
+	}
+
+	if upstreamErr == nil {
+		if mongo.IsDuplicateKeyError(err) {
+			err = ErrConflict
+		} else if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+			err = ErrDatabaseUnavailable
+		}
+	}
+
+	for _, sentinel := range sentinelOrder {
+		if errors.Is(err, sentinel) {
+			apiErr := ErrorMap[sentinel]
+			return &apiErr, StatusMap[sentinel]
+		}
 	}
+
 	// Default fallback for unexpected errors
-	genericErr := APIError{
-		Code:    "GENERIC",
-		Message: err.Error(),
+	apiErr := ErrorMap[ErrInternalServer]
+	return &apiErr, http.StatusInternalServerError
+}
+
+// mapDecodeError reports malformed request bodies as invalid input instead of server errors
+func mapDecodeError(err error) (*APIError, int, bool) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return &APIError{
+			Code:    ErrorMap[ErrInvalidInput].Code,
+			Message: "invalid value for field " + typeErr.Field,
+			Details: []FieldError{{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}},
+		}, http.StatusBadRequest, true
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &APIError{
+			Code:    ErrorMap[ErrInvalidInput].Code,
+			Message: "request body is not valid JSON",
+		}, http.StatusBadRequest, true
+	}
+	return nil, 0, false
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
 	}
-	return &genericErr, http.StatusBadRequest
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
@@ -2,7 +2,6 @@ package utils
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -10,41 +9,47 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// TokenTTL is how long an issued token stays valid
+const TokenTTL = 24 * time.Hour
+
 type Claims struct {
-	ID string `json:"_id"`
+	ID        string `json:"_id"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(secretKey []byte, id primitive.ObjectID) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+func GenerateJWT(keys *KeyManager, id primitive.ObjectID, sessionID primitive.ObjectID) (string, error) {
+	key := keys.Current()
+	if key == nil {
+		return "", fmt.Errorf("Error signing token: no signing key available")
+	}
+
+	expirationTime := time.Now().Add(TokenTTL)
 
 	claims := Claims{
-		ID: id.Hex(),
+		ID:        id.Hex(),
+		SessionID: sessionID.Hex(),
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    os.Getenv("ISSUER"),
+			Issuer:    keys.issuer,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(secretKey)
+	token := jwt.NewWithClaims(key.signingMethod(), claims)
+	token.Header["kid"] = key.ID
+	signedToken, err := token.SignedString(key.Private)
 	if err != nil {
-		return "", fmt.Errorf("Error signing token: %v", err)
+		return "", fmt.Errorf("Error signing token: %w", err)
 	}
 
 	return signedToken, nil
 }
 
-func ValidateJWT(secretKey []byte, tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", t.Header["alg"])
-		}
-		return secretKey, nil
-	})
+func ValidateJWT(keys *KeyManager, tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.verificationKey)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse token: %v", err)
+		return nil, fmt.Errorf("Failed to parse token: %w", err)
 	}
 
 	if !token.Valid {
@@ -0,0 +1,440 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	rsaKeyBits   = 2048
+	keyFileExt   = ".pem"
+	pemKeyType   = "PRIVATE KEY"
+	pemCreatedAt = "Created-At"
+
+	// minKeyReloadInterval spaces the reloads for tokens signed by an unknown key, so tokens
+	// with made up kids cannot keep the keys directory busy
+	minKeyReloadInterval = 10 * time.Second
+)
+
+// SigningKey is a private key used to sign tokens, identified by its kid
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// JWK is the public part of a signing key as published in the JWKS document
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyManager holds the active signing keys. The newest key signs new tokens,
+// older keys are kept for verification until every token they signed has expired.
+type KeyManager struct {
+	mu               sync.RWMutex
+	issuer           string
+	algorithm        string
+	dir              string
+	rotationInterval time.Duration
+	keys             []*SigningKey // newest first
+	legacySecret     []byte
+
+	reloadMu   sync.Mutex
+	lastReload time.Time
+}
+
+// NewKeyManager loads the keys stored in cfg.KeysDir (if any) and makes sure there is a
+// usable signing key. An empty KeysDir keeps the keys in memory only, so a restart invalidates
+// every token.
+// cfg.LegacySecret, when set, is still accepted to verify old HS256 tokens.
+func NewKeyManager(cfg config.JWTConfig) (*KeyManager, error) {
+	if cfg.Algorithm != AlgorithmRS256 && cfg.Algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s, must be %s or %s", cfg.Algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+
+	km := &KeyManager{
+		issuer:           cfg.Issuer,
+		algorithm:        cfg.Algorithm,
+		dir:              cfg.KeysDir,
+		rotationInterval: cfg.RotationInterval,
+		legacySecret:     []byte(cfg.LegacySecret),
+	}
+
+	if km.dir != "" {
+		if err := os.MkdirAll(km.dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create keys directory: %w", err)
+		}
+		if err := km.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	km.prune()
+	if km.needsRotation() {
+		if err := km.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return km, nil
+}
+
+// Current returns the key used to sign new tokens
+func (km *KeyManager) Current() *SigningKey {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	for _, key := range km.keys {
+		if key.Algorithm == km.algorithm {
+			return key
+		}
+	}
+	return nil
+}
+
+// Rotate generates a new signing key and retires the expired ones
+func (km *KeyManager) Rotate() error {
+	key, err := generateSigningKey(km.algorithm)
+	if err != nil {
+		return err
+	}
+
+	if km.dir != "" {
+		if err := km.save(key); err != nil {
+			return err
+		}
+	}
+
+	km.mu.Lock()
+	km.keys = append([]*SigningKey{key}, km.keys...)
+	km.mu.Unlock()
+
+	km.prune()
+	slog.Info("rotated JWT signing key", "kid", key.ID)
+	return nil
+}
+
+// CheckRotation picks up the keys other instances rotated and rotates the signing key once it
+// is due, meant to run periodically
+func (km *KeyManager) CheckRotation(ctx context.Context) {
+	// Other instances sharing the keys directory may have rotated already
+	if km.dir != "" {
+		if err := km.load(); err != nil {
+			slog.Error("error reloading JWT keys", "error", err)
+		}
+	}
+	if km.needsRotation() {
+		if err := km.Rotate(); err != nil {
+			slog.Error("error rotating JWT signing key", "error", err)
+		}
+	} else {
+		km.prune()
+	}
+}
+
+// JWKS returns the public keys that can currently verify our tokens
+func (km *KeyManager) JWKS() JWKSet {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(km.keys))}
+	for _, key := range km.keys {
+		jwk, err := publicJWK(key)
+		if err != nil {
+			slog.Warn("skipping key in JWKS", "kid", key.ID, "error", err)
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func (key *SigningKey) signingMethod() jwt.SigningMethod {
+	if key.Algorithm == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// verificationKey is the jwt.Keyfunc used to look up the key of a token by its kid
+func (km *KeyManager) verificationKey(t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(km.legacySecret) == 0 {
+			return nil, fmt.Errorf("Unexpected signing method: %v", t.Header["alg"])
+		}
+		return km.legacySecret, nil
+	}
+
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("Missing kid in token header")
+	}
+
+	key := km.find(kid)
+	// Another instance sharing the keys directory may have rotated since the last reload
+	if key == nil && km.reloadForUnknownKey() {
+		key = km.find(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("Unknown signing key: %s", kid)
+	}
+	if t.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("Unexpected signing method: %v", t.Header["alg"])
+	}
+	return key.Private.Public(), nil
+}
+
+func (km *KeyManager) find(kid string) *SigningKey {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+
+	for _, key := range km.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// reloadForUnknownKey reloads the keys directory, at most once every minKeyReloadInterval,
+// reporting whether it did
+func (km *KeyManager) reloadForUnknownKey() bool {
+	if km.dir == "" {
+		return false
+	}
+	km.reloadMu.Lock()
+	defer km.reloadMu.Unlock()
+
+	if time.Since(km.lastReload) < minKeyReloadInterval {
+		return false
+	}
+	km.lastReload = time.Now()
+	if err := km.load(); err != nil {
+		slog.Error("error reloading JWT keys", "error", err)
+		return false
+	}
+	return true
+}
+
+func (km *KeyManager) needsRotation() bool {
+	current := km.Current()
+	if current == nil {
+		return true
+	}
+	return km.rotationInterval > 0 && time.Since(current.CreatedAt) >= km.rotationInterval
+}
+
+// prune drops keys that were replaced long enough ago that no valid token can reference them
+func (km *KeyManager) prune() {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	kept := make([]*SigningKey, 0, len(km.keys))
+	for i, key := range km.keys {
+		// A key stops signing when the next (newer) one is created
+		if i > 0 && time.Since(km.keys[i-1].CreatedAt) > TokenTTL {
+			if km.dir != "" {
+				if err := os.Remove(km.keyPath(key)); err != nil && !os.IsNotExist(err) {
+					slog.Error("error removing retired key", "kid", key.ID, "error", err)
+				}
+			}
+			continue
+		}
+		kept = append(kept, key)
+	}
+	km.keys = kept
+}
+
+func (km *KeyManager) keyPath(key *SigningKey) string {
+	return filepath.Join(km.dir, key.ID+keyFileExt)
+}
+
+func (km *KeyManager) load() error {
+	entries, err := os.ReadDir(km.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read keys directory: %w", err)
+	}
+
+	var keys []*SigningKey
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), keyFileExt) {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(km.dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read key %s: %w", entry.Name(), err)
+		}
+		key, err := parseSigningKey(raw)
+		if err != nil {
+			return fmt.Errorf("failed to parse key %s: %w", entry.Name(), err)
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	km.mu.Lock()
+	km.keys = keys
+	km.mu.Unlock()
+	return nil
+}
+
+func (km *KeyManager) save(key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	block := &pem.Block{
+		Type:    pemKeyType,
+		Headers: map[string]string{pemCreatedAt: key.CreatedAt.Format(time.RFC3339)},
+		Bytes:   der,
+	}
+	if err := os.WriteFile(km.keyPath(key), pem.EncodeToMemory(block), 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return nil
+}
+
+func generateSigningKey(algorithm string) (*SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+
+	return newSigningKey(private, time.Now().UTC().Truncate(time.Second))
+}
+
+func parseSigningKey(raw []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != pemKeyType {
+		return nil, fmt.Errorf("no %s PEM block found", pemKeyType)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, block.Headers[pemCreatedAt])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", pemCreatedAt, err)
+	}
+
+	return newSigningKey(private, createdAt)
+}
+
+func newSigningKey(private crypto.Signer, createdAt time.Time) (*SigningKey, error) {
+	key := &SigningKey{Private: private, CreatedAt: createdAt}
+	switch private.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgorithmEdDSA
+	case *rsa.PrivateKey:
+		key.Algorithm = AlgorithmRS256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", private)
+	}
+
+	jwk, err := publicJWK(key)
+	if err != nil {
+		return nil, err
+	}
+	key.ID, err = thumbprint(jwk)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func publicJWK(key *SigningKey) (JWK, error) {
+	jwk := JWK{Use: "sig", Algorithm: key.Algorithm, KeyID: key.ID}
+
+	switch public := key.Private.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+	return jwk, nil
+}
+
+// thumbprint computes the RFC 7638 thumbprint of a key, used as its kid so every
+// instance sharing a key derives the same identifier
+func thumbprint(jwk JWK) (string, error) {
+	var required any
+	if jwk.KeyType == "OKP" {
+		required = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	} else {
+		required = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	}
+
+	canonical, err := json.Marshal(required)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute key thumbprint: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
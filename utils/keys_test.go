@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arcedo/financial-ai-backend/config"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestKeys(t *testing.T, cfg config.JWTConfig) *KeyManager {
+	t.Helper()
+	keys, err := NewKeyManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestKeyManagerAlgorithms(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		keyType   string
+	}{
+		{AlgorithmEdDSA, "OKP"},
+		{AlgorithmRS256, "RSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			keys := newTestKeys(t, config.JWTConfig{Algorithm: tt.algorithm, Issuer: "test"})
+			userID, sessionID := primitive.NewObjectID(), primitive.NewObjectID()
+
+			token, err := GenerateJWT(keys, userID, sessionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ValidateJWT(keys, token)
+			if err != nil {
+				t.Fatalf("ValidateJWT: %v", err)
+			}
+			if claims.ID != userID.Hex() || claims.SessionID != sessionID.Hex() || claims.Issuer != "test" {
+				t.Errorf("claims = %+v", claims)
+			}
+
+			set := keys.JWKS()
+			if len(set.Keys) != 1 {
+				t.Fatalf("JWKS has %d keys, want 1", len(set.Keys))
+			}
+			jwk := set.Keys[0]
+			if jwk.KeyType != tt.keyType || jwk.Algorithm != tt.algorithm || jwk.Use != "sig" || jwk.KeyID != keys.Current().ID {
+				t.Errorf("JWKS key = %+v", jwk)
+			}
+			// The kid is the thumbprint of the public key
+			if kid, err := thumbprint(jwk); err != nil || kid != jwk.KeyID {
+				t.Errorf("thumbprint = %q (%v), want %q", kid, err, jwk.KeyID)
+			}
+		})
+	}
+}
+
+func TestNewKeyManagerUnsupportedAlgorithm(t *testing.T) {
+	if _, err := NewKeyManager(config.JWTConfig{Algorithm: "HS256"}); err == nil {
+		t.Error("expected an error for HS256")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	keys := newTestKeys(t, config.JWTConfig{Algorithm: AlgorithmEdDSA})
+	old := keys.Current()
+	oldToken, err := GenerateJWT(keys, primitive.NewObjectID(), primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keys.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	current := keys.Current()
+	if current.ID == old.ID {
+		t.Fatal("Rotate kept the same signing key")
+	}
+
+	// Tokens signed by the previous key stay valid until they expire
+	if _, err := ValidateJWT(keys, oldToken); err != nil {
+		t.Errorf("token signed before the rotation rejected: %v", err)
+	}
+	newToken, _ := GenerateJWT(keys, primitive.NewObjectID(), primitive.NewObjectID())
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := parsed.Header["kid"]; kid != current.ID {
+		t.Errorf("new token signed with kid %v, want %s", kid, current.ID)
+	}
+
+	kids := map[string]bool{}
+	for _, jwk := range keys.JWKS().Keys {
+		kids[jwk.KeyID] = true
+	}
+	if len(kids) != 2 || !kids[old.ID] || !kids[current.ID] {
+		t.Errorf("JWKS kids = %v, want the old and the current key", kids)
+	}
+}
+
+func TestKeyPrune(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		createdAt []time.Duration // newest first, ago
+		wantKept  int
+	}{
+		{"single key", []time.Duration{48 * time.Hour}, 1},
+		{"replaced within the token lifetime", []time.Duration{time.Hour, 30 * 24 * time.Hour}, 2},
+		{"replaced longer ago than the token lifetime", []time.Duration{TokenTTL + time.Hour, 30 * 24 * time.Hour}, 1},
+		{"only the newest retired key is kept", []time.Duration{time.Hour, TokenTTL + 2*time.Hour, 30 * 24 * time.Hour}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			km := &KeyManager{algorithm: AlgorithmEdDSA}
+			for _, ago := range tt.createdAt {
+				key, err := generateSigningKey(AlgorithmEdDSA)
+				if err != nil {
+					t.Fatal(err)
+				}
+				key.CreatedAt = now.Add(-ago)
+				km.keys = append(km.keys, key)
+			}
+			newest := km.keys[0]
+
+			km.prune()
+			if len(km.keys) != tt.wantKept {
+				t.Errorf("kept %d keys, want %d", len(km.keys), tt.wantKept)
+			}
+			if km.Current() != newest {
+				t.Error("prune dropped the signing key")
+			}
+		})
+	}
+}
+
+func TestKeyNeedsRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		age      time.Duration
+		want     bool
+	}{
+		{"fresh key", 24 * time.Hour, time.Hour, false},
+		{"key past the interval", 24 * time.Hour, 25 * time.Hour, true},
+		{"rotation disabled", 0, 1000 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := generateSigningKey(AlgorithmEdDSA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key.CreatedAt = time.Now().Add(-tt.age)
+			km := &KeyManager{algorithm: AlgorithmEdDSA, rotationInterval: tt.interval, keys: []*SigningKey{key}}
+			if got := km.needsRotation(); got != tt.want {
+				t.Errorf("needsRotation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A key of another algorithm cannot sign, e.g. after switching JWT_ALGORITHM
+	key, _ := generateSigningKey(AlgorithmEdDSA)
+	km := &KeyManager{algorithm: AlgorithmRS256, keys: []*SigningKey{key}}
+	if !km.needsRotation() {
+		t.Error("a key of the previous algorithm must be replaced")
+	}
+}
+
+func TestKeyManagerSharesKeysDir(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.JWTConfig{Algorithm: AlgorithmEdDSA, KeysDir: dir, RotationInterval: 24 * time.Hour}
+	first := newTestKeys(t, cfg)
+	token, err := GenerateJWT(first, primitive.NewObjectID(), primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another instance, or a restart, picks up the stored key instead of generating one
+	second := newTestKeys(t, cfg)
+	if second.Current().ID != first.Current().ID {
+		t.Errorf("second instance signs with %s, want %s", second.Current().ID, first.Current().ID)
+	}
+	if _, err := ValidateJWT(second, token); err != nil {
+		t.Errorf("token of the first instance rejected: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, first.Current().ID+keyFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("key file mode = %o, want 600", perm)
+	}
+}
+
+func TestLegacySecretTokens(t *testing.T) {
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		ID:               primitive.NewObjectID().Hex(),
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	})
+	token, err := legacy.SignedString([]byte("old secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{"accepted with the legacy secret", "old secret", false},
+		{"rejected without it", "", true},
+		{"rejected with another secret", "new secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := newTestKeys(t, config.JWTConfig{Algorithm: AlgorithmEdDSA, LegacySecret: tt.secret})
+			if _, err := ValidateJWT(keys, token); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWT() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyManagerReloadsForUnknownKey(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.JWTConfig{Algorithm: AlgorithmEdDSA, KeysDir: dir, RotationInterval: 24 * time.Hour}
+	first := newTestKeys(t, cfg)
+	second := newTestKeys(t, cfg)
+
+	// The second instance rotates, the first verifies its tokens without waiting for its reload
+	if err := second.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	token, err := GenerateJWT(second, primitive.NewObjectID(), primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(first, token); err != nil {
+		t.Errorf("token of the rotated key rejected: %v", err)
+	}
+
+	// Further unknown kids do not reload again right away
+	if err := second.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	token, _ = GenerateJWT(second, primitive.NewObjectID(), primitive.NewObjectID())
+	if _, err := ValidateJWT(first, token); err == nil {
+		t.Error("expected the reload to be rate limited")
+	}
+	first.lastReload = time.Now().Add(-minKeyReloadInterval)
+	if _, err := ValidateJWT(first, token); err != nil {
+		t.Errorf("token rejected once the reload is allowed again: %v", err)
+	}
+}
+
+func TestCheckRotation(t *testing.T) {
+	cfg := config.JWTConfig{Algorithm: AlgorithmEdDSA, KeysDir: t.TempDir(), RotationInterval: 24 * time.Hour}
+	first := newTestKeys(t, cfg)
+	second := newTestKeys(t, cfg)
+	old := first.Current().ID
+
+	// Nothing is due yet
+	first.CheckRotation(context.Background())
+	if first.Current().ID != old {
+		t.Fatal("rotated a fresh key")
+	}
+
+	// The other instance picks up a rotation
+	if err := first.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	second.CheckRotation(context.Background())
+	if second.find(first.Current().ID) == nil {
+		t.Errorf("second instance does not know the rotated key %s", first.Current().ID)
+	}
+
+	// Once due, the key is rotated
+	inMemory := newTestKeys(t, config.JWTConfig{Algorithm: AlgorithmEdDSA, RotationInterval: 24 * time.Hour})
+	due := inMemory.Current()
+	due.CreatedAt = time.Now().Add(-25 * time.Hour)
+	inMemory.CheckRotation(context.Background())
+	if inMemory.Current().ID == due.ID {
+		t.Error("the key past the interval was not rotated")
+	}
+}
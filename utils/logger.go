@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestInfoKey
+)
+
+// RequestIDHeader carries the request ID between us, our clients and the services we call
+const RequestIDHeader = "X-Request-ID"
+
+// RequestInfo is filled while a request goes through the middlewares so the access log
+// can report values only known deeper in the chain, like the authenticated user
+type RequestInfo struct {
+	ID     string
+	UserID string
+}
+
+// NewLogger builds the application logger. format is "json" or "text".
+func NewLogger(level, format string) (*slog.Logger, error) {
+	var slogLevel slog.Level
+	if err := slogLevel.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	options := &slog.HandlerOptions{Level: slogLevel}
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stdout, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stdout, options)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, must be json or text", format)
+}
+
+// WithLogger stores a request scoped logger in the context
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// LoggerFrom returns the logger of the context, or the default one outside of a request
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey, info)
+}
+
+// RequestInfoFrom returns the info of the current request, or nil outside of a request
+func RequestInfoFrom(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey).(*RequestInfo)
+	return info
+}
+
+// NewRequestID generates a random ID for requests that don't bring their own
+func NewRequestID() string {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(raw)
+}
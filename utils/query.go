@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeQuery fills the fields of the struct v points to from the query parameters named
+// after their json tags. Fields whose parameter is absent keep their value, so defaults can be
+// set beforehand. Unparsable values are reported together as a *ValidationError; the
+// constraints of the validate tags are checked with Validate afterwards.
+func DecodeQuery(values url.Values, v any) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode query: expected a pointer to a struct, got %T", v)
+	}
+	value = value.Elem()
+
+	var fields []FieldError
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		name := jsonName(valueType.Field(i))
+		raw, ok := values[name]
+		if !ok || !valueType.Field(i).IsExported() {
+			continue
+		}
+
+		if message := setQueryValue(value.Field(i), raw); message != "" {
+			fields = append(fields, FieldError{Field: name, Message: name + " " + message})
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// setQueryValue parses the raw values into the field and returns why they are invalid, if so
+func setQueryValue(field reflect.Value, raw []string) string {
+	last := strings.TrimSpace(raw[len(raw)-1])
+
+	switch field.Kind() {
+	case reflect.Pointer:
+		// Optional parameters, nil when absent
+		value := reflect.New(field.Type().Elem())
+		if message := setQueryValue(value.Elem(), raw); message != "" {
+			return message
+		}
+		field.Set(value)
+	case reflect.String:
+		field.SetString(last)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		number, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return "must be an integer"
+		}
+		field.SetInt(number)
+	case reflect.Float32, reflect.Float64:
+		number, err := strconv.ParseFloat(last, 64)
+		if err != nil {
+			return "must be a number"
+		}
+		field.SetFloat(number)
+	case reflect.Bool:
+		flag, err := strconv.ParseBool(last)
+		if err != nil {
+			return "must be true or false"
+		}
+		field.SetBool(flag)
+	case reflect.Slice:
+		// Lists are accepted both repeated (?a=x&a=y) and comma separated (?a=x,y)
+		var items []string
+		for _, value := range raw {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return "is not supported as a query parameter"
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type testHistoryQuery struct {
+	From     string   `json:"from"`
+	Limit    int      `json:"limit"`
+	MinPrice *float64 `json:"min_price"`
+	Adjusted bool     `json:"adjusted"`
+	Symbols  []string `json:"symbols"`
+	Cursor   string
+	hidden   string
+}
+
+func TestDecodeQuery(t *testing.T) {
+	price := 12.5
+	tests := []struct {
+		name  string
+		query string
+		want  testHistoryQuery
+	}{
+		{"defaults kept", "", testHistoryQuery{Limit: 100}},
+		{"scalars", "from=2025-01-02&limit=20&adjusted=true", testHistoryQuery{From: "2025-01-02", Limit: 20, Adjusted: true}},
+		{"the last repeated value wins", "limit=5&limit=+7+", testHistoryQuery{Limit: 7}},
+		{"optional pointer", "min_price=12.5", testHistoryQuery{Limit: 100, MinPrice: &price}},
+		{"repeated list", "symbols=AAPL&symbols=MSFT", testHistoryQuery{Limit: 100, Symbols: []string{"AAPL", "MSFT"}}},
+		{"comma separated list", "symbols=AAPL,+MSFT,,", testHistoryQuery{Limit: 100, Symbols: []string{"AAPL", "MSFT"}}},
+		{"fields without json tag use their name", "Cursor=abc", testHistoryQuery{Limit: 100, Cursor: "abc"}},
+		{"unexported fields are ignored", "hidden=x", testHistoryQuery{Limit: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			got := testHistoryQuery{Limit: 100}
+			if err := DecodeQuery(values, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeQueryErrors(t *testing.T) {
+	values, _ := url.ParseQuery("limit=ten&min_price=cheap&adjusted=maybe")
+	var query testHistoryQuery
+	err := DecodeQuery(values, &query)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("DecodeQuery() = %v, want a *ValidationError", err)
+	}
+	want := []FieldError{
+		{Field: "limit", Message: "limit must be an integer"},
+		{Field: "min_price", Message: "min_price must be a number"},
+		{Field: "adjusted", Message: "adjusted must be true or false"},
+	}
+	if !reflect.DeepEqual(validationErr.Fields, want) {
+		t.Errorf("fields = %+v, want %+v", validationErr.Fields, want)
+	}
+
+	if err := DecodeQuery(values, query); err == nil || errors.As(err, &validationErr) {
+		t.Errorf("DecodeQuery(struct) = %v, want an error for a non pointer", err)
+	}
+}
@@ -1,27 +1,36 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
-func MakeHTTPRequest(method, url string, headers map[string]string, body io.Reader) ([]byte, error) {
-	req, err := http.NewRequest(method, url, body)
+// MakeHTTPRequest performs an outbound request bound to ctx, forwarding the request ID of
+// the incoming request (if any) so the called service can correlate its logs with ours
+func MakeHTTPRequest(ctx context.Context, method, url string, headers map[string]string, body io.Reader, timeout time.Duration) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
+	if info := RequestInfoFrom(ctx); info != nil {
+		req.Header.Set(RequestIDHeader, info.ID)
+	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: timeout}
 
 	res, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %v", err)
+		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer res.Body.Close()
 
@@ -31,8 +40,73 @@ func MakeHTTPRequest(method, url string, headers map[string]string, body io.Read
 
 	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
 	return resBody, nil
 }
+
+var (
+	proxiesMu      sync.RWMutex
+	trustedProxies []*net.IPNet
+)
+
+// SetTrustedProxies sets the CIDRs of the reverse proxies whose X-Forwarded-For header
+// ClientIP believes
+func SetTrustedProxies(cidrs []string) error {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy CIDR %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+
+	proxiesMu.Lock()
+	defer proxiesMu.Unlock()
+	trustedProxies = networks
+	return nil
+}
+
+// ClientIP returns the originating IP. X-Forwarded-For is only honoured for requests coming
+// from a trusted proxy, anyone else could send a new value on every request. The header is
+// read from the right, skipping the trusted proxies, since clients can prepend any address.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return host
+}
+
+func isTrustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	proxiesMu.RLock()
+	defer proxiesMu.RUnlock()
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RuleFunc checks a single field against a rule. It returns a message describing why the
+// value is invalid, or "" if it is valid. An error means the check itself could not run.
+type RuleFunc func(ctx context.Context, value reflect.Value, param string) (string, error)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{
+		"min":      minRule,
+		"max":      maxRule,
+		"enum":     enumRule,
+		"date":     dateRule,
+		"email":    emailRule,
+		"positive": positiveRule,
+	}
+)
+
+// RegisterRule adds a rule usable in `validate` tags, e.g. rules that need the database
+func RegisterRule(name string, fn RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = fn
+}
+
+// Validate checks v against the `validate` tags of its fields and reports every invalid
+// field at once as a *ValidationError. Supported tags:
+//
+//	required            value must be present and not blank
+//	nonblank            if present (non-nil pointer), value must not be blank
+//	required_if=f:a|b   required when sibling json field f has one of the listed values
+//	min=n, max=n        length for strings, bounds for numbers
+//	enum=a|b            value must be one of the listed ones
+//	date                YYYY-MM-DD date
+//	email               valid email address
+//	positive            number greater than zero
+//
+// Other rules only run on present, non-empty values.
+func Validate(ctx context.Context, v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: expected a struct, got %s", value.Kind())
+	}
+
+	var fields []FieldError
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		tag := valueType.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		name := jsonName(valueType.Field(i))
+		message, err := validateField(ctx, value, value.Field(i), tag)
+		if err != nil {
+			return fmt.Errorf("error validating %s: %w", name, err)
+		}
+		if message != "" {
+			fields = append(fields, FieldError{Field: name, Message: name + " " + message})
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateField returns the message of the first failing rule of the field, if any
+func validateField(ctx context.Context, parent, field reflect.Value, tag string) (string, error) {
+	present := !(field.Kind() == reflect.Pointer && field.IsNil())
+	if present {
+		field = reflect.Indirect(field)
+	}
+	empty := !present || isBlank(field)
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "required":
+			if empty {
+				return "is required", nil
+			}
+			continue
+		case "nonblank":
+			if present && empty {
+				return "cannot be empty", nil
+			}
+			continue
+		case "required_if":
+			if empty && siblingMatches(parent, param) {
+				return "is required", nil
+			}
+			continue
+		}
+
+		if empty {
+			continue
+		}
+
+		rulesMu.RLock()
+		fn, ok := rules[name]
+		rulesMu.RUnlock()
+		if !ok {
+			return "", fmt.Errorf("unknown validation rule %q", name)
+		}
+
+		message, err := fn(ctx, field, param)
+		if err != nil || message != "" {
+			return message, err
+		}
+	}
+	return "", nil
+}
+
+// siblingMatches evaluates a required_if param like "type:buy|sell" against the parent struct
+func siblingMatches(parent reflect.Value, param string) bool {
+	fieldName, values, _ := strings.Cut(param, ":")
+	parentType := parent.Type()
+	for i := 0; i < parentType.NumField(); i++ {
+		if jsonName(parentType.Field(i)) != fieldName {
+			continue
+		}
+		sibling := reflect.Indirect(parent.Field(i))
+		return sibling.IsValid() && slices.Contains(strings.Split(values, "|"), fmt.Sprint(sibling.Interface()))
+	}
+	return false
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// isBlank reports empty strings and collections; zero numbers are valid values
+func isBlank(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len() == 0
+	}
+	return false
+}
+
+func minRule(_ context.Context, value reflect.Value, param string) (string, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid min param %q", param)
+	}
+	if value.Kind() == reflect.String {
+		if float64(utf8.RuneCountInString(value.String())) < limit {
+			return fmt.Sprintf("must be at least %s characters long", param), nil
+		}
+		return "", nil
+	}
+	if number, ok := numericValue(value); ok && number < limit {
+		return fmt.Sprintf("must be at least %s", param), nil
+	}
+	return "", nil
+}
+
+func maxRule(_ context.Context, value reflect.Value, param string) (string, error) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid max param %q", param)
+	}
+	if value.Kind() == reflect.String {
+		if float64(utf8.RuneCountInString(value.String())) > limit {
+			return fmt.Sprintf("must be at most %s characters long", param), nil
+		}
+		return "", nil
+	}
+	if number, ok := numericValue(value); ok && number > limit {
+		return fmt.Sprintf("must be at most %s", param), nil
+	}
+	return "", nil
+}
+
+func enumRule(_ context.Context, value reflect.Value, param string) (string, error) {
+	allowed := strings.Split(param, "|")
+	if !slices.Contains(allowed, fmt.Sprint(value.Interface())) {
+		return "must be one of " + strings.Join(allowed, ", "), nil
+	}
+	return "", nil
+}
+
+func dateRule(_ context.Context, value reflect.Value, _ string) (string, error) {
+	if _, err := time.Parse("2006-01-02", value.String()); err != nil {
+		return "must be a date in YYYY-MM-DD format", nil
+	}
+	return "", nil
+}
+
+func emailRule(_ context.Context, value reflect.Value, _ string) (string, error) {
+	if _, err := mail.ParseAddress(value.String()); err != nil {
+		return "must be a valid email address", nil
+	}
+	return "", nil
+}
+
+func positiveRule(_ context.Context, value reflect.Value, _ string) (string, error) {
+	number, ok := numericValue(value)
+	if !ok || number <= 0 {
+		return "must be greater than zero", nil
+	}
+	return "", nil
+}
+
+func numericValue(value reflect.Value) (float64, bool) {
+	switch {
+	case value.CanFloat():
+		return value.Float(), true
+	case value.CanInt():
+		return float64(value.Int()), true
+	case value.CanUint():
+		return float64(value.Uint()), true
+	}
+	return 0, false
+}
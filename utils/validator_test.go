@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testTransaction struct {
+	Type     string   `json:"type" validate:"required,enum=buy|sell|entry"`
+	Amount   float64  `json:"amount" validate:"positive"`
+	Quantity *float64 `json:"quantity,omitempty" validate:"positive"`
+	Date     string   `json:"date" validate:"required,date"`
+	Symbol   string   `json:"symbol" validate:"required_if=type:buy|sell,max=5"`
+}
+
+type testProfile struct {
+	Name     *string `json:"name" validate:"nonblank,max=10"`
+	Email    string  `json:"email" validate:"email"`
+	Password string  `json:"password" validate:"required,min=8"`
+	Age      int     `json:"age" validate:"min=18,max=130"`
+	Untagged string
+}
+
+func TestValidate(t *testing.T) {
+	quantity, negative := 2.0, -1.0
+	blank, long := "  ", "Bartholomew the Third"
+
+	tests := []struct {
+		name  string
+		value any
+		want  []FieldError
+	}{
+		{
+			name:  "valid",
+			value: testTransaction{Type: "buy", Amount: 10, Quantity: &quantity, Date: "2025-07-01", Symbol: "AAPL"},
+		},
+		{
+			name:  "optional fields absent",
+			value: &testTransaction{Type: "entry", Amount: 10, Date: "2025-07-01"},
+		},
+		{
+			name:  "every invalid field is reported",
+			value: testTransaction{Type: "swap", Amount: 0, Quantity: &negative, Date: "01/07/2025", Symbol: "TOOLONG"},
+			want: []FieldError{
+				{Field: "type", Message: "type must be one of buy, sell, entry"},
+				{Field: "amount", Message: "amount must be greater than zero"},
+				{Field: "quantity", Message: "quantity must be greater than zero"},
+				{Field: "date", Message: "date must be a date in YYYY-MM-DD format"},
+				{Field: "symbol", Message: "symbol must be at most 5 characters long"},
+			},
+		},
+		{
+			name:  "required fields",
+			value: testTransaction{Type: " ", Amount: 1},
+			want: []FieldError{
+				{Field: "type", Message: "type is required"},
+				{Field: "date", Message: "date is required"},
+			},
+		},
+		{
+			name:  "required_if a sibling matches",
+			value: testTransaction{Type: "sell", Amount: 1, Date: "2025-07-01"},
+			want:  []FieldError{{Field: "symbol", Message: "symbol is required"}},
+		},
+		{
+			name:  "nonblank and string lengths count characters",
+			value: testProfile{Name: &blank, Email: "ana@example.com", Password: "contraseña", Age: 30},
+			want:  []FieldError{{Field: "name", Message: "name cannot be empty"}},
+		},
+		{
+			name:  "number bounds and email",
+			value: testProfile{Name: &long, Email: "not an email", Password: "short", Age: 12},
+			want: []FieldError{
+				{Field: "name", Message: "name must be at most 10 characters long"},
+				{Field: "email", Message: "email must be a valid email address"},
+				{Field: "password", Message: "password must be at least 8 characters long"},
+				{Field: "age", Message: "age must be at least 18"},
+			},
+		},
+		{
+			name:  "zero numbers are values, not absent",
+			value: testProfile{Password: "long enough"},
+			want:  []FieldError{{Field: "age", Message: "age must be at least 18"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(context.Background(), tt.value)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Validate() = %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(validationErr.Fields, tt.want) {
+				t.Errorf("fields = %+v\nwant %+v", validationErr.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	if err := Validate(context.Background(), "not a struct"); err == nil {
+		t.Error("expected an error for a string")
+	}
+
+	var unknown struct {
+		Field string `json:"field" validate:"shiny"`
+	}
+	unknown.Field = "x"
+	err := Validate(context.Background(), unknown)
+	var validationErr *ValidationError
+	if err == nil || errors.As(err, &validationErr) {
+		t.Errorf("Validate() = %v, want an error for the unknown rule", err)
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	RegisterRule("even", func(_ context.Context, value reflect.Value, _ string) (string, error) {
+		if value.Int()%2 != 0 {
+			return "must be even", nil
+		}
+		return "", nil
+	})
+	t.Cleanup(func() {
+		rulesMu.Lock()
+		delete(rules, "even")
+		rulesMu.Unlock()
+	})
+
+	type pair struct {
+		Count int `json:"count" validate:"even"`
+	}
+	if err := Validate(context.Background(), pair{Count: 4}); err != nil {
+		t.Errorf("Validate(4) = %v", err)
+	}
+	if err := Validate(context.Background(), pair{Count: 3}); err == nil || err.Error() == "" {
+		t.Error("Validate(3) accepted an odd count")
+	}
+}